@@ -0,0 +1,229 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+// Package qn_breaker provides circuit breaker for protecting calls to unhealthy dependencies.
+package qn_breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Breaker is a circuit breaker, which has three states:
+// STATE_CLOSED: all calls are allowed, and it turns open if the failures reach the thresholds;
+// STATE_OPEN: all calls are rejected with ErrOpen, and it turns half-open after the cool-down;
+// STATE_HALF_OPEN: limited trial calls are allowed, it turns closed if they all succeed, or
+// turns open again if any of them fails.
+type Breaker struct {
+	mu          sync.Mutex
+	name        string    // Breaker name, like the host of the dependency.
+	config      Config    // Configuration.
+	state       int       // Current state.
+	consecutive int       // Consecutive failure count in closed state.
+	requests    int       // Request count in current statistic window.
+	failures    int       // Failure count in current statistic window.
+	windowStart time.Time // Starting time of current statistic window.
+	changedAt   time.Time // Last state changing time.
+	trials      int       // Allowed trial count in half-open state.
+	successes   int       // Succeeded trial count in half-open state.
+}
+
+// Config is the configuration for circuit breaker.
+type Config struct {
+	// ConsecutiveFailures specifies the consecutive failure count that turns the breaker open.
+	// It's 5 in default, and it's disabled if it's negative.
+	ConsecutiveFailures int
+
+	// FailureRate specifies the failure rate in range (0, 1] in the statistic window that
+	// turns the breaker open. It's disabled if it's zero.
+	FailureRate float64
+
+	// MinRequests specifies the min request count in the statistic window for the failure
+	// rate checks, which is 10 in default.
+	MinRequests int
+
+	// Window specifies the statistic window for the failure rate, which is 10 seconds in default.
+	Window time.Duration
+
+	// CoolDown specifies the duration of open state before turning half-open,
+	// which is 5 seconds in default.
+	CoolDown time.Duration
+
+	// HalfOpenRequests specifies the trial request count in half-open state, which is 1 in default.
+	HalfOpenRequests int
+
+	// OnStateChange is called when the state of the breaker changes.
+	// Note that it's called with lock of the breaker, so it should not call the breaker.
+	OnStateChange func(name string, from, to int)
+}
+
+const (
+	STATE_CLOSED    = 0 // All calls are allowed.
+	STATE_OPEN      = 1 // All calls are rejected.
+	STATE_HALF_OPEN = 2 // Limited trial calls are allowed.
+)
+
+const (
+	gDEFAULT_CONSECUTIVE_FAILURES = 5
+	gDEFAULT_MIN_REQUESTS         = 10
+	gDEFAULT_WINDOW               = 10 * time.Second
+	gDEFAULT_COOL_DOWN            = 5 * time.Second
+	gDEFAULT_HALF_OPEN_REQUESTS   = 1
+)
+
+var (
+	// ErrOpen is the error returned when the breaker rejects the call.
+	ErrOpen = errors.New("circuit breaker is open")
+)
+
+// New creates and returns a circuit breaker with <name>.
+// The optional parameter <config> specifies the configuration for the breaker.
+func New(name string, config ...Config) *Breaker {
+	c := Config{}
+	if len(config) > 0 {
+		c = config[0]
+	}
+	if c.ConsecutiveFailures == 0 {
+		c.ConsecutiveFailures = gDEFAULT_CONSECUTIVE_FAILURES
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = gDEFAULT_MIN_REQUESTS
+	}
+	if c.Window <= 0 {
+		c.Window = gDEFAULT_WINDOW
+	}
+	if c.CoolDown <= 0 {
+		c.CoolDown = gDEFAULT_COOL_DOWN
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = gDEFAULT_HALF_OPEN_REQUESTS
+	}
+	now := time.Now()
+	return &Breaker{
+		name:        name,
+		config:      c,
+		state:       STATE_CLOSED,
+		windowStart: now,
+		changedAt:   now,
+	}
+}
+
+// Name returns the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkCoolDown(time.Now())
+	return b.state
+}
+
+// Allow checks whether the call is allowed. It returns ErrOpen if the call is rejected.
+// The result of the allowed call should be reported using Report.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.checkCoolDown(now)
+	switch b.state {
+	case STATE_OPEN:
+		return ErrOpen
+	case STATE_HALF_OPEN:
+		// The trial calls may be lost without reporting, it allows new trials after the cool-down.
+		if b.trials >= b.config.HalfOpenRequests {
+			if now.Sub(b.changedAt) < b.config.CoolDown {
+				return ErrOpen
+			}
+			b.trials, b.successes = 0, 0
+			b.changedAt = now
+		}
+		b.trials++
+	}
+	return nil
+}
+
+// Report reports the result of the call to the breaker.
+func (b *Breaker) Report(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.checkCoolDown(now)
+	switch b.state {
+	case STATE_CLOSED:
+		if now.Sub(b.windowStart) >= b.config.Window {
+			b.requests, b.failures = 0, 0
+			b.windowStart = now
+		}
+		b.requests++
+		if success {
+			b.consecutive = 0
+			return
+		}
+		b.failures++
+		b.consecutive++
+		if b.config.ConsecutiveFailures > 0 && b.consecutive >= b.config.ConsecutiveFailures {
+			b.setState(STATE_OPEN, now)
+			return
+		}
+		if b.config.FailureRate > 0 && b.requests >= b.config.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.config.FailureRate {
+			b.setState(STATE_OPEN, now)
+		}
+
+	case STATE_HALF_OPEN:
+		if !success {
+			b.setState(STATE_OPEN, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenRequests {
+			b.setState(STATE_CLOSED, now)
+		}
+	}
+}
+
+// Do calls <f> if it's allowed by the breaker and reports its result,
+// the call fails if <f> returns error. It returns ErrOpen if the call is rejected.
+func (b *Breaker) Do(f func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	err := f()
+	b.Report(err == nil)
+	return err
+}
+
+// Reset resets the breaker to closed state.
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setState(STATE_CLOSED, time.Now())
+}
+
+// checkCoolDown turns the breaker half-open if the cool-down of open state passes.
+func (b *Breaker) checkCoolDown(now time.Time) {
+	if b.state == STATE_OPEN && now.Sub(b.changedAt) >= b.config.CoolDown {
+		b.setState(STATE_HALF_OPEN, now)
+	}
+}
+
+// setState changes the state of the breaker and resets the statistics.
+func (b *Breaker) setState(state int, now time.Time) {
+	from := b.state
+	b.state = state
+	b.changedAt = now
+	b.consecutive = 0
+	b.requests, b.failures = 0, 0
+	b.windowStart = now
+	b.trials, b.successes = 0, 0
+	if from != state && b.config.OnStateChange != nil {
+		b.config.OnStateChange(b.name, from, state)
+	}
+}
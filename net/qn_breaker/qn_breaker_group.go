@@ -0,0 +1,49 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_breaker
+
+import (
+	"github.com/qnsoft/common/container/qn_map"
+)
+
+// Group manages circuit breakers keyed by name, like the host of the dependency.
+// All breakers in the group are created with the same configuration.
+type Group struct {
+	config   []Config          // Configuration for creating breakers.
+	breakers *qn_map.StrAnyMap // Breakers, the key is the breaker name.
+}
+
+// NewGroup creates and returns a breaker group.
+// The optional parameter <config> specifies the configuration for the breakers in the group.
+func NewGroup(config ...Config) *Group {
+	return &Group{
+		config:   config,
+		breakers: qn_map.NewStrAnyMap(true),
+	}
+}
+
+// Get retrieves and returns the breaker of <name>, which is created if it does not exist.
+func (g *Group) Get(name string) *Breaker {
+	return g.breakers.GetOrSetFuncLock(name, func() interface{} {
+		return New(name, g.config...)
+	}).(*Breaker)
+}
+
+// Do calls <f> using the breaker of <name>. See Breaker.Do.
+func (g *Group) Do(name string, f func() error) error {
+	return g.Get(name).Do(f)
+}
+
+// States returns the states of all breakers in the group, the key is the breaker name.
+func (g *Group) States() map[string]int {
+	states := make(map[string]int)
+	g.breakers.Iterator(func(k string, v interface{}) bool {
+		states[k] = v.(*Breaker).State()
+		return true
+	})
+	return states
+}
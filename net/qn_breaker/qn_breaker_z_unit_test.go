@@ -0,0 +1,99 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_breaker_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_breaker"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+var errTest = errors.New("test error")
+
+func Test_Breaker_Consecutive(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		changes := make([]string, 0)
+		b := qn_breaker.New("test", qn_breaker.Config{
+			ConsecutiveFailures: 3,
+			CoolDown:            100 * time.Millisecond,
+			OnStateChange: func(name string, from, to int) {
+				changes = append(changes, fmt.Sprintf("%s:%d->%d", name, from, to))
+			},
+		})
+		for i := 0; i < 2; i++ {
+			t.Assert(b.Do(func() error { return errTest }), errTest)
+		}
+		// Success resets the consecutive failures.
+		t.Assert(b.Do(func() error { return nil }), nil)
+		for i := 0; i < 3; i++ {
+			t.Assert(b.Do(func() error { return errTest }), errTest)
+		}
+		t.Assert(b.State(), qn_breaker.STATE_OPEN)
+		t.Assert(b.Do(func() error { return nil }), qn_breaker.ErrOpen)
+
+		// Half-open after cool-down, and only one trial is allowed.
+		time.Sleep(150 * time.Millisecond)
+		t.Assert(b.State(), qn_breaker.STATE_HALF_OPEN)
+		t.Assert(b.Allow(), nil)
+		t.Assert(b.Allow(), qn_breaker.ErrOpen)
+		b.Report(true)
+		t.Assert(b.State(), qn_breaker.STATE_CLOSED)
+
+		t.Assert(changes, g.Slice{"test:0->1", "test:1->2", "test:2->0"})
+	})
+}
+
+func Test_Breaker_HalfOpenFailure(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		b := qn_breaker.New("test", qn_breaker.Config{
+			ConsecutiveFailures: 1,
+			CoolDown:            100 * time.Millisecond,
+		})
+		t.Assert(b.Do(func() error { return errTest }), errTest)
+		t.Assert(b.State(), qn_breaker.STATE_OPEN)
+		time.Sleep(150 * time.Millisecond)
+		t.Assert(b.Do(func() error { return errTest }), errTest)
+		t.Assert(b.State(), qn_breaker.STATE_OPEN)
+	})
+}
+
+func Test_Breaker_FailureRate(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		b := qn_breaker.New("test", qn_breaker.Config{
+			ConsecutiveFailures: -1,
+			FailureRate:         0.5,
+			MinRequests:         4,
+		})
+		b.Report(true)
+		b.Report(false)
+		b.Report(true)
+		t.Assert(b.State(), qn_breaker.STATE_CLOSED)
+		b.Report(false)
+		t.Assert(b.State(), qn_breaker.STATE_OPEN)
+	})
+}
+
+func Test_Breaker_Group(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		group := qn_breaker.NewGroup(qn_breaker.Config{
+			ConsecutiveFailures: 1,
+		})
+		t.Assert(group.Get("a"), group.Get("a"))
+		t.Assert(group.Do("a", func() error { return errTest }), errTest)
+		t.Assert(group.Do("a", func() error { return nil }), qn_breaker.ErrOpen)
+		t.Assert(group.Do("b", func() error { return nil }), nil)
+		t.Assert(group.States(), g.Map{
+			"a": qn_breaker.STATE_OPEN,
+			"b": qn_breaker.STATE_CLOSED,
+		})
+	})
+}
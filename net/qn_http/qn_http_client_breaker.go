@@ -0,0 +1,41 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"net/http"
+
+	"github.com/qnsoft/common/net/qn_breaker"
+)
+
+// SetBreaker adds circuit breaker middleware to the client, which uses the breaker of
+// the requested host in <group>. The request is rejected with qn_breaker.ErrOpen if the
+// breaker of the host is open.
+//
+// The request fails if it returns error or responses status code >= 500.
+func (c *Client) SetBreaker(group *qn_breaker.Group) *Client {
+	return c.Use(func(c *Client, req *http.Request, next ClientHandlerFunc) (*ClientResponse, error) {
+		breaker := group.Get(req.URL.Host)
+		if err := breaker.Allow(); err != nil {
+			return nil, err
+		}
+		resp, err := next(req)
+		breaker.Report(err == nil && resp.StatusCode < http.StatusInternalServerError)
+		return resp, err
+	})
+}
+
+// Breaker is a chaining function,
+// which adds circuit breaker middleware for next request.
+func (c *Client) Breaker(group *qn_breaker.Group) *Client {
+	newClient := c
+	if c.parent == nil {
+		newClient = c.Clone()
+	}
+	newClient.SetBreaker(group)
+	return newClient
+}
@@ -0,0 +1,317 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qnsoft/common/internal/utils"
+	"github.com/qnsoft/common/os/qn_cache"
+)
+
+const (
+	CLIENT_CACHE_MISS        = "MISS"        // The response is from the server, and it may be stored in cache.
+	CLIENT_CACHE_HIT         = "HIT"         // The response is from the cache without requesting the server.
+	CLIENT_CACHE_REVALIDATED = "REVALIDATED" // The response is from the cache after the server responds 304.
+)
+
+const (
+	gCLIENT_CACHE_KEY_PREFIX = "qn_http.client.cache:"
+)
+
+// clientCacheEntry is the cached response.
+type clientCacheEntry struct {
+	status     int               // Response status code.
+	header     http.Header       // Response header.
+	body       []byte            // Response body.
+	vary       map[string]string // Request header values of the names in response header Vary.
+	freshUntil time.Time         // The entry should be revalidated after this time.
+}
+
+// SetCache adds response caching middleware to the client, which stores the responses of
+// GET requests in <cache> following the HTTP cache semantics:
+//
+// 1. The response is stored according to its Cache-Control, Expires, ETag and Last-Modified headers;
+// 2. The fresh entry is responded directly without requesting the server;
+// 3. The stale entry is revalidated with If-None-Match and If-Modified-Since headers;
+// 4. The entry is removed after the unsafe request like POST/PUT/DELETE to the same URL succeeds;
+// 5. The request having Range, If-*, Authorization or Cookie headers bypasses the cache;
+// 6. The response having Set-Cookie header is not stored.
+//
+// The request also bypasses the cache if the cookie jar of the client has cookies for it,
+// as the <cache> might be shared by clients of different users.
+//
+// The entry having validators is kept after it's stale for revalidation, so it's recommended
+// using a cache with LRU capacity, like qn_cache.New(1000). See ClientResponse.CacheStatus.
+func (c *Client) SetCache(cache *qn_cache.Cache) *Client {
+	return c.Use(func(c *Client, req *http.Request, next ClientHandlerFunc) (*ClientResponse, error) {
+		// The cookies of the jar are added to the request after the middleware.
+		if c.Jar != nil && len(c.Jar.Cookies(req.URL)) > 0 {
+			return next(req)
+		}
+		return clientCacheHandler(cache, req, next)
+	})
+}
+
+// Cache is a chaining function,
+// which adds response caching middleware for next request.
+func (c *Client) Cache(cache *qn_cache.Cache) *Client {
+	newClient := c
+	if c.parent == nil {
+		newClient = c.Clone()
+	}
+	newClient.SetCache(cache)
+	return newClient
+}
+
+// CacheStatus returns the cache status of the response, which is one of CLIENT_CACHE_MISS,
+// CLIENT_CACHE_HIT and CLIENT_CACHE_REVALIDATED. It returns empty string if the request
+// does not go through the response caching, like POST request.
+func (r *ClientResponse) CacheStatus() string {
+	return r.cacheStatus
+}
+
+// clientCacheHandler is the handler of response caching middleware.
+func clientCacheHandler(cache *qn_cache.Cache, req *http.Request, next ClientHandlerFunc) (*ClientResponse, error) {
+	key := gCLIENT_CACHE_KEY_PREFIX + req.URL.String()
+	if req.Method != "GET" {
+		resp, err := next(req)
+		// The unsafe request invalidates the cached response of the same URL.
+		if err == nil && req.Method != "HEAD" && req.Method != "OPTIONS" && req.Method != "TRACE" &&
+			resp.StatusCode >= 200 && resp.StatusCode < 400 {
+			cache.Remove(key)
+		}
+		return resp, err
+	}
+	// The partial, conditional, authorized and cookie requests are not cached,
+	// as the key contains only the URL.
+	if isUncacheableRequest(req) {
+		return next(req)
+	}
+	requestCacheControl := parseCacheControl(req.Header.Get("Cache-Control"))
+	if _, ok := requestCacheControl["no-store"]; ok {
+		return next(req)
+	}
+	var (
+		now        = time.Now()
+		entry, _   = cache.Get(key).(*clientCacheEntry)
+		revalidate = false
+	)
+	if entry != nil && !entry.matchVary(req) {
+		entry = nil
+	}
+	if entry != nil {
+		_, noCache := requestCacheControl["no-cache"]
+		if maxAge, ok := requestCacheControl["max-age"]; ok && maxAge == "0" {
+			noCache = true
+		}
+		if !noCache && now.Before(entry.freshUntil) {
+			return entry.response(req, CLIENT_CACHE_HIT), nil
+		}
+		// Revalidating the stale entry.
+		if etag := entry.header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+			revalidate = true
+		}
+		if lastModified := entry.header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+			revalidate = true
+		}
+	}
+	resp, err := next(req)
+	if err != nil {
+		return resp, err
+	}
+	if revalidate && resp.StatusCode == http.StatusNotModified {
+		resp.Close()
+		// Updating the stored headers with the 304 response.
+		header := entry.header.Clone()
+		for k, v := range resp.Header {
+			header[k] = v
+		}
+		entry = &clientCacheEntry{
+			status: entry.status,
+			header: header,
+			body:   entry.body,
+			vary:   entry.vary,
+		}
+		entry.store(cache, key, now)
+		return entry.response(req, CLIENT_CACHE_REVALIDATED), nil
+	}
+	resp.cacheStatus = CLIENT_CACHE_MISS
+	if !isCacheableStatus(resp.StatusCode) {
+		return resp, nil
+	}
+	entry = &clientCacheEntry{
+		status: resp.StatusCode,
+		header: resp.Header.Clone(),
+	}
+	// The body is buffered into memory only if the response is going to be stored.
+	if _, ok := entry.freshness(now); !ok || !entry.initVary(req) {
+		cache.Remove(key)
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return resp, err
+	}
+	resp.Body = utils.NewReadCloser(body, false)
+	entry.body = body
+	entry.store(cache, key, now)
+	return resp, nil
+}
+
+// isUncacheableRequest checks whether <req> is a partial, conditional, authorized or cookie request,
+// whose response should not be served from or stored to the cache.
+func isUncacheableRequest(req *http.Request) bool {
+	if req.Header.Get("Range") != "" || req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		return true
+	}
+	for name := range req.Header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), "If-") {
+			return true
+		}
+	}
+	return false
+}
+
+// initVary records the request header values of the names in response header Vary.
+// It returns false if the response varies on all request headers.
+func (e *clientCacheEntry) initVary(req *http.Request) bool {
+	e.vary = make(map[string]string)
+	for _, value := range e.header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return false
+			}
+			if name != "" {
+				e.vary[name] = req.Header.Get(name)
+			}
+		}
+	}
+	return true
+}
+
+// matchVary checks whether the request header values of the names in Vary match <req>.
+func (e *clientCacheEntry) matchVary(req *http.Request) bool {
+	for name, value := range e.vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// store calculates the freshness of the entry and stores it to <cache>, if it's storable.
+func (e *clientCacheEntry) store(cache *qn_cache.Cache, key string, now time.Time) {
+	lifetime, ok := e.freshness(now)
+	if !ok {
+		cache.Remove(key)
+		return
+	}
+	e.freshUntil = now.Add(lifetime)
+	if e.hasValidator() {
+		// The stale entry is kept for revalidation until it's evicted by LRU.
+		cache.Set(key, e, 0)
+	} else {
+		cache.Set(key, e, lifetime)
+	}
+}
+
+// hasValidator checks whether the entry has ETag or Last-Modified for revalidation.
+func (e *clientCacheEntry) hasValidator() bool {
+	return e.header.Get("ETag") != "" || e.header.Get("Last-Modified") != ""
+}
+
+// freshness calculates and returns the freshness lifetime of the entry,
+// and whether the entry is storable, which is fresh or has validators, and sets no cookie.
+func (e *clientCacheEntry) freshness(now time.Time) (lifetime time.Duration, storable bool) {
+	cacheControl := parseCacheControl(e.header.Get("Cache-Control"))
+	if _, ok := cacheControl["no-store"]; ok {
+		return 0, false
+	}
+	// The cookie is private to the user, which should not be shared by cache.
+	if e.header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	if _, ok := cacheControl["no-cache"]; ok {
+		lifetime = 0
+	} else if v, ok := cacheControl["max-age"]; ok {
+		seconds, _ := strconv.ParseInt(v, 10, 64)
+		lifetime = time.Duration(seconds) * time.Second
+	} else if v := e.header.Get("Expires"); v != "" {
+		// The invalid Expires value like "0" means already expired.
+		if expires, err := http.ParseTime(v); err == nil {
+			date := now
+			if d, err := http.ParseTime(e.header.Get("Date")); err == nil {
+				date = d
+			}
+			lifetime = expires.Sub(date)
+		}
+	}
+	if age, err := strconv.ParseInt(e.header.Get("Age"), 10, 64); err == nil {
+		lifetime -= time.Duration(age) * time.Second
+	}
+	return lifetime, e.hasValidator() || lifetime > 0
+}
+
+// response creates and returns the response for <req> from the entry.
+func (e *clientCacheEntry) response(req *http.Request, cacheStatus string) *ClientResponse {
+	header := e.header.Clone()
+	header.Del("Age")
+	return &ClientResponse{
+		Response: &http.Response{
+			Status:        strconv.Itoa(e.status) + " " + http.StatusText(e.status),
+			StatusCode:    e.status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          utils.NewReadCloser(e.body, false),
+			ContentLength: int64(len(e.body)),
+			Request:       req,
+		},
+		request:     req,
+		cacheStatus: cacheStatus,
+	}
+}
+
+// isCacheableStatus checks whether the response of <status> can be cached.
+func isCacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// parseCacheControl parses the Cache-Control header value <value> to map,
+// like: "max-age=60, no-cache" to {"max-age": "60", "no-cache": ""}.
+func parseCacheControl(value string) map[string]string {
+	m := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		array := strings.SplitN(item, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(array[0]))
+		if len(array) == 2 {
+			m[name] = strings.Trim(strings.TrimSpace(array[1]), `"`)
+		} else {
+			m[name] = ""
+		}
+	}
+	return m
+}
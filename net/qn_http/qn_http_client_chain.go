@@ -133,5 +133,5 @@ func (c *Client) Retry(retryCount int, retryInterval time.Duration) *Client {
 		newClient = c.Clone()
 	}
 	newClient.SetRetry(retryCount, retryInterval)
-	return c
+	return newClient
 }
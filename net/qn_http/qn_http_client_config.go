@@ -8,8 +8,8 @@ package qn_http
 
 import (
 	"context"
-	"crypto/tls"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/qnsoft/common/text/qn_regex"
@@ -17,33 +17,31 @@ import (
 
 // Client is the HTTP client for HTTP request management.
 type Client struct {
-	http.Client                     // Underlying HTTP Client.
-	ctx           context.Context   // Context for each request.
-	parent        *Client           // Parent http client, this is used for chaining operations.
-	header        map[string]string // Custom header map.
-	cookies       map[string]string // Custom cookie map.
-	prefix        string            // Prefix for request.
-	authUser      string            // HTTP basic authentication: user.
-	authPass      string            // HTTP basic authentication: pass.
-	browserMode   bool              // Whether auto saving and sending cookie content.
-	retryCount    int               // Retry count when request fails.
-	retryInterval time.Duration     // Retry interval when request fails.
+	http.Client                           // Underlying HTTP Client.
+	ctx            context.Context        // Context for each request.
+	parent         *Client                // Parent http client, this is used for chaining operations.
+	header         map[string]string      // Custom header map.
+	cookies        map[string]string      // Custom cookie map.
+	prefix         string                 // Prefix for request.
+	authUser       string                 // HTTP basic authentication: user.
+	authPass       string                 // HTTP basic authentication: pass.
+	retryPolicy    *RetryPolicy           // Retry policy when request fails.
+	middleware     []ClientMiddlewareFunc // Middleware chain running around each request.
+	uploadProgress ClientProgressFunc     // Callback for the progress of sending request body.
+	ownTransport   *http.Transport        // Transport created by current client, which is not shared with others.
 }
 
 // NewClient creates and returns a new HTTP client object.
+// The transport of the client is in compatible mode in default, see SetClientSecureMode.
 func NewClient() *Client {
+	transport := newClientTransport()
 	return &Client{
 		Client: http.Client{
-			Transport: &http.Transport{
-				// No validation for https certification of the server in default.
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
-				DisableKeepAlives: true,
-			},
+			Transport: transport,
 		},
-		header:  make(map[string]string),
-		cookies: make(map[string]string),
+		header:       make(map[string]string),
+		cookies:      make(map[string]string),
+		ownTransport: transport,
 	}
 }
 
@@ -59,14 +57,32 @@ func (c *Client) Clone() *Client {
 	for k, v := range c.cookies {
 		newClient.cookies[k] = v
 	}
+	// The middleware array is copied, so that adding middleware to the cloned
+	// client does not affect current client.
+	newClient.middleware = append([]ClientMiddlewareFunc(nil), c.middleware...)
+	// The transport is shared with current client until it's configured.
+	newClient.ownTransport = nil
 	return newClient
 }
 
 // SetBrowserMode enables browser mode of the client.
 // When browser mode is enabled, it automatically saves and sends cookie content
-// from and to server.
+// from and to server using a new ClientCookieJar, if there's no cookie jar set.
+//
+// Note that the cookie jar is shared between the client and its cloned clients.
 func (c *Client) SetBrowserMode(enabled bool) *Client {
-	c.browserMode = enabled
+	if !enabled {
+		c.Jar = nil
+	} else if c.Jar == nil {
+		c.Jar = NewClientCookieJar()
+	}
+	return c
+}
+
+// SetCookieJar sets the cookie jar of the client, which also enables the browser mode.
+// The <jar> can be a ClientCookieJar loaded from file for resuming the sessions.
+func (c *Client) SetCookieJar(jar http.CookieJar) *Client {
+	c.Jar = jar
 	return c
 }
 
@@ -140,9 +156,14 @@ func (c *Client) SetCtx(ctx context.Context) *Client {
 	return c
 }
 
-// SetRetry sets retry count and interval.
+// SetRetry sets retry count and fixed interval, which retries any request on transport errors.
+// Use SetRetryPolicy for more retry features.
 func (c *Client) SetRetry(retryCount int, retryInterval time.Duration) *Client {
-	c.retryCount = retryCount
-	c.retryInterval = retryInterval
-	return c
+	return c.SetRetryPolicy(RetryPolicy{
+		Count:       retryCount,
+		Interval:    retryInterval,
+		Multiplier:  1,
+		StatusCodes: []int{},
+		Methods:     strings.Split(HTTP_METHODS, ","),
+	})
 }
@@ -0,0 +1,156 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qnsoft/common/internal/json"
+	"github.com/qnsoft/common/os/qn_file"
+)
+
+// ClientCookieJar is the cookie jar for the client in browser mode, which manages the cookies
+// following RFC 6265, respecting the domain, path, Secure attribute and expiry of the cookies.
+// It can be saved to and loaded from file, so that the client can resume its sessions.
+type ClientCookieJar struct {
+	mu      sync.RWMutex
+	jar     *cookiejar.Jar
+	entries map[string]*clientCookieJarEntry // Cookies for persistence, the key is "domain;path;name".
+}
+
+// clientCookieJarEntry is the cookie item for persistence.
+type clientCookieJarEntry struct {
+	Url    string       `json:"url"`    // URL from which the cookie is received.
+	Cookie *http.Cookie `json:"cookie"` // Cookie with absolute expiry time.
+}
+
+// NewClientCookieJar creates and returns an empty cookie jar.
+func NewClientCookieJar() *ClientCookieJar {
+	// It never returns error with nil options.
+	jar, _ := cookiejar.New(nil)
+	return &ClientCookieJar{
+		jar:     jar,
+		entries: make(map[string]*clientCookieJarEntry),
+	}
+}
+
+// SetCookies implements the http.CookieJar interface,
+// which handles the receipt of the <cookies> in a reply for the given <u>.
+func (j *ClientCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.setCookies(u, cookies, time.Now())
+}
+
+// Cookies implements the http.CookieJar interface,
+// which returns the cookies to send in a request for the given <u>.
+func (j *ClientCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.jar.Cookies(u)
+}
+
+// Clear removes all cookies from the jar.
+func (j *ClientCookieJar) Clear() {
+	jar, _ := cookiejar.New(nil)
+	j.mu.Lock()
+	j.jar = jar
+	j.entries = make(map[string]*clientCookieJarEntry)
+	j.mu.Unlock()
+}
+
+// Save saves the unexpired cookies of the jar to file <path> in JSON format.
+// Note that the session cookies without expiry are also saved.
+func (j *ClientCookieJar) Save(path string) error {
+	j.mu.RLock()
+	var (
+		now     = time.Now()
+		entries = make([]*clientCookieJarEntry, 0, len(j.entries))
+	)
+	for _, entry := range j.entries {
+		if entry.Cookie.Expires.IsZero() || entry.Cookie.Expires.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+	j.mu.RUnlock()
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return qn_file.PutBytes(path, b)
+}
+
+// Load loads the cookies from file <path> which is saved by Save, and merges them into the jar.
+// It does nothing if the file does not exist.
+func (j *ClientCookieJar) Load(path string) error {
+	if !qn_file.Exists(path) {
+		return nil
+	}
+	entries := make([]*clientCookieJarEntry, 0)
+	if err := json.Unmarshal(qn_file.GetBytes(path), &entries); err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.Cookie == nil {
+			continue
+		}
+		u, err := url.Parse(entry.Url)
+		if err != nil {
+			return err
+		}
+		j.setCookies(u, []*http.Cookie{entry.Cookie}, now)
+	}
+	return nil
+}
+
+// setCookies sets the <cookies> to the jar and records them for persistence.
+// The relative expiry Max-Age of the cookies is converted to absolute time for persistence.
+func (j *ClientCookieJar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
+	j.jar.SetCookies(u, cookies)
+	for _, cookie := range cookies {
+		c := *cookie
+		if c.MaxAge > 0 {
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		key := j.entryKey(u, &c)
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && !c.Expires.After(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = &clientCookieJarEntry{
+			Url:    u.Scheme + "://" + u.Host + u.Path,
+			Cookie: &c,
+		}
+	}
+}
+
+// entryKey returns the persistence key of <cookie> received from <u>,
+// which identifies the cookie by its domain, path and name like the jar.
+func (j *ClientCookieJar) entryKey(u *url.URL, cookie *http.Cookie) string {
+	domain := strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	path := cookie.Path
+	if path == "" || path[0] != '/' {
+		// Default path of the cookie, see RFC 6265 section 5.1.4.
+		path = "/"
+		if i := strings.LastIndex(u.Path, "/"); i > 0 {
+			path = u.Path[:i]
+		}
+	}
+	return domain + ";" + path + ";" + cookie.Name
+}
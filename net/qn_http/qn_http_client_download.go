@@ -0,0 +1,441 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/os/qn_file"
+)
+
+// DownloadOptions is the options for Client.Download.
+type DownloadOptions struct {
+	Parallel int                // Number of parallel range segments, it downloads in single stream if it's less than 2.
+	Resume   bool               // Whether resumes the interrupted downloading from the temporary files.
+	Checksum string             // Expected checksum of the file in format "algorithm:hex", like: md5:xxx, sha256:xxx.
+	Progress ClientProgressFunc // Callback for the downloading progress, which can be called concurrently.
+}
+
+// downloadProgress is the progress counter of downloading.
+type downloadProgress struct {
+	transferred *qn_type.Int64
+	total       int64
+	progress    ClientProgressFunc
+}
+
+// downloadWriter wraps the writer of the downloading file, which updates the progress on each writing.
+type downloadWriter struct {
+	io.Writer
+	progress *downloadProgress
+}
+
+const (
+	gDOWNLOAD_TEMP_SUFFIX      = ".download"  // Suffix of the temporary file of downloading.
+	gDOWNLOAD_VALIDATOR_SUFFIX = ".validator" // Suffix of the file storing the validator of the temporary file.
+)
+
+// Download downloads the content of <url> to local file <path> in streaming, without holding
+// the content in memory.
+//
+// The content is written to the temporary file "<path>.download" firstly, which is renamed to
+// <path> after it's completely downloaded and verified. If it's downloaded in parallel, each
+// segment uses its own temporary file "<path>.download.<index>" which is merged at last.
+// The temporary files are kept if it fails, so that it can be resumed using option Resume.
+//
+// The strong ETag or Last-Modified of the content is stored in "<path>.download.validator",
+// which is sent in header If-Range for resuming, so that the changed content is downloaded
+// from the beginning instead of being appended to the old content.
+func (c *Client) Download(url, path string, options ...DownloadOptions) (err error) {
+	var option DownloadOptions
+	if len(options) > 0 {
+		option = options[0]
+	}
+	if option.Checksum != "" {
+		if _, _, err = newChecksumHash(option.Checksum); err != nil {
+			return err
+		}
+	}
+	var (
+		tempPath      = path + gDOWNLOAD_TEMP_SUFFIX
+		validatorPath = tempPath + gDOWNLOAD_VALIDATOR_SUFFIX
+		progress      = &downloadProgress{
+			transferred: qn_type.NewInt64(),
+			total:       -1,
+			progress:    option.Progress,
+		}
+	)
+	if dir := qn_file.Dir(path); !qn_file.Exists(dir) {
+		if err = qn_file.Mkdir(dir); err != nil {
+			return err
+		}
+	}
+	if option.Parallel > 1 {
+		var (
+			size      int64
+			ranged    bool
+			validator string
+		)
+		if size, ranged, validator, err = c.downloadProbe(url); err != nil {
+			return err
+		}
+		if ranged && size > 0 {
+			err = c.downloadParallel(url, tempPath, size, validator, option, progress)
+		} else {
+			err = c.downloadSingle(url, tempPath, option.Resume, progress)
+		}
+		if err != nil {
+			return err
+		}
+	} else if err = c.downloadSingle(url, tempPath, option.Resume, progress); err != nil {
+		return err
+	}
+	if option.Checksum != "" {
+		if err = checkFileChecksum(tempPath, option.Checksum); err != nil {
+			// The temporary file is broken, which cannot be resumed.
+			qn_file.Remove(tempPath)
+			qn_file.Remove(validatorPath)
+			return err
+		}
+	}
+	if err = qn_file.Rename(tempPath, path); err != nil {
+		return err
+	}
+	if qn_file.Exists(validatorPath) {
+		return qn_file.Remove(validatorPath)
+	}
+	return nil
+}
+
+// downloadProbe sends a range request for the first byte of <url>, and returns the content
+// size, whether the server supports range request and the validator of the content.
+// The returned size is -1 if it's unknown.
+func (c *Client) downloadProbe(url string) (size int64, ranged bool, validator string, err error) {
+	client := c.Clone()
+	client.SetHeader("Range", "bytes=0-0")
+	resp, err := client.Get(url)
+	if err != nil {
+		return -1, false, "", err
+	}
+	defer resp.Close()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		// Content-Range: bytes 0-0/1024
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total >= 0 {
+			return total, true, downloadValidator(resp.Header), nil
+		}
+		return -1, false, "", nil
+
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp.ContentLength, false, downloadValidator(resp.Header), nil
+
+	default:
+		return -1, false, "", resp.newClientError(resp.ReadAll())
+	}
+}
+
+// downloadSingle downloads the content of <url> to <tempPath> in single stream.
+func (c *Client) downloadSingle(url, tempPath string, resume bool, progress *downloadProgress) error {
+	var (
+		offset        int64
+		client        = c.Clone()
+		validatorPath = tempPath + gDOWNLOAD_VALIDATOR_SUFFIX
+		validator     = ""
+	)
+	if resume && qn_file.Exists(tempPath) {
+		offset = qn_file.Size(tempPath)
+		validator = qn_file.GetContents(validatorPath)
+	}
+	// The content without validator cannot be resumed safely, as it might be changed.
+	if offset > 0 && validator != "" {
+		client.SetHeader("Range", fmt.Sprintf("bytes=%d-", offset))
+		client.SetHeader("If-Range", validator)
+	} else {
+		offset = 0
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+	var (
+		flag  = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		total = resp.ContentLength
+	)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, rangeTotal, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return errors.New(fmt.Sprintf(`invalid Content-Range "%s" for offset %d`, resp.Header.Get("Content-Range"), offset))
+		}
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		total = rangeTotal
+		if total < 0 && resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The temporary file is already completely downloaded if its size is the total size.
+		if _, rangeTotal, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && rangeTotal == offset {
+			return nil
+		}
+		if offset > 0 {
+			// The content is changed or truncated, it downloads from the beginning.
+			resp.Close()
+			qn_file.Remove(tempPath)
+			qn_file.Remove(validatorPath)
+			return c.downloadSingle(url, tempPath, false, progress)
+		}
+		return resp.newClientError(resp.ReadAll())
+
+	default:
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return resp.newClientError(resp.ReadAll())
+		}
+		// The server does not support range request or the content is changed,
+		// it downloads from the beginning.
+		offset = 0
+	}
+	if err = saveDownloadValidator(validatorPath, downloadValidator(resp.Header)); err != nil {
+		return err
+	}
+	progress.total = total
+	progress.transferred.Set(offset)
+	file, err := qn_file.OpenWithFlagPerm(tempPath, flag, qn_file.DefaultPermOpen)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	n, err := io.Copy(&downloadWriter{Writer: file, progress: progress}, resp.Body)
+	if err != nil {
+		return err
+	}
+	if total >= 0 && offset+n != total {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// downloadParallel downloads the content of <url> in <size> to <tempPath> with parallel range segments.
+// The parameter <validator> is the validator of the content, which is sent in header If-Range.
+func (c *Client) downloadParallel(url, tempPath string, size int64, validator string, option DownloadOptions, progress *downloadProgress) error {
+	var (
+		wg            = sync.WaitGroup{}
+		errMu         = sync.Mutex{}
+		firstErr      error
+		partPaths     = make([]string, 0, option.Parallel)
+		segment       = (size + int64(option.Parallel) - 1) / int64(option.Parallel)
+		validatorPath = tempPath + gDOWNLOAD_VALIDATOR_SUFFIX
+	)
+	// The segments can be resumed only if the content is not changed.
+	resume := option.Resume && validator != "" && qn_file.GetContents(validatorPath) == validator
+	if err := saveDownloadValidator(validatorPath, validator); err != nil {
+		return err
+	}
+	progress.total = size
+	for i := 0; i < option.Parallel; i++ {
+		start := int64(i) * segment
+		if start >= size {
+			break
+		}
+		end := start + segment - 1
+		if end >= size {
+			end = size - 1
+		}
+		partPath := fmt.Sprintf("%s.%d", tempPath, i)
+		partPaths = append(partPaths, partPath)
+		if !resume {
+			qn_file.Remove(partPath)
+		}
+		// The downloaded size of the resumed segment.
+		if qn_file.Exists(partPath) {
+			progress.transferred.Add(qn_file.Size(partPath))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.downloadRange(url, partPath, start, end, validator, progress); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	// Merging the segments into the temporary file.
+	file, err := qn_file.OpenWithFlagPerm(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, qn_file.DefaultPermOpen)
+	if err != nil {
+		return err
+	}
+	for _, partPath := range partPaths {
+		if err = copyFile(file, partPath); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	// It's closed before renaming and checksum verifying.
+	if err = file.Close(); err != nil {
+		return err
+	}
+	for _, partPath := range partPaths {
+		qn_file.Remove(partPath)
+	}
+	return nil
+}
+
+// downloadRange downloads the range from <start> to <end> of <url> to <partPath>,
+// which resumes from the existing content of <partPath>.
+func (c *Client) downloadRange(url, partPath string, start, end int64, validator string, progress *downloadProgress) error {
+	var offset int64
+	if qn_file.Exists(partPath) {
+		offset = qn_file.Size(partPath)
+	}
+	if start+offset > end {
+		return nil
+	}
+	client := c.Clone()
+	client.SetHeader("Range", fmt.Sprintf("bytes=%d-%d", start+offset, end))
+	if validator != "" {
+		client.SetHeader("If-Range", validator)
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return resp.newClientError(resp.ReadAll())
+		}
+		// The full content is responded if the validator does not match.
+		return errors.New(fmt.Sprintf(`content of "%s" is changed or range request is not supported`, url))
+	}
+	if rangeStart, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || rangeStart != start+offset {
+		return errors.New(fmt.Sprintf(`invalid Content-Range "%s" for offset %d`, resp.Header.Get("Content-Range"), start+offset))
+	}
+	file, err := qn_file.OpenWithFlagPerm(partPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, qn_file.DefaultPermOpen)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	n, err := io.Copy(&downloadWriter{Writer: file, progress: progress}, resp.Body)
+	if err != nil {
+		return err
+	}
+	if n != end-start-offset+1 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// downloadValidator returns the validator of the content for header If-Range,
+// which is the strong ETag or Last-Modified of <header>.
+func downloadValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// saveDownloadValidator stores <validator> to file <path>, or removes the file if it's empty.
+func saveDownloadValidator(path, validator string) error {
+	if validator == "" {
+		if qn_file.Exists(path) {
+			return qn_file.Remove(path)
+		}
+		return nil
+	}
+	return qn_file.PutContents(path, validator)
+}
+
+// parseContentRange parses the value of header Content-Range, like "bytes 0-99/1024" or
+// "bytes */1024", and returns the start offset and total size. The returned start is -1 for
+// unsatisfied range, and the total is -1 if it's unknown.
+func parseContentRange(value string) (start, total int64, ok bool) {
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, false
+	}
+	value = strings.TrimSpace(value[len("bytes "):])
+	pos := strings.LastIndex(value, "/")
+	if pos == -1 {
+		return 0, 0, false
+	}
+	var err error
+	if value[pos+1:] == "*" {
+		total = -1
+	} else if total, err = strconv.ParseInt(value[pos+1:], 10, 64); err != nil {
+		return 0, 0, false
+	}
+	if value[:pos] == "*" {
+		return -1, total, true
+	}
+	array := strings.SplitN(value[:pos], "-", 2)
+	if start, err = strconv.ParseInt(array[0], 10, 64); err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// Write implements the io.Writer interface.
+func (w *downloadWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	if n > 0 {
+		transferred := w.progress.transferred.Add(int64(n))
+		if w.progress.progress != nil {
+			w.progress.progress(transferred, w.progress.total)
+		}
+	}
+	return
+}
+
+// newChecksumHash creates and returns the hash and expected hex value of <checksum>,
+// which is in format "algorithm:hex".
+func newChecksumHash(checksum string) (hash.Hash, string, error) {
+	array := strings.SplitN(checksum, ":", 2)
+	if len(array) != 2 {
+		return nil, "", errors.New(fmt.Sprintf(`invalid checksum "%s"`, checksum))
+	}
+	switch strings.ToLower(array[0]) {
+	case "md5":
+		return md5.New(), array[1], nil
+	case "sha256":
+		return sha256.New(), array[1], nil
+	default:
+		return nil, "", errors.New(fmt.Sprintf(`unsupported checksum algorithm "%s"`, array[0]))
+	}
+}
+
+// checkFileChecksum checks whether the content of file <path> matches <checksum>.
+func checkFileChecksum(path, checksum string) error {
+	h, expected, err := newChecksumHash(checksum)
+	if err != nil {
+		return err
+	}
+	if err = copyFile(h, path); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, expected) {
+		return errors.New(fmt.Sprintf(`checksum mismatch, expected "%s" but got "%s"`, expected, actual))
+	}
+	return nil
+}
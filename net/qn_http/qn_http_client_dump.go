@@ -34,17 +34,10 @@ func getResponseBody(res *http.Response) string {
 	return qn_conv.UnsafeBytesToStr(bodyContent)
 }
 
-// RawRequest returns the raw content of the request.
-func (r *ClientResponse) RawRequest() string {
-	// ClientResponse can be nil.
-	if r == nil {
-		return ""
-	}
-	if r.request == nil {
-		return ""
-	}
+// dumpRequest returns the raw content of the request <req> with its <body>.
+func dumpRequest(req *http.Request, body []byte) string {
 	// DumpRequestOut writes more request headers than DumpRequest, such as User-Agent.
-	bs, err := httputil.DumpRequestOut(r.request, false)
+	bs, err := httputil.DumpRequestOut(req, false)
 	if err != nil {
 		return ""
 	}
@@ -52,29 +45,46 @@ func (r *ClientResponse) RawRequest() string {
 		dumpTextFormat,
 		"REQUEST",
 		qn_conv.UnsafeBytesToStr(bs),
-		r.requestBody,
+		body,
 	)
 }
 
-// RawResponse returns the raw content of the response.
-func (r *ClientResponse) RawResponse() string {
-	// ClientResponse can be nil.
-	if r == nil || r.Response == nil {
-		return ""
-	}
-	bs, err := httputil.DumpResponse(r.Response, false)
+// dumpResponse returns the raw content of the response <res>.
+// The body of <res> can be read again after dumping.
+func dumpResponse(res *http.Response) string {
+	bs, err := httputil.DumpResponse(res, false)
 	if err != nil {
 		return ""
 	}
-
 	return fmt.Sprintf(
 		dumpTextFormat,
 		"RESPONSE",
 		qn_conv.UnsafeBytesToStr(bs),
-		getResponseBody(r.Response),
+		getResponseBody(res),
 	)
 }
 
+// RawRequest returns the raw content of the request.
+func (r *ClientResponse) RawRequest() string {
+	// ClientResponse can be nil.
+	if r == nil {
+		return ""
+	}
+	if r.request == nil {
+		return ""
+	}
+	return dumpRequest(r.request, r.requestBody)
+}
+
+// RawResponse returns the raw content of the response.
+func (r *ClientResponse) RawResponse() string {
+	// ClientResponse can be nil.
+	if r == nil || r.Response == nil {
+		return ""
+	}
+	return dumpResponse(r.Response)
+}
+
 // Raw returns the raw text of the request and the response.
 func (r *ClientResponse) Raw() string {
 	return fmt.Sprintf("%s\n%s", r.RawRequest(), r.RawResponse())
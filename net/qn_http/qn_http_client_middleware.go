@@ -0,0 +1,46 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"net/http"
+)
+
+// ClientHandlerFunc is the handler sending the request and returning the response,
+// which is the <next> handler for client middleware.
+type ClientHandlerFunc = func(req *http.Request) (*ClientResponse, error)
+
+// ClientMiddlewareFunc is the client middleware function, which runs around each request.
+// It can modify the request before calling <next>, or handle the response after <next> returns.
+// It can also return its own response without calling <next>.
+type ClientMiddlewareFunc = func(c *Client, req *http.Request, next ClientHandlerFunc) (*ClientResponse, error)
+
+// Use adds one or more middleware to the client, which are called in their adding order.
+// The middleware is inherited by the cloned client and the chaining functions like Prefix/Header.
+//
+// The request passed to the middleware is completely prepared, with the custom header,
+// cookie and basic authentication set, so that it's suitable for request signing.
+func (c *Client) Use(handlers ...ClientMiddlewareFunc) *Client {
+	c.middleware = append(c.middleware, handlers...)
+	return c
+}
+
+// callRequest sends the request <req> through the middleware chain of the client.
+func (c *Client) callRequest(req *http.Request) (*ClientResponse, error) {
+	handler := ClientHandlerFunc(c.doRequest)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		handler = c.wrapMiddleware(c.middleware[i], handler)
+	}
+	return handler(req)
+}
+
+// wrapMiddleware wraps middleware <md> and its <next> handler as a handler.
+func (c *Client) wrapMiddleware(md ClientMiddlewareFunc, next ClientHandlerFunc) ClientHandlerFunc {
+	return func(req *http.Request) (*ClientResponse, error) {
+		return md(c, req, next)
+	}
+}
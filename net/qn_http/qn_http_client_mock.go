@@ -0,0 +1,240 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/qnsoft/common/internal/json"
+	"github.com/qnsoft/common/internal/utils"
+	"github.com/qnsoft/common/text/qn_regex"
+	"github.com/qnsoft/common/util/qn_conv"
+)
+
+// MockTransport is the transport for testing, which returns the canned responses for the
+// registered requests without sending them to the server. It can be injected into the client
+// using Client.SetTransport.
+//
+// The request not matching any registered item is passed to the fallback transport if it's set,
+// or else it returns an error.
+type MockTransport struct {
+	mu       sync.RWMutex
+	items    []*MockItem
+	fallback http.RoundTripper
+}
+
+// MockItem is the registered request and its canned response of MockTransport.
+type MockItem struct {
+	mu          sync.RWMutex
+	method      string                 // Request method, it matches any method if empty.
+	pattern     string                 // URL pattern.
+	regex       *regexp.Regexp         // Compiled URL pattern.
+	bodyMatcher func(body []byte) bool // Custom matcher for request body.
+	status      int                    // Response status code.
+	header      http.Header            // Response header.
+	body        []byte                 // Response body.
+	err         error                  // Error returned instead of response.
+	times       int                    // Max matching times, it's unlimited if it's 0.
+	called      int                    // Matched times.
+}
+
+// NewMockTransport creates and returns a mock transport.
+// The optional parameter <fallback> specifies the transport for the unmatched requests.
+func NewMockTransport(fallback ...http.RoundTripper) *MockTransport {
+	m := &MockTransport{
+		items: make([]*MockItem, 0),
+	}
+	if len(fallback) > 0 {
+		m.fallback = fallback[0]
+	}
+	return m
+}
+
+// On registers and returns a mock item for requests of <method> and <urlPattern>.
+// The <method> can be empty which matches any method. The <urlPattern> is matched with the
+// full URL, in which the "*" matches any characters, like: http://127.0.0.1/user/*.
+//
+// The items are matched in their registering order, and it replies status 200 with
+// empty body in default.
+func (m *MockTransport) On(method, urlPattern string) *MockItem {
+	item := &MockItem{
+		method:  strings.ToUpper(method),
+		pattern: urlPattern,
+		regex: regexp.MustCompile(
+			"^" + strings.Replace(qn_regex.Quote(urlPattern), `\*`, ".*", -1) + "$",
+		),
+		status: http.StatusOK,
+		header: make(http.Header),
+	}
+	m.mu.Lock()
+	m.items = append(m.items, item)
+	m.mu.Unlock()
+	return item
+}
+
+// Reset removes all the registered items.
+func (m *MockTransport) Reset() {
+	m.mu.Lock()
+	m.items = make([]*MockItem, 0)
+	m.mu.Unlock()
+}
+
+// Pending returns the patterns of the items which are expected to be matched specified
+// times by MockItem.Times but not yet, which can be used for asserting in testing.
+func (m *MockTransport) Pending() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pending := make([]string, 0)
+	for _, item := range m.items {
+		if item.times > 0 && item.Called() < item.times {
+			pending = append(pending, strings.TrimSpace(item.method+" "+item.pattern))
+		}
+	}
+	return pending
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = utils.NewReadCloser(body, false)
+	}
+	m.mu.RLock()
+	items := m.items
+	m.mu.RUnlock()
+	for _, item := range items {
+		if item.match(req, body) {
+			return item.response(req)
+		}
+	}
+	if m.fallback != nil {
+		return m.fallback.RoundTrip(req)
+	}
+	return nil, errors.New(fmt.Sprintf(`no mock matches request: %s %s`, req.Method, req.URL.String()))
+}
+
+// WithBody sets the custom matcher for the request body.
+func (i *MockItem) WithBody(matcher func(body []byte) bool) *MockItem {
+	i.bodyMatcher = matcher
+	return i
+}
+
+// WithBodyContains sets the item matching the request whose body contains <s>.
+func (i *MockItem) WithBodyContains(s string) *MockItem {
+	return i.WithBody(func(body []byte) bool {
+		return strings.Contains(qn_conv.UnsafeBytesToStr(body), s)
+	})
+}
+
+// Reply sets the status code and body of the canned response.
+// The <body> can be string/[]byte, or else it's encoded as JSON.
+func (i *MockItem) Reply(status int, body ...interface{}) *MockItem {
+	i.status = status
+	if len(body) > 0 {
+		switch v := body[0].(type) {
+		case string, []byte:
+			i.body = qn_conv.Bytes(v)
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				panic(err)
+			}
+			i.body = b
+			if i.header.Get("Content-Type") == "" {
+				i.header.Set("Content-Type", "application/json")
+			}
+		}
+	}
+	return i
+}
+
+// ReplyHeader sets a header of the canned response.
+func (i *MockItem) ReplyHeader(key, value string) *MockItem {
+	i.header.Set(key, value)
+	return i
+}
+
+// ReplyError sets the item returning error <err> instead of response,
+// which simulates the network failure.
+func (i *MockItem) ReplyError(err error) *MockItem {
+	i.err = err
+	return i
+}
+
+// Times sets the max matching times of the item, after which the item never matches.
+func (i *MockItem) Times(n int) *MockItem {
+	i.times = n
+	return i
+}
+
+// Called returns the matched times of the item.
+func (i *MockItem) Called() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.called
+}
+
+// match checks whether the item matches the request <req> with <body>,
+// and increases the matched times if it matches.
+func (i *MockItem) match(req *http.Request, body []byte) bool {
+	if i.method != "" && i.method != req.Method {
+		return false
+	}
+	if !i.regex.MatchString(req.URL.String()) {
+		return false
+	}
+	if i.bodyMatcher != nil && !i.bodyMatcher(body) {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.times > 0 && i.called >= i.times {
+		return false
+	}
+	i.called++
+	return true
+}
+
+// response creates and returns the canned response for <req>.
+func (i *MockItem) response(req *http.Request) (*http.Response, error) {
+	if i.err != nil {
+		return nil, i.err
+	}
+	header := make(http.Header, len(i.header))
+	for k, v := range i.header {
+		header[k] = append([]string(nil), v...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.status, http.StatusText(i.status)),
+		StatusCode:    i.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          utils.NewReadCloser(i.body, false),
+		ContentLength: int64(len(i.body)),
+		Request:       req,
+	}, nil
+}
+
+// SetTransport sets the underlying transport of the client, like MockTransport or ClientRecorder.
+//
+// Note that the transport which is not *http.Transport is not configured by the client,
+// the chaining configuration functions like SetTLSConfig take no effect on it, and the ones
+// returning error like SetProxy return error.
+func (c *Client) SetTransport(transport http.RoundTripper) *Client {
+	c.Client.Transport = transport
+	return c
+}
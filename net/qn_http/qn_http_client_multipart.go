@@ -0,0 +1,199 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/qnsoft/common/os/qn_file"
+)
+
+// Multipart is the builder for "multipart/form-data" request body, which is streamed
+// to the server part by part, so that the uploading files are never loaded into memory.
+// It's passed as the request data to the client, like: client.Post(url, multipart).
+//
+// Note that the readers added by AddReader/AddPart can be consumed only once,
+// and the request with multipart body is never retried.
+type Multipart struct {
+	boundary string          // Custom boundary, it's randomly generated if empty.
+	parts    []multipartPart // Parts in adding order.
+}
+
+// multipartPart is one part of the multipart body.
+type multipartPart struct {
+	header textproto.MIMEHeader // Part header.
+	value  string               // Form field value.
+	path   string               // Local file path, which is opened when it's being streamed.
+	reader io.Reader            // Part content.
+}
+
+// multipartBody is the streaming body of the multipart, which implements io.ReadCloser.
+// The writing goroutine is started when it's read for the first time, so there's no
+// goroutine leak if the body is never read.
+type multipartBody struct {
+	once   sync.Once
+	m      *Multipart
+	writer *multipart.Writer
+	pr     *io.PipeReader
+	pw     *io.PipeWriter
+}
+
+var (
+	// quoteEscaper is used for escaping the field name and file name in part header.
+	quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+)
+
+// NewMultipart creates and returns an empty multipart body builder.
+func NewMultipart() *Multipart {
+	return &Multipart{
+		parts: make([]multipartPart, 0),
+	}
+}
+
+// SetBoundary sets custom boundary of the multipart body.
+func (m *Multipart) SetBoundary(boundary string) *Multipart {
+	m.boundary = boundary
+	return m
+}
+
+// AddField adds a form field <name> with <value>.
+// The <value> is sent as it is, even it starts with "@file:".
+func (m *Multipart) AddField(name, value string) *Multipart {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(name)))
+	m.parts = append(m.parts, multipartPart{
+		header: header,
+		value:  value,
+	})
+	return m
+}
+
+// AddFile adds a file field <name> with content of local file <path>.
+// The file is opened and read only when the body is being sent.
+func (m *Multipart) AddFile(name, path string) *Multipart {
+	m.parts = append(m.parts, multipartPart{
+		header: newMultipartFileHeader(name, filepath.Base(path)),
+		path:   path,
+	})
+	return m
+}
+
+// AddReader adds a file field <name> with <fileName> and content from <reader>.
+func (m *Multipart) AddReader(name, fileName string, reader io.Reader) *Multipart {
+	m.parts = append(m.parts, multipartPart{
+		header: newMultipartFileHeader(name, fileName),
+		reader: reader,
+	})
+	return m
+}
+
+// AddPart adds a part with custom <header> and content from <reader>,
+// which is used for the part needs custom Content-Type or other headers.
+func (m *Multipart) AddPart(header textproto.MIMEHeader, reader io.Reader) *Multipart {
+	m.parts = append(m.parts, multipartPart{
+		header: header,
+		reader: reader,
+	})
+	return m
+}
+
+// check checks whether the local files of the parts exist.
+func (m *Multipart) check() error {
+	for _, part := range m.parts {
+		if part.path != "" && !qn_file.Exists(part.path) {
+			return errors.New(fmt.Sprintf(`"%s" does not exist`, part.path))
+		}
+	}
+	return nil
+}
+
+// Body creates and returns the streaming body and its Content-Type.
+// Each call of Body creates a new body.
+func (m *Multipart) Body() (io.ReadCloser, string, error) {
+	pr, pw := io.Pipe()
+	body := &multipartBody{
+		m:      m,
+		writer: multipart.NewWriter(pw),
+		pr:     pr,
+		pw:     pw,
+	}
+	if m.boundary != "" {
+		if err := body.writer.SetBoundary(m.boundary); err != nil {
+			return nil, "", err
+		}
+	}
+	return body, body.writer.FormDataContentType(), nil
+}
+
+// Read implements the io.Reader interface.
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		go func() {
+			b.pw.CloseWithError(b.write())
+		}()
+	})
+	return b.pr.Read(p)
+}
+
+// Close implements the io.Closer interface, which stops the writing goroutine.
+func (b *multipartBody) Close() error {
+	return b.pr.Close()
+}
+
+// write writes all the parts to the pipe.
+func (b *multipartBody) write() error {
+	for _, part := range b.m.parts {
+		w, err := b.writer.CreatePart(part.header)
+		if err != nil {
+			return err
+		}
+		if part.path != "" {
+			if err = copyFile(w, part.path); err != nil {
+				return err
+			}
+		} else if part.reader != nil {
+			if _, err = io.Copy(w, part.reader); err != nil {
+				return err
+			}
+		} else if _, err = io.WriteString(w, part.value); err != nil {
+			return err
+		}
+	}
+	// Close finishes the multipart message and writes the trailing
+	// boundary end line to the output.
+	return b.writer.Close()
+}
+
+// copyFile copies content of local file <path> to <w>.
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// newMultipartFileHeader creates and returns the part header for file field.
+func newMultipartFileHeader(name, fileName string) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(
+		`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(name), quoteEscaper.Replace(fileName),
+	))
+	header.Set("Content-Type", "application/octet-stream")
+	return header
+}
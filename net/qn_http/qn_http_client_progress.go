@@ -0,0 +1,62 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"io"
+)
+
+// ClientProgressFunc is the callback for the progress of uploading or downloading.
+// The <total> is -1 if the size is unknown, like streaming body.
+type ClientProgressFunc = func(transferred, total int64)
+
+// progressReadCloser wraps the request body, which calls the progress callback on each reading.
+type progressReadCloser struct {
+	io.ReadCloser
+	sent     int64
+	total    int64
+	progress ClientProgressFunc
+}
+
+// SetUploadProgress sets the callback for the progress of sending request body.
+func (c *Client) SetUploadProgress(progress ClientProgressFunc) *Client {
+	c.uploadProgress = progress
+	return c
+}
+
+// UploadProgress is a chaining function,
+// which sets the callback for the progress of sending request body for next request.
+func (c *Client) UploadProgress(progress ClientProgressFunc) *Client {
+	newClient := c
+	if c.parent == nil {
+		newClient = c.Clone()
+	}
+	newClient.SetUploadProgress(progress)
+	return newClient
+}
+
+// newProgressReadCloser creates and returns a progress reader wrapping <body>.
+func newProgressReadCloser(body io.ReadCloser, total int64, progress ClientProgressFunc) *progressReadCloser {
+	if total <= 0 {
+		total = -1
+	}
+	return &progressReadCloser{
+		ReadCloser: body,
+		total:      total,
+		progress:   progress,
+	}
+}
+
+// Read implements the io.Reader interface.
+func (r *progressReadCloser) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	if n > 0 {
+		r.sent += int64(n)
+		r.progress(r.sent, r.total)
+	}
+	return
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/qnsoft/common/internal/utils"
+	"github.com/qnsoft/common/os/qn_file"
+)
+
+const (
+	RECORDER_MODE_AUTO   = iota // Replays the exchange if its fixture file exists, or else records it.
+	RECORDER_MODE_RECORD        // Always sends the request and records the exchange.
+	RECORDER_MODE_REPLAY        // Always replays the exchange from fixture file, and never sends the request.
+)
+
+// ClientRecorder is the transport recording the real HTTP exchanges to fixture files,
+// and replaying them offline, which can be injected into the client using Client.SetTransport.
+//
+// The fixture file is named by the request method, URL and body, and its content is the
+// raw text of the request and the response, same as ClientResponse.Raw. Note that the
+// request body should be deterministic for replaying, like the boundary of Multipart.
+type ClientRecorder struct {
+	dir       string            // Directory path storing the fixture files.
+	mode      int               // Recorder mode.
+	transport http.RoundTripper // Transport for sending the real requests.
+}
+
+// NewClientRecorder creates and returns a recorder storing fixture files in <dir>.
+// The optional parameter <transport> specifies the transport for sending the real requests.
+func NewClientRecorder(dir string, mode int, transport ...http.RoundTripper) *ClientRecorder {
+	r := &ClientRecorder{
+		dir:  dir,
+		mode: mode,
+	}
+	if len(transport) > 0 {
+		r.transport = transport[0]
+	} else {
+		r.transport = newClientTransport()
+	}
+	return r
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *ClientRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = utils.NewReadCloser(body, false)
+	}
+	path := r.fixturePath(req, body)
+	switch r.mode {
+	case RECORDER_MODE_REPLAY:
+		return r.replay(req, path)
+	case RECORDER_MODE_AUTO:
+		if qn_file.Exists(path) {
+			return r.replay(req, path)
+		}
+	}
+	return r.record(req, body, path)
+}
+
+// record sends the request <req> and saves the exchange to fixture file <path>.
+func (r *ClientRecorder) record(req *http.Request, body []byte, path string) (*http.Response, error) {
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	content := fmt.Sprintf("%s\n%s", dumpRequest(req, body), dumpResponse(res))
+	if err = qn_file.PutContents(path, content); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// replay reads fixture file <path> and returns the recorded response for <req>.
+func (r *ClientRecorder) replay(req *http.Request, path string) (*http.Response, error) {
+	if !qn_file.Exists(path) {
+		return nil, errors.New(fmt.Sprintf(
+			`fixture file "%s" not found for request: %s %s`, path, req.Method, req.URL.String(),
+		))
+	}
+	// The fixture is in format of dumpTextFormat, and the response part is after its title.
+	var (
+		content = qn_file.GetContents(path)
+		title   = strings.TrimRight(fmt.Sprintf(dumpTextFormat, "RESPONSE", "", ""), "\n") + "\n"
+		pos     = strings.Index(content, title)
+	)
+	if pos == -1 {
+		return nil, errors.New(fmt.Sprintf(`invalid fixture file "%s"`, path))
+	}
+	content = content[pos+len(title):]
+	if pos = strings.Index(content, "\r\n\r\n"); pos == -1 {
+		return nil, errors.New(fmt.Sprintf(`invalid fixture file "%s"`, path))
+	}
+	res, err := http.ReadResponse(bufio.NewReader(strings.NewReader(content[:pos+4])), req)
+	if err != nil {
+		return nil, err
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(content[pos+4:], "\n"), "\n")
+	res.Header.Del("Transfer-Encoding")
+	res.TransferEncoding = nil
+	res.ContentLength = int64(len(body))
+	res.Body = utils.NewReadCloser([]byte(body), false)
+	return res, nil
+}
+
+// fixturePath returns the fixture file path for the request <req> with <body>.
+func (r *ClientRecorder) fixturePath(req *http.Request, body []byte) string {
+	h := md5.New()
+	h.Write([]byte(req.Method + " " + req.URL.String() + "\n"))
+	h.Write(body)
+	return qn_file.Join(r.dir, fmt.Sprintf(
+		"%s_%s.txt", strings.ToLower(req.Method), hex.EncodeToString(h.Sum(nil))[:16],
+	))
+}
@@ -8,15 +8,10 @@ package qn_http
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
 	"github.com/qnsoft/common/internal/json"
 	"github.com/qnsoft/common/internal/utils"
@@ -24,8 +19,6 @@ import (
 
 	"github.com/qnsoft/common/encoding/qn_parser"
 	"github.com/qnsoft/common/text/qn_regex"
-
-	"github.com/qnsoft/common/os/qn_file"
 )
 
 // Get send GET request and returns the response object.
@@ -89,13 +82,37 @@ func (c *Client) Trace(url string, data ...interface{}) (*ClientResponse, error)
 // else it uses "application/x-www-form-urlencoded". It also automatically detects the post
 // content for JSON format, and for that it automatically sets the Content-Type as
 // "application/json".
+//
+// If <data> is *Multipart, it's sent as streaming "multipart/form-data" body, the Content-Type
+// of which containing the boundary is never overwritten by the custom header.
+// If <data> is any other io.Reader, like *os.File, *bytes.Buffer or *strings.Reader, it's sent
+// as streaming body as it is, without being converted to parameters like other types, and the
+// Content-Type should be set by the custom header if necessary.
 func (c *Client) DoRequest(method, url string, data ...interface{}) (resp *ClientResponse, err error) {
 	method = strings.ToUpper(method)
 	if len(c.prefix) > 0 {
 		url = c.prefix + qn.str.Trim(url)
 	}
-	param := ""
+	var (
+		body        io.Reader
+		contentType string
+	)
+	// Streaming body.
 	if len(data) > 0 {
+		switch v := data[0].(type) {
+		case *Multipart:
+			if err = v.check(); err != nil {
+				return nil, err
+			}
+			if body, contentType, err = v.Body(); err != nil {
+				return nil, err
+			}
+		case io.Reader:
+			body = v
+		}
+	}
+	param := ""
+	if body == nil && len(data) > 0 {
 		switch c.header["Content-Type"] {
 		case "application/json":
 			switch data[0].(type) {
@@ -123,47 +140,36 @@ func (c *Client) DoRequest(method, url string, data ...interface{}) (resp *Clien
 			param = BuildParams(data[0])
 		}
 	}
-	req := (*http.Request)(nil)
-	if strings.Contains(param, "@file:") {
-		// File uploading request.
-		buffer := new(bytes.Buffer)
-		writer := multipart.NewWriter(buffer)
+	if body == nil && strings.Contains(param, "@file:") {
+		// File uploading request, which is sent as streaming multipart body.
+		// Use Multipart for the field value which starts with "@file:" but is not a file.
+		m := NewMultipart()
 		for _, item := range strings.Split(param, "&") {
-			array := strings.Split(item, "=")
+			array := strings.SplitN(item, "=", 2)
+			if len(array) < 2 {
+				continue
+			}
 			if len(array[1]) > 6 && strings.Compare(array[1][0:6], "@file:") == 0 {
-				path := array[1][6:]
-				if !qn_file.Exists(path) {
-					return nil, errors.New(fmt.Sprintf(`"%s" does not exist`, path))
-				}
-				if file, err := writer.CreateFormFile(array[0], path); err == nil {
-					if f, err := os.Open(path); err == nil {
-						if _, err = io.Copy(file, f); err != nil {
-							f.Close()
-							return nil, err
-						}
-						f.Close()
-					} else {
-						return nil, err
-					}
-				} else {
-					return nil, err
-				}
+				m.AddFile(array[0], array[1][6:])
 			} else {
-				if err = writer.WriteField(array[0], array[1]); err != nil {
-					return nil, err
-				}
+				m.AddField(array[0], array[1])
 			}
 		}
-		// Close finishes the multipart message and writes the trailing
-		// boundary end line to the output.
-		if err = writer.Close(); err != nil {
+		if err = m.check(); err != nil {
 			return nil, err
 		}
-
-		if req, err = http.NewRequest(method, url, buffer); err != nil {
+		if body, contentType, err = m.Body(); err != nil {
 			return nil, err
-		} else {
-			req.Header.Set("Content-Type", writer.FormDataContentType())
+		}
+	}
+	req := (*http.Request)(nil)
+	if body != nil {
+		// Streaming request.
+		if req, err = http.NewRequest(method, url, body); err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
 		}
 	} else {
 		// Normal request.
@@ -192,6 +198,10 @@ func (c *Client) DoRequest(method, url string, data ...interface{}) (resp *Clien
 	// Custom header.
 	if len(c.header) > 0 {
 		for k, v := range c.header {
+			// The Content-Type of multipart body contains the boundary, which cannot be changed.
+			if contentType != "" && http.CanonicalHeaderKey(k) == "Content-Type" {
+				continue
+			}
 			req.Header.Set(k, v)
 		}
 	}
@@ -217,37 +227,54 @@ func (c *Client) DoRequest(method, url string, data ...interface{}) (resp *Clien
 	if len(c.authUser) > 0 {
 		req.SetBasicAuth(c.authUser, c.authPass)
 	}
+	// Sending the request through the middleware chain.
+	return c.callRequest(req)
+}
+
+// doRequest sends the prepared request <req> with retries and returns the response object,
+// which is the last handler of the client middleware chain.
+func (c *Client) doRequest(req *http.Request) (resp *ClientResponse, err error) {
 	resp = &ClientResponse{
 		request: req,
 	}
-	// The request body can be reused for dumping
-	// raw HTTP request-response procedure.
-	reqBodyContent, _ := ioutil.ReadAll(req.Body)
-	resp.requestBody = reqBodyContent
-	req.Body = utils.NewReadCloser(reqBodyContent, false)
-	for {
-		if resp.Response, err = c.Do(req); err != nil {
-			if c.retryCount > 0 {
-				c.retryCount--
-				time.Sleep(c.retryInterval)
-			} else {
-				return resp, err
-			}
-		} else {
+	// The request body can be reused for dumping raw HTTP request-response procedure
+	// and retrying, unless it's a streaming body of unknown size, which can be read only once.
+	var (
+		hasBody   = req.Body != nil && req.Body != http.NoBody
+		streaming = hasBody && req.GetBody == nil && req.ContentLength <= 0
+	)
+	if hasBody && !streaming {
+		reqBodyContent, _ := ioutil.ReadAll(req.Body)
+		resp.requestBody = reqBodyContent
+	}
+	// The retried count is only for current request, which never changes the client.
+	for retried := 0; ; retried++ {
+		// The request body is replayed for each attempt.
+		if hasBody && !streaming {
+			req.Body = utils.NewReadCloser(resp.requestBody, false)
+		}
+		if hasBody && c.uploadProgress != nil {
+			req.Body = newProgressReadCloser(req.Body, req.ContentLength, c.uploadProgress)
+		}
+		resp.Response, err = c.Do(req)
+		if streaming {
 			break
 		}
-	}
-
-	// Auto saving cookie content.
-	if c.browserMode {
-		now := time.Now()
-		for _, v := range resp.Response.Cookies() {
-			if v.Expires.UnixNano() < now.UnixNano() {
-				delete(c.cookies, v.Name)
-			} else {
-				c.cookies[v.Name] = v.Value
-			}
+		wait, ok := c.retryPolicy.retryWait(req, resp.Response, err, retried)
+		if !ok {
+			break
+		}
+		// The response is discarded for retrying.
+		if resp.Response != nil {
+			resp.Response.Body.Close()
+			resp.Response = nil
 		}
+		if err = sleepWithContext(req.Context(), wait); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		return resp, err
 	}
 	return resp, nil
 }
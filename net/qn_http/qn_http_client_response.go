@@ -19,6 +19,7 @@ type ClientResponse struct {
 	request     *http.Request
 	requestBody []byte
 	cookies     map[string]string
+	cacheStatus string // Cache status of the response if it goes through response caching.
 }
 
 // initCookie initializes the cookie map attribute of ClientResponse.
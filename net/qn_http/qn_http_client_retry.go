@@ -0,0 +1,202 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qnsoft/common/util/qn_rand"
+)
+
+// RetryPolicy is the retry policy for client requests.
+// The retry count is counted for each request, so it never changes the client.
+type RetryPolicy struct {
+	// Count specifies the max retry count for each request.
+	Count int
+
+	// Interval specifies the interval before the first retry.
+	Interval time.Duration
+
+	// MaxInterval specifies the max interval between retries, which also limits the
+	// interval from Retry-After header. It's not limited if it's zero.
+	MaxInterval time.Duration
+
+	// Multiplier specifies the multiplier of the interval for each retry,
+	// which is 2 in default. Use 1 for fixed interval.
+	Multiplier float64
+
+	// Jitter specifies the random factor of the interval in range [0, 1].
+	// The interval is randomized in [interval*(1-Jitter), interval*(1+Jitter)].
+	Jitter float64
+
+	// StatusCodes specifies the response status codes that are retried, which is
+	// 429/502/503/504 in default if it's nil. The transport errors are always retried.
+	StatusCodes []int
+
+	// Methods specifies the HTTP methods that can be retried, which is the idempotent
+	// methods GET/HEAD/PUT/DELETE/OPTIONS/TRACE in default if it's nil.
+	Methods []string
+}
+
+const (
+	gRETRY_DEFAULT_MULTIPLIER = 2
+)
+
+var (
+	// retryDefaultStatusCodes is the default status codes to be retried.
+	retryDefaultStatusCodes = []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+	// retryDefaultMethods is the default idempotent methods to be retried.
+	retryDefaultMethods = []string{"GET", "HEAD", "PUT", "DELETE", "OPTIONS", "TRACE"}
+)
+
+// SetRetryPolicy sets the retry policy for the client.
+func (c *Client) SetRetryPolicy(policy RetryPolicy) *Client {
+	if policy.Multiplier <= 0 {
+		policy.Multiplier = gRETRY_DEFAULT_MULTIPLIER
+	}
+	if policy.Jitter < 0 {
+		policy.Jitter = 0
+	} else if policy.Jitter > 1 {
+		policy.Jitter = 1
+	}
+	if policy.StatusCodes == nil {
+		policy.StatusCodes = retryDefaultStatusCodes
+	}
+	if policy.Methods == nil {
+		policy.Methods = retryDefaultMethods
+	}
+	methods := make([]string, len(policy.Methods))
+	for i, method := range policy.Methods {
+		methods[i] = strings.ToUpper(method)
+	}
+	policy.Methods = methods
+	c.retryPolicy = &policy
+	return c
+}
+
+// RetryPolicy is a chaining function,
+// which sets the retry policy for next request.
+func (c *Client) RetryPolicy(policy RetryPolicy) *Client {
+	newClient := c
+	if c.parent == nil {
+		newClient = c.Clone()
+	}
+	newClient.SetRetryPolicy(policy)
+	return newClient
+}
+
+// retryWait checks whether the request <req> should be retried for the response <res> or
+// error <err> of the attempt, and returns the waiting duration before retrying.
+// The parameter <retried> is the retried count of the request.
+func (p *RetryPolicy) retryWait(req *http.Request, res *http.Response, err error, retried int) (time.Duration, bool) {
+	if p == nil || retried >= p.Count || !p.isMethodRetried(req.Method) {
+		return 0, false
+	}
+	if err != nil {
+		// The request is canceled or timeout by its context.
+		if req.Context().Err() != nil {
+			return 0, false
+		}
+	} else if !p.isStatusRetried(res.StatusCode) {
+		return 0, false
+	}
+	wait := p.backoff(retried)
+	if res != nil {
+		if after, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			wait = after
+			if p.MaxInterval > 0 && wait > p.MaxInterval {
+				wait = p.MaxInterval
+			}
+		}
+	}
+	return wait, true
+}
+
+// backoff calculates and returns the interval before the retry, which is exponential
+// backoff with jitter.
+func (p *RetryPolicy) backoff(retried int) time.Duration {
+	interval := float64(p.Interval)
+	for i := 0; i < retried; i++ {
+		interval *= p.Multiplier
+		if p.MaxInterval > 0 && interval >= float64(p.MaxInterval) {
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		interval += interval * p.Jitter * float64(qn_rand.N(-1000, 1000)) / 1000
+	}
+	if p.MaxInterval > 0 && interval > float64(p.MaxInterval) {
+		interval = float64(p.MaxInterval)
+	}
+	return time.Duration(interval)
+}
+
+// isMethodRetried checks whether the request with <method> can be retried.
+func (p *RetryPolicy) isMethodRetried(method string) bool {
+	for _, v := range p.Methods {
+		if v == method {
+			return true
+		}
+	}
+	return false
+}
+
+// isStatusRetried checks whether the response with status <code> should be retried.
+func (p *RetryPolicy) isStatusRetried(code int) bool {
+	for _, v := range p.StatusCodes {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter parses the value of Retry-After header, which can be seconds or HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleepWithContext sleeps for duration <d>, which returns the error of <ctx> if it's done
+// before the duration passes.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/qnsoft/common/encoding/qn_xml"
+	"github.com/qnsoft/common/internal/json"
+	"github.com/qnsoft/common/util/qn_conv"
+)
+
+// ClientError is the error for the response having non-2xx status code.
+type ClientError struct {
+	Method     string      // Request method.
+	Url        string      // Request URL.
+	StatusCode int         // Response status code.
+	Status     string      // Response status, like: 404 Not Found.
+	Header     http.Header // Response header.
+	Body       string      // Excerpt of response body, which is truncated if it's too long.
+}
+
+const (
+	gCLIENT_ERROR_BODY_EXCERPT_SIZE = 512 // Max size of the body excerpt in ClientError.
+)
+
+// Error implements the interface of error.
+func (e *ClientError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf(`%s %s: %s`, e.Method, e.Url, e.Status)
+	}
+	return fmt.Sprintf(`%s %s: %s: %s`, e.Method, e.Url, e.Status, e.Body)
+}
+
+// Scan reads the response body and decodes it into <pointer> according to the Content-Type
+// of the response, which supports JSON and XML content. The <pointer> can be a pointer to
+// struct, map or slice for JSON content, but only a pointer to struct or map for XML content,
+// as the elements of XML content are decoded as map without root.
+//
+// It returns *ClientError if the status code of the response is not 2xx, and the error of
+// reading the response body if it fails reading.
+// Note that the response body is read, so it cannot be read again.
+func (r *ClientResponse) Scan(pointer interface{}) error {
+	body, err := ioutil.ReadAll(r.Response.Body)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return r.newClientError(body)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case isJsonContentType(contentType):
+		return json.Unmarshal(body, pointer)
+
+	case isXmlContentType(contentType):
+		if v := reflect.ValueOf(pointer); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice {
+			return errors.New(`scanning XML content into slice is not supported`)
+		}
+		m, err := qn_xml.DecodeWithoutRoot(body)
+		if err != nil {
+			return err
+		}
+		return qn_conv.Struct(m, pointer)
+
+	default:
+		// Auto detecting the JSON content without proper Content-Type.
+		if (body[0] == '[' || body[0] == '{') && json.Valid(body) {
+			return json.Unmarshal(body, pointer)
+		}
+		return errors.New(fmt.Sprintf(`unsupported Content-Type "%s" for scanning`, contentType))
+	}
+}
+
+// newClientError creates and returns a ClientError with response <body>.
+func (r *ClientResponse) newClientError(body []byte) *ClientError {
+	if len(body) > gCLIENT_ERROR_BODY_EXCERPT_SIZE {
+		body = append(body[:gCLIENT_ERROR_BODY_EXCERPT_SIZE:gCLIENT_ERROR_BODY_EXCERPT_SIZE], "..."...)
+	}
+	e := &ClientError{
+		StatusCode: r.StatusCode,
+		Status:     r.Status,
+		Header:     r.Header,
+		Body:       string(body),
+	}
+	if r.request != nil {
+		e.Method = r.request.Method
+		e.Url = r.request.URL.String()
+	}
+	return e
+}
+
+// GetJson sends GET request and decodes the JSON response into <pointer>.
+// It returns *ClientError if the status code of the response is not 2xx.
+func (c *Client) GetJson(url string, pointer interface{}, data ...interface{}) error {
+	return c.DoRequestScan("GET", url, pointer, data...)
+}
+
+// PutJson sends PUT request with JSON content of <data> and decodes the JSON response into <pointer>.
+// It returns *ClientError if the status code of the response is not 2xx.
+func (c *Client) PutJson(url string, pointer interface{}, data ...interface{}) error {
+	return c.ContentJson().DoRequestScan("PUT", url, pointer, data...)
+}
+
+// PostJson sends POST request with JSON content of <data> and decodes the JSON response into <pointer>.
+// It returns *ClientError if the status code of the response is not 2xx.
+func (c *Client) PostJson(url string, pointer interface{}, data ...interface{}) error {
+	return c.ContentJson().DoRequestScan("POST", url, pointer, data...)
+}
+
+// PatchJson sends PATCH request with JSON content of <data> and decodes the JSON response into <pointer>.
+// It returns *ClientError if the status code of the response is not 2xx.
+func (c *Client) PatchJson(url string, pointer interface{}, data ...interface{}) error {
+	return c.ContentJson().DoRequestScan("PATCH", url, pointer, data...)
+}
+
+// DeleteJson sends DELETE request and decodes the JSON response into <pointer>.
+// It returns *ClientError if the status code of the response is not 2xx.
+func (c *Client) DeleteJson(url string, pointer interface{}, data ...interface{}) error {
+	return c.DoRequestScan("DELETE", url, pointer, data...)
+}
+
+// DoRequestScan sends request with given HTTP method and data, and decodes the response
+// into <pointer> using ClientResponse.Scan. The response object is automatically closed.
+func (c *Client) DoRequestScan(method, url string, pointer interface{}, data ...interface{}) error {
+	response, err := c.DoRequest(method, url, data...)
+	if err != nil {
+		return err
+	}
+	defer response.Close()
+	return response.Scan(pointer)
+}
+
+// isJsonContentType checks whether <contentType> is JSON content type,
+// like: application/json, text/json, application/problem+json.
+func isJsonContentType(contentType string) bool {
+	return contentType == "application/json" || contentType == "text/json" ||
+		strings.HasSuffix(contentType, "+json")
+}
+
+// isXmlContentType checks whether <contentType> is XML content type,
+// like: application/xml, text/xml, application/atom+xml.
+func isXmlContentType(contentType string) bool {
+	return contentType == "application/xml" || contentType == "text/xml" ||
+		strings.HasSuffix(contentType, "+xml")
+}
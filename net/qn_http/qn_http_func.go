@@ -7,6 +7,7 @@
 package qn_http
 
 import (
+	"net/http"
 	"strings"
 
 	"github.com/qnsoft/common/errors/qn_error"
@@ -60,6 +61,9 @@ func niceCallFunc(f func()) {
 			switch e {
 			case gEXCEPTION_EXIT, gEXCEPTION_EXIT_ALL:
 				return
+			case http.ErrAbortHandler:
+				// It aborts the response, which is passed through as it is.
+				panic(e)
 			default:
 				if _, ok := e.(qn_error.ApiStack); ok {
 					// It's already an error that has stack info.
@@ -55,6 +55,7 @@ type Request struct {
 	isFileRequest   bool                   // A bool marking whether current request is file serving.
 	viewObject      *qn_view.View            // Custom template view engine object for this response.
 	viewParams      qn_view.Params           // Custom template view variables for this response.
+	domainConfig    *domainConfig            // Configuration of the requested domain, which is nil if not configured.
 }
 
 // StaticFile is the file struct for static file service.
@@ -72,8 +73,9 @@ func newRequest(s *Server, r *http.Request, w http.ResponseWriter) *Request {
 		Response:  newResponse(s, w),
 		EnterTime: qn_time.TimestampMilli(),
 	}
+	request.domainConfig = s.getDomainConfig(request.GetHost())
 	request.Cookie = GetCookie(request)
-	request.Session = s.sessionManager.New(request.GetSessionId())
+	request.Session = request.getSessionManager().New(request.GetSessionId())
 	request.Response.Request = request
 	request.Middleware = &Middleware{
 		request: request,
@@ -198,7 +200,7 @@ func (r *Request) GetUrl() string {
 func (r *Request) GetSessionId() string {
 	id := r.Cookie.GetSessionId()
 	if id == "" {
-		id = r.Header.Get(r.Server.GetSessionIdName())
+		id = r.Header.Get(r.getSessionIdName())
 	}
 	return id
 }
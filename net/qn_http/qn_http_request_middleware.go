@@ -122,6 +122,10 @@ func (m *Middleware) Next() {
 				loop = false
 			}
 		}, func(exception interface{}) {
+			// It aborts the response, which is passed through to the server.
+			if exception == http.ErrAbortHandler {
+				panic(exception)
+			}
 			if e, ok := exception.(qn_error.ApiStack); ok {
 				// It's already an error that has stack info.
 				m.request.error = e.(error)
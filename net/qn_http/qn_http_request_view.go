@@ -16,6 +16,9 @@ func (r *Request) SetView(view *qn_view.View) {
 // GetView returns the template view engine object for this request.
 func (r *Request) GetView() *qn_view.View {
 	view := r.viewObject
+	if view == nil && r.domainConfig != nil {
+		view = r.domainConfig.View
+	}
 	if view == nil {
 		view = r.Server.config.View
 	}
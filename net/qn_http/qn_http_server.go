@@ -51,6 +51,12 @@ type (
 		routesMap        map[string][]registeredRouteItem // Route map mainly for route dumps and repeated route checks.
 		statusHandlerMap map[string]HandlerFunc           // Custom status handler map.
 		sessionManager   *gsession.Manager                // Session manager.
+		draining         *qn_type.Bool                    // Whether the server is draining, which makes readiness checks fail.
+		inflightMap      *qn_map.Map                      // In-flight requests, the key is *Request.
+		healthChecks     *qn_map.ListMap                  // Registered health checks, the key is the check name.
+		drainHooks       map[string][]DrainHookFunc       // Hooks for drain phases.
+		domainConfigs    map[string]*domainConfig         // Configurations for domains, the key is the domain or wildcard domain.
+		wildcardDomains  []string                         // Registered wildcard domains like "*.example.com", longest first.
 	}
 
 	// Router object.
@@ -65,16 +71,17 @@ type (
 
 	// Router item just for route dumps.
 	RouterItem struct {
-		Server           string       // Server name.
-		Address          string       // Listening address.
-		Domain           string       // Bound domain.
-		Type             int          // Router type.
-		Middleware       string       // Bound middleware.
-		Method           string       // Handler method name.
-		Route            string       // Route URI.
-		Priority         int          // Just for reference.
-		IsServiceHandler bool         // Is service handler.
-		handler          *handlerItem // The handler.
+		Server           string                 // Server name.
+		Address          string                 // Listening address.
+		Domain           string                 // Bound domain.
+		Type             int                    // Router type.
+		Middleware       string                 // Bound middleware.
+		Method           string                 // Handler method name.
+		Route            string                 // Route URI.
+		Priority         int                    // Just for reference.
+		IsServiceHandler bool                   // Is service handler.
+		Chains           map[string]RouterChain // Resolved hooks, middleware and handler chains by HTTP method, only for service handler.
+		handler          *handlerItem           // The handler.
 	}
 
 	// handlerItem is the registered handler for route handling,
@@ -240,6 +247,11 @@ func GetServer(name ...interface{}) *Server {
 		serveTree:        make(map[string]interface{}),
 		serveCache:       qn_cache.New(),
 		routesMap:        make(map[string][]registeredRouteItem),
+		draining:         qn_type.NewBool(),
+		inflightMap:      qn_map.New(true),
+		healthChecks:     qn_map.NewListMap(true),
+		drainHooks:       make(map[string][]DrainHookFunc),
+		domainConfigs:    make(map[string]*domainConfig),
 	}
 	// Initialize the server using default configurations.
 	if err := s.SetConfig(Config()); err != nil {
@@ -293,6 +305,8 @@ func (s *Server) Start() error {
 		s.config.SessionMaxAge,
 		s.config.SessionStorage,
 	)
+	// Session managers for domains having custom session configuration.
+	s.initDomainSessionManagers()
 
 	// PProf feature.
 	if s.config.PProfEnabled {
@@ -358,6 +372,9 @@ func (s *Server) dumpRouterMap() {
 			data[4] = item.Route
 			data[5] = item.handler.itemName
 			data[6] = item.Middleware
+			if item.IsServiceHandler {
+				data[6] = routeChainsMiddleware(item.Chains)
+			}
 			table.Append(data)
 		}
 		table.Render()
@@ -393,8 +410,9 @@ func (s *Server) GetRouterArray() []RouterItem {
 			switch item.handler.itemType {
 			case gHANDLER_TYPE_CONTROLLER, gHANDLER_TYPE_OBJECT, gHANDLER_TYPE_HANDLER:
 				item.IsServiceHandler = true
+				item.Chains = s.getRouteChainsByItem(item.handler)
 			case gHANDLER_TYPE_MIDDLEWARE:
-				item.Middleware = "GLOBAL MIDDLEWARE"
+				item.Middleware = ROUTE_CHAIN_GLOBAL_MIDDLEWARE
 			}
 			if len(item.handler.middleware) > 0 {
 				for _, v := range item.handler.middleware {
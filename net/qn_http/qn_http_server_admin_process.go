@@ -185,13 +185,13 @@ func restartWebServers(signal string, newExeFilePath ...string) error {
 	if runtime.GOOS == "windows" {
 		if len(signal) > 0 {
 			// Controlled by signal.
-			forceCloseWebServers()
+			drainWebServers()
 			forkRestartProcess(newExeFilePath...)
 		} else {
 			// Controlled by web page.
 			// It should ensure the response wrote to client and then close all servers gracefully.
 			qn_timer.SetTimeout(time.Second, func() {
-				forceCloseWebServers()
+				drainWebServers()
 				forkRestartProcess(newExeFilePath...)
 			})
 		}
@@ -213,41 +213,28 @@ func restartWebServers(signal string, newExeFilePath ...string) error {
 }
 
 // shutdownWebServers shuts down all servers.
+// The servers are drained before shutting down, so that the signal from orchestrators like
+// Kubernetes makes the readiness check failing, waits the grace period and the in-flight requests.
 func shutdownWebServers(signal ...string) {
 	serverProcessStatus.Set(gADMIN_ACTION_SHUTINGDOWN)
 	if len(signal) > 0 {
 		qn_log.Printf("%d: server shutting down by signal: %s", gproc.Pid(), signal[0])
-		forceCloseWebServers()
+		drainWebServers()
 		allDoneChan <- struct{}{}
 	} else {
 		qn_log.Printf("%d: server shutting down by api", gproc.Pid())
+		// It should ensure the response wrote to client and then drain all servers.
 		qn_timer.SetTimeout(time.Second, func() {
-			forceCloseWebServers()
+			drainWebServers()
 			allDoneChan <- struct{}{}
 		})
 	}
 }
 
-// gracefulShutdownWebServers gracefully shuts down all servers.
+// gracefulShutdownWebServers gracefully shuts down all servers,
+// which drains the servers and calls their drain hooks.
 func gracefulShutdownWebServers() {
-	serverMapping.RLockFunc(func(m map[string]interface{}) {
-		for _, v := range m {
-			for _, s := range v.(*Server).servers {
-				s.shutdown()
-			}
-		}
-	})
-}
-
-// forceCloseWebServers forced shuts down all servers.
-func forceCloseWebServers() {
-	serverMapping.RLockFunc(func(m map[string]interface{}) {
-		for _, v := range m {
-			for _, s := range v.(*Server).servers {
-				s.close()
-			}
-		}
-	})
+	drainWebServers()
 }
 
 // handleProcessMessage listens the signal from system.
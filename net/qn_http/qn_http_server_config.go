@@ -203,6 +203,20 @@ type ServerConfig struct {
 	// PProfPattern specifies the PProf service pattern for router.
 	PProfPattern string
 
+	// ==================================
+	// Drain.
+	// ==================================
+
+	// DrainGracePeriod specifies the duration to wait after the readiness check turns failing
+	// and before the underlying servers are shut down, which gives the load balancer time to
+	// remove the server from its endpoints.
+	DrainGracePeriod time.Duration
+
+	// ShutdownTimeout specifies the deadline for shutting down the underlying servers when draining.
+	// The servers are closed forcibly if there're still in-flight requests after the deadline.
+	// It waits for all in-flight requests done if it's 0.
+	ShutdownTimeout time.Duration
+
 	// ==================================
 	// Other.
 	// ==================================
@@ -0,0 +1,21 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"time"
+)
+
+// SetDrainGracePeriod sets the DrainGracePeriod for server.
+func (s *Server) SetDrainGracePeriod(period time.Duration) {
+	s.config.DrainGracePeriod = period
+}
+
+// SetShutdownTimeout sets the ShutdownTimeout for server.
+func (s *Server) SetShutdownTimeout(timeout time.Duration) {
+	s.config.ShutdownTimeout = timeout
+}
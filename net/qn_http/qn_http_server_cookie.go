@@ -108,12 +108,12 @@ func (c *Cookie) SetCookie(key, value, domain, path string, maxAge time.Duration
 
 // GetSessionId retrieves and returns the session id from cookie.
 func (c *Cookie) GetSessionId() string {
-	return c.Get(c.server.GetSessionIdName())
+	return c.Get(c.request.getSessionIdName())
 }
 
 // SetSessionId sets session id in the cookie.
 func (c *Cookie) SetSessionId(id string) {
-	c.Set(c.server.GetSessionIdName(), id)
+	c.Set(c.request.getSessionIdName(), id)
 }
 
 // Get retrieves and returns the value with specified key.
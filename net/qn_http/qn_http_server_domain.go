@@ -7,6 +7,7 @@
 package qn_http
 
 import (
+	"sort"
 	"strings"
 )
 
@@ -16,7 +17,17 @@ type Domain struct {
 	domains map[string]struct{} // Support multiple domains.
 }
 
+const (
+	// gDOMAIN_WILDCARD_ROUTER_NAME is the router value name of the subdomain matched by wildcard domain.
+	gDOMAIN_WILDCARD_ROUTER_NAME = "subdomain"
+)
+
 // Domain creates and returns a domain object for management for one or more domains.
+//
+// The domain can be wildcard pattern like "*.example.com", which matches any subdomain
+// of "example.com" if there's no exact domain matched, and the matched subdomain can be
+// retrieved by r.GetRouterString("subdomain"). If multiple wildcard domains are matched,
+// the longest one is used.
 func (s *Server) Domain(domains string) *Domain {
 	d := &Domain{
 		server:  s,
@@ -178,3 +189,33 @@ func (d *Domain) BindMiddlewareDefault(handlers ...HandlerFunc) {
 func (d *Domain) Use(handlers ...HandlerFunc) {
 	d.BindMiddlewareDefault(handlers...)
 }
+
+// addWildcardDomain adds <domain> to the wildcard domain array if it's a wildcard pattern.
+// The array is sorted by length in descending order, so that the longest one matches first.
+func (s *Server) addWildcardDomain(domain string) {
+	if !strings.HasPrefix(domain, "*.") {
+		return
+	}
+	for _, v := range s.wildcardDomains {
+		if v == domain {
+			return
+		}
+	}
+	s.wildcardDomains = append(s.wildcardDomains, domain)
+	sort.SliceStable(s.wildcardDomains, func(i, j int) bool {
+		return len(s.wildcardDomains[i]) > len(s.wildcardDomains[j])
+	})
+}
+
+// searchWildcardDomain searches and returns the wildcard domain matching <host>,
+// along with the matched subdomain. It returns empty strings if no one matches.
+func (s *Server) searchWildcardDomain(host string) (domain, subdomain string) {
+	host = strings.ToLower(host)
+	for _, v := range s.wildcardDomains {
+		suffix := v[1:]
+		if len(host) > len(suffix) && strings.HasSuffix(host, suffix) {
+			return v, host[:len(host)-len(suffix)]
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qnsoft/common/os/gsession"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_res"
+	"github.com/qnsoft/common/os/qn_view"
+)
+
+// DomainConfig is the configuration for domains, which overrides the server configuration
+// for requests to the domains. The empty field uses the server configuration.
+type DomainConfig struct {
+	// ServerRoot specifies the root directory for static service of the domain,
+	// which is searched before the server's.
+	ServerRoot string
+
+	// View specifies the template view engine of the domain.
+	View *qn_view.View
+
+	// SessionIdName specifies the session id name of the domain.
+	SessionIdName string
+
+	// SessionMaxAge specifies max TTL for session items of the domain.
+	SessionMaxAge time.Duration
+
+	// SessionStorage specifies the session storage of the domain.
+	SessionStorage gsession.Storage
+
+	// HTTPSCertPath and HTTPSKeyPath specify the certificate of the domain, which is selected
+	// by the server name of TLS handshake. Note that HTTPS should be enabled for the server.
+	HTTPSCertPath string
+	HTTPSKeyPath  string
+}
+
+// domainConfig is the parsed DomainConfig.
+type domainConfig struct {
+	DomainConfig
+	certificate    *tls.Certificate  // Loaded certificate.
+	sessionManager *gsession.Manager // Session manager, which is nil if using the server's.
+}
+
+// SetConfig sets the configuration for the domains, which can be called only before the server starts.
+func (d *Domain) SetConfig(config DomainConfig) error {
+	c := &domainConfig{
+		DomainConfig: config,
+	}
+	if c.ServerRoot != "" && !qn_res.Contains(c.ServerRoot) {
+		realPath, err := qn_file.Search(c.ServerRoot)
+		if err != nil {
+			return errors.New(fmt.Sprintf(`[qn_http] domain ServerRoot failed: %v`, err))
+		}
+		c.ServerRoot = strings.TrimRight(realPath, qn_file.Separator)
+	}
+	if c.HTTPSCertPath != "" || c.HTTPSKeyPath != "" {
+		certificate, err := tls.LoadX509KeyPair(c.HTTPSCertPath, c.HTTPSKeyPath)
+		if err != nil {
+			return errors.New(fmt.Sprintf(
+				`open cert file "%s","%s" failed: %s`, c.HTTPSCertPath, c.HTTPSKeyPath, err.Error(),
+			))
+		}
+		c.certificate = &certificate
+	}
+	for domain := range d.domains {
+		d.server.domainConfigs[domain] = c
+		d.server.addWildcardDomain(domain)
+	}
+	return nil
+}
+
+// getDomainConfig retrieves and returns the configuration for <host>,
+// exact domain first and then wildcard domain. It returns nil if no configuration matched.
+func (s *Server) getDomainConfig(host string) *domainConfig {
+	if len(s.domainConfigs) == 0 {
+		return nil
+	}
+	if c, ok := s.domainConfigs[host]; ok {
+		return c
+	}
+	if domain, _ := s.searchWildcardDomain(host); domain != "" {
+		return s.domainConfigs[domain]
+	}
+	return nil
+}
+
+// initDomainSessionManagers creates session managers for domains having custom session
+// configuration, which is called after the server's session manager is created.
+func (s *Server) initDomainSessionManagers() {
+	for _, c := range s.domainConfigs {
+		if c.sessionManager != nil || (c.SessionMaxAge == 0 && c.SessionStorage == nil) {
+			continue
+		}
+		var (
+			maxAge  = c.SessionMaxAge
+			storage = c.SessionStorage
+		)
+		if maxAge == 0 {
+			maxAge = s.config.SessionMaxAge
+		}
+		if storage == nil {
+			storage = s.config.SessionStorage
+		}
+		c.sessionManager = gsession.New(maxAge, storage)
+	}
+}
+
+// getDomainCertificate returns the certificate of the domain for TLS handshake.
+// It returns nil to use the server's certificate if the domain has no certificate.
+func (s *Server) getDomainCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if c := s.getDomainConfig(strings.ToLower(hello.ServerName)); c != nil {
+		return c.certificate, nil
+	}
+	return nil, nil
+}
+
+// hasDomainCertificate checks and returns whether any domain has its own certificate.
+func (s *Server) hasDomainCertificate() bool {
+	for _, c := range s.domainConfigs {
+		if c.certificate != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// getSessionManager returns the session manager for the request.
+func (r *Request) getSessionManager() *gsession.Manager {
+	if r.domainConfig != nil && r.domainConfig.sessionManager != nil {
+		return r.domainConfig.sessionManager
+	}
+	return r.Server.sessionManager
+}
+
+// getSessionIdName returns the session id name for the request.
+func (r *Request) getSessionIdName() string {
+	if r.domainConfig != nil && r.domainConfig.SessionIdName != "" {
+		return r.domainConfig.SessionIdName
+	}
+	return r.Server.GetSessionIdName()
+}
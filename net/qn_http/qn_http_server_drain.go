@@ -0,0 +1,140 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/qnsoft/common/os/gproc"
+	"github.com/qnsoft/common/os/qn_time"
+)
+
+const (
+	DRAIN_PHASE_START    = "DRAIN_PHASE_START"    // Readiness check turns failing, before waiting the grace period.
+	DRAIN_PHASE_SHUTDOWN = "DRAIN_PHASE_SHUTDOWN" // Grace period passed, before shutting down the underlying servers.
+	DRAIN_PHASE_DONE     = "DRAIN_PHASE_DONE"     // All underlying servers are shut down.
+)
+
+// DrainHookFunc is the hook function for drain phases.
+type DrainHookFunc = func(s *Server, phase string)
+
+// InflightRequest is the information of a request which is still under serving.
+type InflightRequest struct {
+	Method    string // HTTP method.
+	Url       string // Requested URL.
+	ClientIp  string // Client ip.
+	EnterTime int64  // Request starting time in milliseconds.
+}
+
+// BindDrainHook registers hook for specified drain <phase>.
+// The hooks are called in their registering order, either when Drain is called
+// or when the servers are restarted by RestartAllServer.
+func (s *Server) BindDrainHook(phase string, hook DrainHookFunc) {
+	s.drainHooks[phase] = append(s.drainHooks[phase], hook)
+}
+
+// IsDraining checks and returns whether the server is draining.
+func (s *Server) IsDraining() bool {
+	return s.draining.Val()
+}
+
+// GetInflightRequests retrieves and returns the requests still under serving.
+func (s *Server) GetInflightRequests() []InflightRequest {
+	requests := make([]InflightRequest, 0)
+	s.inflightMap.Iterator(func(k, v interface{}) bool {
+		r := k.(*Request)
+		requests = append(requests, InflightRequest{
+			Method:    r.Method,
+			Url:       r.URL.String(),
+			ClientIp:  r.GetClientIp(),
+			EnterTime: r.EnterTime,
+		})
+		return true
+	})
+	return requests
+}
+
+// Drain gracefully shuts down the server for orchestrators like Kubernetes:
+//  1. The readiness check turns failing;
+//  2. It waits for ServerConfig.DrainGracePeriod;
+//  3. It shuts down the underlying servers, waiting the in-flight requests done
+//     in ServerConfig.ShutdownTimeout, and closes them forcibly after the deadline.
+//
+// The drain hooks are called around each phase. It blocks until all phases are done.
+func (s *Server) Drain() error {
+	if !s.draining.Cas(false, true) {
+		return nil
+	}
+	s.callDrainHooks(DRAIN_PHASE_START)
+	if s.config.DrainGracePeriod > 0 {
+		time.Sleep(s.config.DrainGracePeriod)
+	}
+	s.callDrainHooks(DRAIN_PHASE_SHUTDOWN)
+	var (
+		err    error
+		ctx    = context.Background()
+		cancel = context.CancelFunc(func() {})
+	)
+	if s.config.ShutdownTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.config.ShutdownTimeout)
+	}
+	defer cancel()
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	for _, v := range s.servers {
+		wg.Add(1)
+		go func(server *gracefulServer) {
+			defer wg.Done()
+			if e := server.shutdown(ctx); e != nil {
+				mu.Lock()
+				err = e
+				mu.Unlock()
+			}
+		}(v)
+	}
+	wg.Wait()
+	if err != nil {
+		// Report the requests which are still running after the deadline.
+		now := qn_time.TimestampMilli()
+		for _, r := range s.GetInflightRequests() {
+			s.Logger().Warningf(
+				"%d: request still in-flight after shutdown deadline: %s %s from %s, running for %dms",
+				gproc.Pid(), r.Method, r.Url, r.ClientIp, now-r.EnterTime,
+			)
+		}
+		// The listeners are already closed, it closes the active connections forcibly.
+		for _, v := range s.servers {
+			v.httpServer.Close()
+		}
+	}
+	s.callDrainHooks(DRAIN_PHASE_DONE)
+	return err
+}
+
+// callDrainHooks calls the hooks of given drain <phase>.
+func (s *Server) callDrainHooks(phase string) {
+	for _, hook := range s.drainHooks[phase] {
+		hook(s, phase)
+	}
+}
+
+// drainWebServers drains all servers of current process concurrently.
+func drainWebServers() {
+	wg := sync.WaitGroup{}
+	serverMapping.RLockFunc(func(m map[string]interface{}) {
+		for _, v := range m {
+			wg.Add(1)
+			go func(s *Server) {
+				defer wg.Done()
+				s.Drain()
+			}(v.(*Server))
+		}
+	})
+	wg.Wait()
+}
@@ -107,6 +107,10 @@ func (s *gracefulServer) ListenAndServeTLS(certFile, keyFile string, tlsConfig .
 	if config.NextProtos == nil {
 		config.NextProtos = []string{"http/1.1"}
 	}
+	// Certificates of domains are selected by the server name of TLS handshake.
+	if config.GetCertificate == nil && s.server.hasDomainCertificate() {
+		config.GetCertificate = s.server.getDomainCertificate
+	}
 	err := error(nil)
 	if len(config.Certificates) == 0 {
 		config.Certificates = make([]tls.Certificate, 1)
@@ -171,16 +175,23 @@ func (s *gracefulServer) getNetListener() (net.Listener, error) {
 }
 
 // shutdown shuts down the server gracefully.
-func (s *gracefulServer) shutdown() {
+// The optional parameter <ctx> specifies the deadline for waiting the active connections done.
+func (s *gracefulServer) shutdown(ctx ...context.Context) error {
 	if s.status == SERVER_STATUS_STOPPED {
-		return
+		return nil
+	}
+	shutdownCtx := context.Background()
+	if len(ctx) > 0 && ctx[0] != nil {
+		shutdownCtx = ctx[0]
 	}
-	if err := s.httpServer.Shutdown(context.Background()); err != nil {
+	err := s.httpServer.Shutdown(shutdownCtx)
+	if err != nil {
 		s.server.Logger().Errorf(
 			"%d: %s server [%s] shutdown error: %v",
 			gproc.Pid(), s.getProto(), s.address, err,
 		)
 	}
+	return err
 }
 
 // close shuts down the server forcibly.
@@ -54,13 +54,22 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Create a new request object.
 	request := newRequest(s, r, w)
 
+	// Track the in-flight request for draining.
+	s.inflightMap.Set(request, nil)
+	defer s.inflightMap.Remove(request)
+
 	defer func() {
+		aborted := false
 		request.LeaveTime = qn_time.TimestampMilli()
 		// error log handling.
 		if request.error != nil {
 			s.handleErrorLog(request.error, request)
 		} else {
-			if exception := recover(); exception != nil {
+			if exception := recover(); exception == http.ErrAbortHandler {
+				// The handler aborts the response, like the reverse proxy failing copying
+				// the response body, which is not an error of the server.
+				aborted = true
+			} else if exception != nil {
 				request.Response.WriteStatus(http.StatusInternalServerError)
 				s.handleErrorLog(qn_error.Newf("%v", exception), request)
 			}
@@ -70,6 +79,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// Close the session, which automatically update the TTL
 		// of the session if it exists.
 		request.Session.Close()
+		// The underlying server aborts the connection silently for it.
+		if aborted {
+			panic(http.ErrAbortHandler)
+		}
 	}()
 
 	// ============================================================
@@ -79,11 +92,15 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Search the static file with most high priority,
 	// which also handle the index files feature.
-	if s.config.FileServerEnabled {
+	// The static directory of the domain is searched before the server's.
+	if request.domainConfig != nil && request.domainConfig.ServerRoot != "" {
+		request.StaticFile = s.searchStaticFileInPath(request.domainConfig.ServerRoot, r.URL.Path)
+	}
+	if request.StaticFile == nil && s.config.FileServerEnabled {
 		request.StaticFile = s.searchStaticFile(r.URL.Path)
-		if request.StaticFile != nil {
-			request.isFileRequest = true
-		}
+	}
+	if request.StaticFile != nil {
+		request.isFileRequest = true
 	}
 
 	// Search the dynamic service handler.
@@ -149,6 +166,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Encode the session data to session id for the storage like StorageCookie.
+	if err := request.Session.Flush(); err != nil {
+		s.handleErrorLog(err, request)
+	}
 	// Automatically set the session id to cookie
 	// if it creates a new session id in this request.
 	if request.Session.IsDirty() && request.Session.Id() != request.GetSessionId() {
@@ -199,18 +220,8 @@ func (s *Server) searchStaticFile(uri string) *StaticFile {
 	// Secondly search the root and searching paths.
 	if len(s.config.SearchPaths) > 0 {
 		for _, p := range s.config.SearchPaths {
-			file = qn_res.GetWithIndex(p+uri, s.config.IndexFiles)
-			if file != nil {
-				return &StaticFile{
-					File:  file,
-					IsDir: file.FileInfo().IsDir(),
-				}
-			}
-			if path, dir = qn.spath.Search(p, uri, s.config.IndexFiles...); path != "" {
-				return &StaticFile{
-					Path:  path,
-					IsDir: dir,
-				}
+			if f := s.searchStaticFileInPath(p, uri); f != nil {
+				return f
 			}
 		}
 	}
@@ -226,6 +237,23 @@ func (s *Server) searchStaticFile(uri string) *StaticFile {
 	return nil
 }
 
+// searchStaticFileInPath searches the file with given URI in directory <p>.
+func (s *Server) searchStaticFileInPath(p string, uri string) *StaticFile {
+	if file := qn_res.GetWithIndex(p+uri, s.config.IndexFiles); file != nil {
+		return &StaticFile{
+			File:  file,
+			IsDir: file.FileInfo().IsDir(),
+		}
+	}
+	if path, dir := qn.spath.Search(p, uri, s.config.IndexFiles...); path != "" {
+		return &StaticFile{
+			Path:  path,
+			IsDir: dir,
+		}
+	}
+	return nil
+}
+
 // serveFile serves the static file for client.
 // The optional parameter <allowIndex> specifies if allowing directory listing if <f> is directory.
 func (s *Server) serveFile(r *Request, f *StaticFile, allowIndex ...bool) {
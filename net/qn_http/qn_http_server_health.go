@@ -0,0 +1,109 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HealthCheckFunc is the function for health checks, which returns error if unhealthy.
+type HealthCheckFunc = func() error
+
+// healthCheckItem is the registered health check.
+type healthCheckItem struct {
+	check         HealthCheckFunc
+	readinessOnly bool
+}
+
+// utilHealth is the controller for health checks.
+type utilHealth struct {
+	server *Server
+}
+
+// EnableHealth enables the built-in health check endpoints for server:
+// "/healthz" for liveness checks and "/readyz" for readiness checks.
+// The optional parameter <pattern> specifies the URI prefix for the endpoints.
+//
+// Both endpoints response status 200 if all their checks pass, or else status 503.
+// The "/readyz" also responses status 503 if the server is draining.
+func (s *Server) EnableHealth(pattern ...string) {
+	p := ""
+	if len(pattern) > 0 {
+		p = strings.TrimRight(pattern[0], "/")
+	}
+	h := &utilHealth{server: s}
+	s.BindHandler(p+"/healthz", h.Healthz)
+	s.BindHandler(p+"/readyz", h.Readyz)
+}
+
+// AddHealthCheck registers a health check named <name>, which is used by both
+// liveness and readiness checks.
+func (s *Server) AddHealthCheck(name string, check HealthCheckFunc) {
+	s.healthChecks.Set(name, &healthCheckItem{check: check})
+}
+
+// AddReadinessCheck registers a health check named <name>, which is only used by
+// readiness checks, like checks for dependencies that the server needs to serve.
+func (s *Server) AddReadinessCheck(name string, check HealthCheckFunc) {
+	s.healthChecks.Set(name, &healthCheckItem{check: check, readinessOnly: true})
+}
+
+// Healthz is the handler for liveness checks.
+func (h *utilHealth) Healthz(r *Request) {
+	h.doCheck(r, false)
+}
+
+// Readyz is the handler for readiness checks.
+func (h *utilHealth) Readyz(r *Request) {
+	h.doCheck(r, true)
+}
+
+// doCheck runs the registered checks and writes the result to the response.
+func (h *utilHealth) doCheck(r *Request, readiness bool) {
+	var (
+		status = "ok"
+		checks = make(map[string]string)
+	)
+	h.server.healthChecks.Iterator(func(k, v interface{}) bool {
+		item := v.(*healthCheckItem)
+		if item.readinessOnly && !readiness {
+			return true
+		}
+		result := "ok"
+		if err := runHealthCheck(item.check); err != nil {
+			result = err.Error()
+			status = "failed"
+		}
+		checks[k.(string)] = result
+		return true
+	})
+	if readiness && h.server.IsDraining() {
+		status = "draining"
+	}
+	r.Response.Header().Set("Cache-Control", "no-store")
+	if status != "ok" {
+		r.Response.WriteHeader(http.StatusServiceUnavailable)
+	}
+	r.Response.WriteJson(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// runHealthCheck calls <check> and returns its error,
+// the panic in <check> is also treated as failure.
+func runHealthCheck(check HealthCheckFunc) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprintf("%v", e))
+		}
+	}()
+	return check()
+}
@@ -0,0 +1,331 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/internal/utils"
+	"github.com/qnsoft/common/os/qn_timer"
+)
+
+// ProxyConfig is the configuration for reverse proxy handler.
+type ProxyConfig struct {
+	// Upstreams specifies the backend service addresses like: http://127.0.0.1:8080.
+	Upstreams []string
+
+	// Balance specifies the load balancing algorithm, which can be PROXY_BALANCE_ROUND_ROBIN,
+	// PROXY_BALANCE_LEAST_CONN or PROXY_BALANCE_CONSISTENT_HASH. It's round-robin in default.
+	Balance string
+
+	// HashKey specifies the key for consistent hash balancing, which is the client ip in default.
+	HashKey func(r *Request) string
+
+	// StripPrefix specifies the prefix of URI path that is removed before forwarding.
+	StripPrefix string
+
+	// PreserveHost specifies whether forwarding the request with the original Host header.
+	PreserveHost bool
+
+	// Retry specifies how many other upstreams are tried if the request fails forwarding.
+	// Only the idempotent requests are retried.
+	Retry int
+
+	// RequestHeaders specifies the headers set to the forwarded request.
+	// The header is removed if its value is empty.
+	RequestHeaders map[string]string
+
+	// ResponseHeaders specifies the headers set to the response from upstream.
+	// The header is removed if its value is empty.
+	ResponseHeaders map[string]string
+
+	// HealthCheckPath specifies the URI path for active health checks, like: /healthz.
+	// The health check is disabled if it's empty.
+	HealthCheckPath string
+
+	// HealthCheckInterval specifies the interval of active health checks, which is 10 seconds in default.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckTimeout specifies the timeout of each health check request, which is 3 seconds in default.
+	HealthCheckTimeout time.Duration
+
+	// FlushInterval specifies the flush interval to flush to the client while copying the response body.
+	// A negative value means to flush immediately after each write to the client.
+	FlushInterval time.Duration
+
+	// Transport specifies the transport for forwarding requests, which is http.DefaultTransport in default.
+	Transport http.RoundTripper
+}
+
+// Proxy is the reverse proxy handler, which forwards requests to multiple upstreams.
+type Proxy struct {
+	config    ProxyConfig
+	upstreams []*proxyUpstream
+	balancer  proxyBalancer
+	entry     *qn_timer.Entry // Timer entry for health checks.
+}
+
+// proxyUpstream is a backend service of the proxy.
+type proxyUpstream struct {
+	url   *url.URL               // Parsed URL of the upstream.
+	proxy *httputil.ReverseProxy // Underlying reverse proxy.
+	alive *qn_type.Bool          // Whether the upstream passes health checks.
+	conns *qn_type.Int           // Active request count.
+}
+
+// proxyAttempt is the state of one forwarding attempt, which is passed by request context.
+type proxyAttempt struct {
+	err error
+}
+
+// proxyAttemptCtxKey is the context key for proxyAttempt.
+type proxyAttemptCtxKey struct{}
+
+const (
+	gPROXY_DEFAULT_HEALTH_CHECK_INTERVAL = 10 * time.Second
+	gPROXY_DEFAULT_HEALTH_CHECK_TIMEOUT  = 3 * time.Second
+)
+
+var (
+	// proxyIdempotentMethods specifies the methods that can be retried.
+	proxyIdempotentMethods = map[string]struct{}{
+		"GET":     {},
+		"HEAD":    {},
+		"PUT":     {},
+		"DELETE":  {},
+		"OPTIONS": {},
+		"TRACE":   {},
+	}
+)
+
+// NewProxy creates and returns a reverse proxy handler with given configuration.
+// Use Proxy.Serve as the handler for route registering, for example:
+// s.BindHandler("/api/*any", proxy.Serve).
+func NewProxy(config ProxyConfig) (*Proxy, error) {
+	if len(config.Upstreams) == 0 {
+		return nil, errors.New("no upstream configured for proxy")
+	}
+	if config.Transport == nil {
+		config.Transport = http.DefaultTransport
+	}
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = gPROXY_DEFAULT_HEALTH_CHECK_INTERVAL
+	}
+	if config.HealthCheckTimeout <= 0 {
+		config.HealthCheckTimeout = gPROXY_DEFAULT_HEALTH_CHECK_TIMEOUT
+	}
+	p := &Proxy{
+		config:    config,
+		upstreams: make([]*proxyUpstream, 0, len(config.Upstreams)),
+	}
+	for _, address := range config.Upstreams {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.New(fmt.Sprintf(`invalid upstream address "%s"`, address))
+		}
+		p.upstreams = append(p.upstreams, p.newUpstream(u))
+	}
+	p.balancer = newProxyBalancer(config.Balance, p.upstreams)
+	if config.HealthCheckPath != "" {
+		p.entry = qn_timer.AddSingleton(config.HealthCheckInterval, p.checkHealth)
+	}
+	return p, nil
+}
+
+// newUpstream creates and returns an upstream object for <u>.
+func (p *Proxy) newUpstream(u *url.URL) *proxyUpstream {
+	upstream := &proxyUpstream{
+		url:   u,
+		alive: qn_type.NewBool(true),
+		conns: qn_type.NewInt(),
+	}
+	upstream.proxy = &httputil.ReverseProxy{
+		Transport:     p.config.Transport,
+		FlushInterval: p.config.FlushInterval,
+		Director: func(req *http.Request) {
+			p.direct(u, req)
+		},
+		ModifyResponse: func(res *http.Response) error {
+			for k, v := range p.config.ResponseHeaders {
+				if v == "" {
+					res.Header.Del(k)
+				} else {
+					res.Header.Set(k, v)
+				}
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			if attempt, ok := req.Context().Value(proxyAttemptCtxKey{}).(*proxyAttempt); ok {
+				attempt.err = err
+			}
+		},
+	}
+	return upstream
+}
+
+// direct rewrites the request <req> forwarding to upstream <u>.
+func (p *Proxy) direct(u *url.URL, req *http.Request) {
+	path := req.URL.Path
+	if p.config.StripPrefix != "" && strings.HasPrefix(path, p.config.StripPrefix) {
+		path = path[len(p.config.StripPrefix):]
+		if path == "" || path[0] != '/' {
+			path = "/" + path
+		}
+	}
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	req.URL.Path = strings.TrimRight(u.Path, "/") + path
+	req.URL.RawPath = ""
+	if u.RawQuery != "" {
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = u.RawQuery
+		} else {
+			req.URL.RawQuery = u.RawQuery + "&" + req.URL.RawQuery
+		}
+	}
+	if req.Header.Get("X-Forwarded-Host") == "" {
+		req.Header.Set("X-Forwarded-Host", req.Host)
+	}
+	if req.Header.Get("X-Forwarded-Proto") == "" {
+		if req.TLS != nil {
+			req.Header.Set("X-Forwarded-Proto", "https")
+		} else {
+			req.Header.Set("X-Forwarded-Proto", "http")
+		}
+	}
+	if !p.config.PreserveHost {
+		req.Host = u.Host
+	}
+	for k, v := range p.config.RequestHeaders {
+		if v == "" {
+			req.Header.Del(k)
+		} else {
+			req.Header.Set(k, v)
+		}
+	}
+	// Explicitly disable User-Agent so it's not set to default value.
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "")
+	}
+}
+
+// Serve is the handler function forwarding the request to one of the upstreams.
+// It writes the response of upstream to the buffer of Response, so it composes with
+// middleware like other handlers. The WebSocket request is also passed through.
+//
+// If the upstream fails after its response is partly copied, it panics with
+// http.ErrAbortHandler, which makes the server abort the client connection.
+func (p *Proxy) Serve(r *Request) {
+	var (
+		body    []byte
+		retry   int
+		hashKey string
+		lastErr error
+		tried   = make(map[*proxyUpstream]struct{})
+	)
+	if _, ok := proxyIdempotentMethods[r.Method]; ok {
+		retry = p.config.Retry
+		// The body is cached for replaying if the request is retried.
+		body = r.GetBody()
+	}
+	if p.config.Balance == PROXY_BALANCE_CONSISTENT_HASH {
+		if p.config.HashKey != nil {
+			hashKey = p.config.HashKey(r)
+		} else {
+			hashKey = r.GetClientIp()
+		}
+	}
+	for i := 0; i <= retry; i++ {
+		upstream := p.selectUpstream(hashKey, tried)
+		if upstream == nil {
+			break
+		}
+		tried[upstream] = struct{}{}
+		attempt := &proxyAttempt{}
+		req := r.Request.WithContext(context.WithValue(r.Context(), proxyAttemptCtxKey{}, attempt))
+		if body != nil {
+			req.Body = utils.NewReadCloser(body, false)
+		}
+		p.serveUpstream(upstream, r, req)
+		if attempt.err == nil {
+			return
+		}
+		lastErr = attempt.err
+		// It cannot be retried if the response is already partly wrote.
+		if r.Response.Status != 0 {
+			break
+		}
+		r.Response.ClearBuffer()
+	}
+	if lastErr != nil {
+		r.Server.Logger().Errorf(`[qn_http] proxy "%s" failed: %v`, r.URL.Path, lastErr)
+	}
+	if r.Response.Status == 0 {
+		r.Response.WriteStatus(http.StatusBadGateway)
+	}
+}
+
+// serveUpstream forwards <req> to <upstream>, counting its active connections.
+func (p *Proxy) serveUpstream(upstream *proxyUpstream, r *Request, req *http.Request) {
+	upstream.conns.Add(1)
+	// The proxy panics with http.ErrAbortHandler if copying response body fails.
+	defer upstream.conns.Add(-1)
+	upstream.proxy.ServeHTTP(r.Response.Writer, req)
+}
+
+// Close stops the health checks of the proxy.
+func (p *Proxy) Close() {
+	if p.entry != nil {
+		p.entry.Close()
+	}
+}
+
+// selectUpstream selects an alive upstream that is not in <excluded>.
+func (p *Proxy) selectUpstream(hashKey string, excluded map[*proxyUpstream]struct{}) *proxyUpstream {
+	candidates := make([]*proxyUpstream, 0, len(p.upstreams))
+	for _, upstream := range p.upstreams {
+		if _, ok := excluded[upstream]; ok {
+			continue
+		}
+		if upstream.alive.Val() {
+			candidates = append(candidates, upstream)
+		}
+	}
+	return p.balancer.Select(candidates, hashKey)
+}
+
+// checkHealth checks all upstreams by requesting the health check path.
+// The upstream responding status less than 500 is treated as alive.
+func (p *Proxy) checkHealth() {
+	client := &http.Client{
+		Transport: p.config.Transport,
+		Timeout:   p.config.HealthCheckTimeout,
+	}
+	for _, upstream := range p.upstreams {
+		go func(upstream *proxyUpstream) {
+			alive := false
+			checkUrl := strings.TrimRight(upstream.url.Scheme+"://"+upstream.url.Host+upstream.url.Path, "/")
+			if res, err := client.Get(checkUrl + p.config.HealthCheckPath); err == nil {
+				res.Body.Close()
+				alive = res.StatusCode < http.StatusInternalServerError
+			}
+			upstream.alive.Set(alive)
+		}(upstream)
+	}
+}
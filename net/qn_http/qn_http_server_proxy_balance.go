@@ -0,0 +1,123 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+
+	"github.com/qnsoft/common/container/qn_type"
+)
+
+const (
+	PROXY_BALANCE_ROUND_ROBIN      = "round-robin"     // Selects the upstreams in turn.
+	PROXY_BALANCE_LEAST_CONN       = "least-conn"      // Selects the upstream having the least active requests.
+	PROXY_BALANCE_CONSISTENT_HASH  = "consistent-hash" // Selects the upstream by hash of ProxyConfig.HashKey.
+	gPROXY_HASH_VIRTUAL_NODE_COUNT = 160               // Virtual node count on the hash ring for each upstream.
+)
+
+// proxyBalancer selects an upstream from alive upstreams for request.
+type proxyBalancer interface {
+	// Select returns the selected upstream from <upstreams> using <key>,
+	// the <key> is only used by consistent hash balancer.
+	Select(upstreams []*proxyUpstream, key string) *proxyUpstream
+}
+
+// proxyRoundRobin is the round-robin balancer.
+type proxyRoundRobin struct {
+	counter *qn_type.Uint64
+}
+
+// proxyLeastConn is the least-connections balancer.
+type proxyLeastConn struct{}
+
+// proxyConsistentHash is the consistent hash balancer.
+type proxyConsistentHash struct {
+	hashes []uint32                  // Sorted hash values of virtual nodes.
+	nodes  map[uint32]*proxyUpstream // Virtual node hash to upstream mapping.
+}
+
+// newProxyBalancer creates and returns balancer with given balance <name>.
+// It returns round-robin balancer if <name> is unknown.
+func newProxyBalancer(name string, upstreams []*proxyUpstream) proxyBalancer {
+	switch name {
+	case PROXY_BALANCE_LEAST_CONN:
+		return &proxyLeastConn{}
+	case PROXY_BALANCE_CONSISTENT_HASH:
+		return newProxyConsistentHash(upstreams)
+	default:
+		return &proxyRoundRobin{
+			counter: qn_type.NewUint64(),
+		}
+	}
+}
+
+// Select implements interface proxyBalancer.Select.
+func (b *proxyRoundRobin) Select(upstreams []*proxyUpstream, key string) *proxyUpstream {
+	if len(upstreams) == 0 {
+		return nil
+	}
+	return upstreams[(b.counter.Add(1)-1)%uint64(len(upstreams))]
+}
+
+// Select implements interface proxyBalancer.Select.
+func (b *proxyLeastConn) Select(upstreams []*proxyUpstream, key string) *proxyUpstream {
+	var selected *proxyUpstream
+	for _, upstream := range upstreams {
+		if selected == nil || upstream.conns.Val() < selected.conns.Val() {
+			selected = upstream
+		}
+	}
+	return selected
+}
+
+// newProxyConsistentHash creates the hash ring for all configured upstreams.
+// The ring is never changed, the unavailable upstreams are skipped when selecting,
+// so that the keys of alive upstreams are never remapped.
+func newProxyConsistentHash(upstreams []*proxyUpstream) *proxyConsistentHash {
+	b := &proxyConsistentHash{
+		hashes: make([]uint32, 0, len(upstreams)*gPROXY_HASH_VIRTUAL_NODE_COUNT),
+		nodes:  make(map[uint32]*proxyUpstream),
+	}
+	for _, upstream := range upstreams {
+		for i := 0; i < gPROXY_HASH_VIRTUAL_NODE_COUNT; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + upstream.url.String()))
+			if _, ok := b.nodes[hash]; ok {
+				continue
+			}
+			b.nodes[hash] = upstream
+			b.hashes = append(b.hashes, hash)
+		}
+	}
+	sort.Slice(b.hashes, func(i, j int) bool {
+		return b.hashes[i] < b.hashes[j]
+	})
+	return b
+}
+
+// Select implements interface proxyBalancer.Select.
+func (b *proxyConsistentHash) Select(upstreams []*proxyUpstream, key string) *proxyUpstream {
+	if len(upstreams) == 0 || len(b.hashes) == 0 {
+		return nil
+	}
+	available := make(map[*proxyUpstream]struct{}, len(upstreams))
+	for _, upstream := range upstreams {
+		available[upstream] = struct{}{}
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	index := sort.Search(len(b.hashes), func(i int) bool {
+		return b.hashes[i] >= hash
+	})
+	for i := 0; i < len(b.hashes); i++ {
+		upstream := b.nodes[b.hashes[(index+i)%len(b.hashes)]]
+		if _, ok := available[upstream]; ok {
+			return upstream
+		}
+	}
+	return nil
+}
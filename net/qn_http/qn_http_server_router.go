@@ -46,7 +46,7 @@ func (s *Server) parsePattern(pattern string) (domain, method, path string, err
 			method = v
 		}
 	}
-	if array, err := qn_regex.MatchString(`(.+)@([\w\.\-]+)`, path); len(array) > 1 && err == nil {
+	if array, err := qn_regex.MatchString(`(.+)@([\w\.\-\*]+)`, path); len(array) > 1 && err == nil {
 		path = strings.TrimSpace(array[1])
 		if v := strings.TrimSpace(array[2]); v != "" {
 			domain = v
@@ -106,6 +106,7 @@ func (s *Server) setHandler(pattern string, handler *handlerItem) {
 
 	if _, ok := s.serveTree[domain]; !ok {
 		s.serveTree[domain] = make(map[string]interface{})
+		s.addWildcardDomain(domain)
 	}
 	// List array, very important for router registering.
 	// There may be multiple lists adding into this array when searching from root to leaf.
@@ -0,0 +1,210 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"strings"
+
+	"github.com/qnsoft/common/debug/qn_debug"
+)
+
+const (
+	ROUTE_CHAIN_GLOBAL_MIDDLEWARE = "GLOBAL MIDDLEWARE" // Middleware bound by BindMiddleware/BindMiddlewareDefault.
+	ROUTE_CHAIN_MIDDLEWARE        = "MIDDLEWARE"        // Middleware bound to the route, like group middleware.
+	ROUTE_CHAIN_HANDLER           = "HANDLER"           // The serving handler.
+)
+
+type (
+	// RouterChain is the resolved handler chain of a route, in executing order.
+	RouterChain []RouterChainItem
+
+	// RouterChainItem is an item of the resolved handler chain.
+	RouterChainItem struct {
+		Type   string // Item type, which is ROUTE_CHAIN_* or hook name like HOOK_BEFORE_SERVE.
+		Name   string // Function name of the item.
+		Method string // HTTP method the item is bound to.
+		Route  string // Route pattern the item is bound to.
+		Domain string // Domain the item is bound to.
+		Source string // Source file path:line when registering.
+	}
+)
+
+// utilRouter is the controller for router introspection.
+type utilRouter struct {
+	server *Server
+}
+
+// GetRouteChain resolves and returns the executing chain of hooks, middleware and
+// serving handler for request of <method> and <path>, which is exactly the same as
+// the chain when serving the request.
+// The optional parameter <domain> specifies the requested domain.
+func (s *Server) GetRouteChain(method, path string, domain ...string) RouterChain {
+	d := gDEFAULT_DOMAIN
+	if len(domain) > 0 && domain[0] != "" {
+		d = domain[0]
+	}
+	parsedItems, _, _ := s.searchHandlers(strings.ToUpper(method), path, d)
+	return newRouteChain(parsedItems)
+}
+
+// getRouteChainsByItem returns the executing chains of registered serving handler <handler>
+// by HTTP method. The handler bound to all methods has the chains of all methods, as the hooks
+// and middleware might be bound to specified method.
+func (s *Server) getRouteChainsByItem(handler *handlerItem) map[string]RouterChain {
+	methods := []string{handler.router.Method}
+	if handler.router.Method == gDEFAULT_METHOD {
+		methods = strings.Split(HTTP_METHODS, ",")
+	}
+	chains := make(map[string]RouterChain, len(methods))
+	for _, method := range methods {
+		chains[method] = s.getRouteChainByItem(handler, method)
+	}
+	return chains
+}
+
+// getRouteChainByItem returns the executing chain of registered serving handler <handler>
+// for request of <method>, which contains the hooks and middleware matching the route pattern
+// of the handler. Unlike GetRouteChain, the serving handler of the chain is always <handler>
+// itself, even if the route pattern is also matched by other serving handlers as a path.
+func (s *Server) getRouteChainByItem(handler *handlerItem, method string) RouterChain {
+	searchedItems, _, _ := s.searchHandlers(method, handler.router.Uri, handler.router.Domain)
+	parsedItems := make([]*handlerParsedItem, 0, len(searchedItems)+1)
+	for _, parsedItem := range searchedItems {
+		switch parsedItem.handler.itemType {
+		case gHANDLER_TYPE_HOOK, gHANDLER_TYPE_MIDDLEWARE:
+			parsedItems = append(parsedItems, parsedItem)
+		}
+	}
+	parsedItems = append(parsedItems, &handlerParsedItem{handler, nil})
+	return newRouteChain(parsedItems)
+}
+
+// newRouteChain creates and returns the executing chain of <parsedItems>.
+func newRouteChain(parsedItems []*handlerParsedItem) RouterChain {
+	chain := make(RouterChain, 0, len(parsedItems))
+	chain = appendRouteChainHooks(chain, parsedItems, HOOK_BEFORE_SERVE)
+	for _, parsedItem := range parsedItems {
+		item := parsedItem.handler
+		switch item.itemType {
+		case gHANDLER_TYPE_HOOK:
+			continue
+		case gHANDLER_TYPE_MIDDLEWARE:
+			chain = append(chain, newRouteChainItem(ROUTE_CHAIN_GLOBAL_MIDDLEWARE, item.itemName, item))
+		default:
+			for _, md := range item.middleware {
+				chain = append(chain, newRouteChainItem(ROUTE_CHAIN_MIDDLEWARE, qn_debug.FuncPath(md), item))
+			}
+			chain = append(chain, newRouteChainItem(ROUTE_CHAIN_HANDLER, item.itemName, item))
+		}
+	}
+	chain = appendRouteChainHooks(chain, parsedItems, HOOK_AFTER_SERVE)
+	chain = appendRouteChainHooks(chain, parsedItems, HOOK_BEFORE_OUTPUT)
+	chain = appendRouteChainHooks(chain, parsedItems, HOOK_AFTER_OUTPUT)
+	return chain
+}
+
+// Names returns the function names of the chain, which is convenient for asserting
+// the chain in unit testing, for example:
+// t.Assert(s.GetRouteChain("GET", "/user/1").Names(), g.Slice{...}).
+func (c RouterChain) Names() []string {
+	names := make([]string, len(c))
+	for i, item := range c {
+		names[i] = item.Name
+	}
+	return names
+}
+
+// Middleware returns the function names of global and route middleware in the chain.
+func (c RouterChain) Middleware() []string {
+	names := make([]string, 0)
+	for _, item := range c {
+		if item.Type == ROUTE_CHAIN_GLOBAL_MIDDLEWARE || item.Type == ROUTE_CHAIN_MIDDLEWARE {
+			names = append(names, item.Name)
+		}
+	}
+	return names
+}
+
+// routeChainsMiddleware returns the middleware names of <chains> for dumping, which are
+// grouped by methods if they're different between methods, like: "GET,HEAD:a,b POST:a".
+func routeChainsMiddleware(chains map[string]RouterChain) string {
+	var (
+		methods = make(map[string][]string)
+		names   = make([]string, 0, 1)
+	)
+	for _, method := range strings.Split(HTTP_METHODS, ",") {
+		if chain, ok := chains[method]; ok {
+			name := strings.Join(chain.Middleware(), ",")
+			if _, ok = methods[name]; !ok {
+				names = append(names, name)
+			}
+			methods[name] = append(methods[name], method)
+		}
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	for i, name := range names {
+		names[i] = strings.Join(methods[name], ",") + ":" + name
+	}
+	return strings.Join(names, " ")
+}
+
+// appendRouteChainHooks appends the hooks of <hook> in <parsedItems> to <chain>.
+func appendRouteChainHooks(chain RouterChain, parsedItems []*handlerParsedItem, hook string) RouterChain {
+	for _, parsedItem := range parsedItems {
+		item := parsedItem.handler
+		if item.itemType == gHANDLER_TYPE_HOOK && item.hookName == hook {
+			chain = append(chain, newRouteChainItem(hook, item.itemName, item))
+		}
+	}
+	return chain
+}
+
+// newRouteChainItem creates and returns a chain item for handler <item>.
+func newRouteChainItem(itemType, name string, item *handlerItem) RouterChainItem {
+	chainItem := RouterChainItem{
+		Type:   itemType,
+		Name:   name,
+		Source: item.source,
+	}
+	if item.router != nil {
+		chainItem.Method = item.router.Method
+		chainItem.Route = item.router.Uri
+		chainItem.Domain = item.router.Domain
+	}
+	return chainItem
+}
+
+// EnableRouterInspect enables the JSON endpoint for router introspection.
+// The optional parameter <pattern> specifies the URI for the endpoint, which is
+// "/debug/routes" in default.
+//
+// It responses all routes with their resolved chains, or the chain of specified
+// request if query parameter "path" is given, for example:
+// /debug/routes?method=POST&path=/user/1&domain=example.com.
+func (s *Server) EnableRouterInspect(pattern ...string) {
+	p := "/debug/routes"
+	if len(pattern) > 0 {
+		p = pattern[0]
+	}
+	s.BindHandler(p, (&utilRouter{server: s}).Index)
+}
+
+// Index responses the routes or the chain of specified request as JSON.
+func (u *utilRouter) Index(r *Request) {
+	path := r.GetQueryString("path")
+	if path == "" {
+		r.Response.WriteJson(u.server.GetRouterArray())
+		return
+	}
+	r.Response.WriteJson(u.server.GetRouteChain(
+		r.GetQueryString("method", "GET"),
+		path,
+		r.GetQueryString("domain", r.GetHost()),
+	))
+}
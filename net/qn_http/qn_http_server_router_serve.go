@@ -75,6 +75,13 @@ func (s *Server) searchHandlers(method, path, domain string) (parsedItems []*han
 	parsedItemList := qn_list.New()
 	lastMiddlewareElem := (*qn_list.Element)(nil)
 	repeatHandlerCheckMap := make(map[int]struct{}, 16)
+	// Wildcard domain is searched if there's no exact domain matched.
+	subdomain := ""
+	if _, ok := s.serveTree[domain]; !ok {
+		if v, sub := s.searchWildcardDomain(domain); v != "" {
+			domain, subdomain = v, sub
+		}
+	}
 	// Default domain has the most priority when iteration.
 	for _, domain := range []string{gDEFAULT_DOMAIN, domain} {
 		p, ok := s.serveTree[domain]
@@ -186,6 +193,15 @@ func (s *Server) searchHandlers(method, path, domain string) (parsedItems []*han
 		parsedItems = make([]*handlerParsedItem, parsedItemList.Len())
 		for e := parsedItemList.Front(); e != nil; e = e.Next() {
 			parsedItems[index] = e.Value.(*handlerParsedItem)
+			// The matched subdomain is also a router value, which does not overwrite the one from URI.
+			if subdomain != "" {
+				if parsedItems[index].values == nil {
+					parsedItems[index].values = make(map[string]string, 1)
+				}
+				if _, ok := parsedItems[index].values[gDOMAIN_WILDCARD_ROUTER_NAME]; !ok {
+					parsedItems[index].values[gDOMAIN_WILDCARD_ROUTER_NAME] = subdomain
+				}
+			}
 			index++
 		}
 	}
@@ -0,0 +1,321 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qnsoft/common/os/qn_log"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/os/qn_timer"
+	"github.com/qnsoft/common/util/guid"
+)
+
+const (
+	TUS_VERSION                 = "1.0.0"
+	gTUS_EXTENSIONS             = "creation,termination,expiration"
+	gTUS_CONTENT_TYPE           = "application/offset+octet-stream"
+	gTUS_DEFAULT_EXPIRE         = 24 * time.Hour
+	gTUS_DEFAULT_CHECK_INTERVAL = time.Minute
+)
+
+// TusConfig is the configuration for resumable uploading handler.
+type TusConfig struct {
+	// Storage specifies the storage for partial uploads,
+	// which is TusStorageFile under DefaultTusStorageFilePath in default.
+	Storage TusStorage
+
+	// MaxSize specifies the max size in bytes of an upload, no limit if it's 0.
+	// Note that each PATCH request is also limited by ServerConfig.ClientMaxBodySize.
+	MaxSize int64
+
+	// Expire specifies the duration after which an upload without any update is removed
+	// from the storage, which is sent to client in header "Upload-Expires". It's 24 hours in default.
+	//
+	// Note that the completed uploads are also removed after expired, so OnComplete
+	// should move the content out of the storage if it needs to be kept.
+	Expire time.Duration
+
+	// CheckInterval specifies the interval for expired uploads checks.
+	// It's 1 minute in default.
+	CheckInterval time.Duration
+
+	// OnComplete is called when an upload receives its last chunk.
+	OnComplete func(r *Request, upload *TusUpload)
+}
+
+// TusUpload is the information of a resumable upload.
+type TusUpload struct {
+	Id         string            `json:"id"`         // Unique id of the upload.
+	Size       int64             `json:"size"`       // Total size in bytes.
+	Offset     int64             `json:"offset"`     // Received size in bytes.
+	Metadata   map[string]string `json:"metadata"`   // Decoded metadata from header "Upload-Metadata".
+	CreateTime int64             `json:"createTime"` // Creating timestamp in milliseconds.
+	UpdateTime int64             `json:"updateTime"` // Last updating timestamp in milliseconds.
+}
+
+// TusHandler is the handler bundle implementing tus resumable uploading protocol.
+// See https://tus.io/protocols/resumable-upload.html.
+type TusHandler struct {
+	config  TusConfig
+	entry   *qn_timer.Entry     // Timer entry for expiration checks.
+	mu      sync.Mutex          // Mutex for locking map.
+	locking map[string]struct{} // Uploads that are under writing.
+}
+
+// IsDone checks and returns whether the upload receives all its content.
+func (u *TusUpload) IsDone() bool {
+	return u.Offset >= u.Size
+}
+
+// NewTusHandler creates and returns a resumable uploading handler with given configuration.
+// It starts a timer for removing expired uploads, which should be stopped by Close.
+func NewTusHandler(config ...TusConfig) *TusHandler {
+	h := &TusHandler{
+		locking: make(map[string]struct{}),
+	}
+	if len(config) > 0 {
+		h.config = config[0]
+	}
+	if h.config.Storage == nil {
+		h.config.Storage = NewTusStorageFile()
+	}
+	if h.config.Expire <= 0 {
+		h.config.Expire = gTUS_DEFAULT_EXPIRE
+	}
+	if h.config.CheckInterval <= 0 {
+		h.config.CheckInterval = gTUS_DEFAULT_CHECK_INTERVAL
+	}
+	h.entry = qn_timer.AddSingleton(h.config.CheckInterval, h.clearExpired)
+	return h
+}
+
+// Storage returns the storage of the handler.
+func (h *TusHandler) Storage() TusStorage {
+	return h.config.Storage
+}
+
+// Bind mounts the handlers to given router group.
+// The URL of each upload is the prefix of the group joined with its id.
+func (h *TusHandler) Bind(group *RouterGroup) *RouterGroup {
+	group.OPTIONS("/", h.Options)
+	group.POST("/", h.Create)
+	group.HEAD("/:id", h.Head)
+	group.PATCH("/:id", h.Patch)
+	group.DELETE("/:id", h.Delete)
+	return group
+}
+
+// Close stops the expiration checks of the handler.
+func (h *TusHandler) Close() {
+	h.entry.Close()
+}
+
+// Options responses the protocol information of the server.
+func (h *TusHandler) Options(r *Request) {
+	header := r.Response.Header()
+	header.Set("Tus-Resumable", TUS_VERSION)
+	header.Set("Tus-Version", TUS_VERSION)
+	header.Set("Tus-Extension", gTUS_EXTENSIONS)
+	if h.config.MaxSize > 0 {
+		header.Set("Tus-Max-Size", strconv.FormatInt(h.config.MaxSize, 10))
+	}
+	r.Response.WriteHeader(http.StatusNoContent)
+}
+
+// Create creates a new upload using header "Upload-Length" and "Upload-Metadata".
+func (h *TusHandler) Create(r *Request) {
+	h.checkVersion(r)
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		r.Response.WriteStatusExit(http.StatusBadRequest, "invalid Upload-Length")
+	}
+	if h.config.MaxSize > 0 && size > h.config.MaxSize {
+		r.Response.WriteStatusExit(http.StatusRequestEntityTooLarge)
+	}
+	now := qn_time.TimestampMilli()
+	upload := &TusUpload{
+		Id:         guid.S(),
+		Size:       size,
+		Metadata:   tusParseMetadata(r.Header.Get("Upload-Metadata")),
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	if err = h.config.Storage.Create(upload); err != nil {
+		r.Response.WriteStatusExit(http.StatusInternalServerError, err.Error())
+	}
+	r.Response.Header().Set("Location", strings.TrimRight(r.URL.Path, "/")+"/"+upload.Id)
+	h.setExpires(r, upload)
+	r.Response.WriteHeader(http.StatusCreated)
+	if upload.IsDone() && h.config.OnComplete != nil {
+		h.config.OnComplete(r, upload)
+	}
+}
+
+// Head responses the current offset of the upload.
+func (h *TusHandler) Head(r *Request) {
+	h.checkVersion(r)
+	upload := h.getUpload(r)
+	header := r.Response.Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	h.setExpires(r, upload)
+	r.Response.WriteHeader(http.StatusOK)
+}
+
+// Patch writes the request body to the upload at offset specified by header "Upload-Offset".
+func (h *TusHandler) Patch(r *Request) {
+	h.checkVersion(r)
+	if r.Header.Get("Content-Type") != gTUS_CONTENT_TYPE {
+		r.Response.WriteStatusExit(http.StatusUnsupportedMediaType)
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		r.Response.WriteStatusExit(http.StatusBadRequest, "invalid Upload-Offset")
+	}
+	upload := h.getUpload(r)
+	if !h.lock(upload.Id) {
+		r.Response.WriteStatusExit(http.StatusLocked)
+	}
+	defer h.unlock(upload.Id)
+	// The upload might be changed by another request before locked.
+	upload = h.getUpload(r)
+	if upload.IsDone() {
+		r.Response.WriteStatusExit(http.StatusForbidden, "upload already completed")
+	}
+	if offset != upload.Offset {
+		r.Response.WriteStatusExit(http.StatusConflict)
+	}
+	if _, err = h.config.Storage.WriteChunk(upload.Id, offset, r.Body); err != nil {
+		r.Response.WriteStatusExit(http.StatusInternalServerError, err.Error())
+	}
+	if upload, err = h.config.Storage.Get(upload.Id); err != nil || upload == nil {
+		r.Response.WriteStatusExit(http.StatusInternalServerError)
+	}
+	r.Response.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	h.setExpires(r, upload)
+	r.Response.WriteHeader(http.StatusNoContent)
+	if upload.IsDone() && h.config.OnComplete != nil {
+		h.config.OnComplete(r, upload)
+	}
+}
+
+// Delete terminates the upload and removes it from storage.
+func (h *TusHandler) Delete(r *Request) {
+	h.checkVersion(r)
+	upload := h.getUpload(r)
+	if !h.lock(upload.Id) {
+		r.Response.WriteStatusExit(http.StatusLocked)
+	}
+	defer h.unlock(upload.Id)
+	// The upload might be removed by another request before locked.
+	upload = h.getUpload(r)
+	if err := h.config.Storage.Remove(upload.Id); err != nil {
+		r.Response.WriteStatusExit(http.StatusInternalServerError, err.Error())
+	}
+	r.Response.WriteHeader(http.StatusNoContent)
+}
+
+// checkVersion sets the protocol version header and checks the version of client.
+func (h *TusHandler) checkVersion(r *Request) {
+	r.Response.Header().Set("Tus-Resumable", TUS_VERSION)
+	if v := r.Header.Get("Tus-Resumable"); v != TUS_VERSION {
+		r.Response.Header().Set("Tus-Version", TUS_VERSION)
+		r.Response.WriteStatusExit(http.StatusPreconditionFailed)
+	}
+}
+
+// setExpires sets header "Upload-Expires" of the response, which is the time the <upload> expires.
+func (h *TusHandler) setExpires(r *Request, upload *TusUpload) {
+	expires := time.Unix(0, upload.UpdateTime*1e6).Add(h.config.Expire)
+	r.Response.Header().Set("Upload-Expires", expires.UTC().Format(http.TimeFormat))
+}
+
+// getUpload retrieves the upload by router parameter "id".
+// It exits current handler with status 404 if the upload does not exist.
+func (h *TusHandler) getUpload(r *Request) *TusUpload {
+	upload, err := h.config.Storage.Get(r.GetRouterString("id"))
+	if err != nil {
+		r.Response.WriteStatusExit(http.StatusInternalServerError, err.Error())
+	}
+	if upload == nil {
+		r.Response.WriteStatusExit(http.StatusNotFound)
+	}
+	return upload
+}
+
+// lock marks the upload of <id> under writing.
+// It returns false if the upload is already locked by another request.
+func (h *TusHandler) lock(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.locking[id]; ok {
+		return false
+	}
+	h.locking[id] = struct{}{}
+	return true
+}
+
+// unlock removes the writing mark of the upload of <id>.
+func (h *TusHandler) unlock(id string) {
+	h.mu.Lock()
+	delete(h.locking, id)
+	h.mu.Unlock()
+}
+
+// clearExpired removes the uploads which are not updated in configured duration,
+// including the completed ones.
+func (h *TusHandler) clearExpired() {
+	uploads, err := h.config.Storage.List()
+	if err != nil {
+		qn_log.Error("[qn_http] tus uploads listing failed:", err)
+		return
+	}
+	deadline := qn_time.TimestampMilli() - h.config.Expire.Nanoseconds()/1e6
+	for _, upload := range uploads {
+		if upload.UpdateTime > deadline {
+			continue
+		}
+		id := upload.Id
+		if !h.lock(id) {
+			continue
+		}
+		// The upload might be updated by another request before locked.
+		if upload, err = h.config.Storage.Get(id); err == nil && upload != nil {
+			if upload.UpdateTime <= deadline {
+				if err = h.config.Storage.Remove(id); err != nil {
+					qn_log.Errorf(`[qn_http] tus upload "%s" removing failed: %v`, id, err)
+				}
+			}
+		}
+		h.unlock(id)
+	}
+}
+
+// tusParseMetadata parses the value of header "Upload-Metadata", which is
+// comma-separated key-value pairs and the values are encoded in base64.
+func tusParseMetadata(value string) map[string]string {
+	m := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		array := strings.Fields(item)
+		switch len(array) {
+		case 1:
+			m[array[0]] = ""
+		case 2:
+			if v, err := base64.StdEncoding.DecodeString(array[1]); err == nil {
+				m[array[0]] = string(v)
+			}
+		}
+	}
+	return m
+}
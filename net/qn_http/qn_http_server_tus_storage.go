@@ -0,0 +1,186 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/qnsoft/common/internal/json"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_time"
+)
+
+// TusStorage is the interface definition for storing partial uploads of resumable uploading.
+type TusStorage interface {
+	// Create creates the storage for new <upload>, which has zero offset.
+	Create(upload *TusUpload) error
+
+	// Get retrieves and returns the upload information of <id>.
+	// It returns nil if the upload does not exist.
+	Get(id string) (*TusUpload, error)
+
+	// WriteChunk appends the content from <reader> to the upload of <id> at <offset>,
+	// and returns the written size in bytes. The upload information should be updated
+	// with the new offset after writing.
+	WriteChunk(id string, offset int64, reader io.Reader) (int64, error)
+
+	// Remove removes the upload of <id> from storage.
+	Remove(id string) error
+
+	// List retrieves and returns all the uploads in storage.
+	// It is used for expiration checks.
+	List() ([]*TusUpload, error)
+}
+
+// TusStorageFile implements TusStorage using local disk.
+// Each upload has a data file "<id>.bin" and an information file "<id>.info" in its directory.
+type TusStorageFile struct {
+	path string
+}
+
+const (
+	gTUS_STORAGE_FILE_DATA_EXT = ".bin"
+	gTUS_STORAGE_FILE_INFO_EXT = ".info"
+)
+
+var (
+	// DefaultTusStorageFilePath is the default directory for TusStorageFile.
+	DefaultTusStorageFilePath = qn_file.TempDir("gtus")
+)
+
+// NewTusStorageFile creates and returns a file storage object for resumable uploading.
+// The optional parameter <path> specifies the storage directory, which is
+// DefaultTusStorageFilePath in default.
+func NewTusStorageFile(path ...string) *TusStorageFile {
+	storagePath := DefaultTusStorageFilePath
+	if len(path) > 0 && path[0] != "" {
+		storagePath, _ = qn_file.Search(path[0])
+		if storagePath == "" {
+			panic(fmt.Sprintf("'%s' does not exist", path[0]))
+		}
+		if !qn_file.IsWritable(storagePath) {
+			panic(fmt.Sprintf("'%s' is not writable", path[0]))
+		}
+	}
+	if storagePath != "" {
+		if err := qn_file.Mkdir(storagePath); err != nil {
+			panic(fmt.Sprintf("mkdir '%s' failed: %v", storagePath, err))
+		}
+	}
+	return &TusStorageFile{
+		path: storagePath,
+	}
+}
+
+// dataFilePath returns the data file path for upload of <id>.
+func (s *TusStorageFile) dataFilePath(id string) string {
+	return qn_file.Join(s.path, qn_file.Basename(id)+gTUS_STORAGE_FILE_DATA_EXT)
+}
+
+// infoFilePath returns the information file path for upload of <id>.
+func (s *TusStorageFile) infoFilePath(id string) string {
+	return qn_file.Join(s.path, qn_file.Basename(id)+gTUS_STORAGE_FILE_INFO_EXT)
+}
+
+// DataFilePath returns the data file path for upload of <id>, which can be used
+// to move the file to its final destination after the upload is complete.
+func (s *TusStorageFile) DataFilePath(id string) string {
+	return s.dataFilePath(id)
+}
+
+// Create creates the data and information files for new <upload>.
+func (s *TusStorageFile) Create(upload *TusUpload) error {
+	file, err := qn_file.Create(s.dataFilePath(upload.Id))
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return s.saveInfo(upload)
+}
+
+// Get retrieves and returns the upload information of <id> from its information file.
+func (s *TusStorageFile) Get(id string) (*TusUpload, error) {
+	path := s.infoFilePath(id)
+	if !qn_file.Exists(path) {
+		return nil, nil
+	}
+	upload := new(TusUpload)
+	if err := json.Unmarshal(qn_file.GetBytes(path), upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// WriteChunk appends the content from <reader> to the data file of <id>.
+func (s *TusStorageFile) WriteChunk(id string, offset int64, reader io.Reader) (int64, error) {
+	upload, err := s.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	if upload == nil {
+		return 0, errors.New(fmt.Sprintf(`upload "%s" does not exist`, id))
+	}
+	file, err := qn_file.OpenWithFlagPerm(s.dataFilePath(id), os.O_WRONLY, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	// The content exceeding the upload size is never written.
+	var remaining = upload.Size - offset
+	if remaining < 0 {
+		remaining = 0
+	}
+	// The written content is kept even if the copying fails in the middle,
+	// so that the client can resume from the new offset.
+	n, err := io.Copy(file, io.LimitReader(reader, remaining))
+	upload.Offset = offset + n
+	upload.UpdateTime = qn_time.TimestampMilli()
+	if saveErr := s.saveInfo(upload); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return n, err
+}
+
+// Remove deletes the data and information files of <id>.
+func (s *TusStorageFile) Remove(id string) error {
+	if err := qn_file.Remove(s.infoFilePath(id)); err != nil {
+		return err
+	}
+	return qn_file.Remove(s.dataFilePath(id))
+}
+
+// List retrieves and returns all the uploads by scanning the information files.
+func (s *TusStorageFile) List() ([]*TusUpload, error) {
+	files, err := qn_file.ScanDirFile(s.path, "*"+gTUS_STORAGE_FILE_INFO_EXT)
+	if err != nil {
+		return nil, err
+	}
+	uploads := make([]*TusUpload, 0, len(files))
+	for _, path := range files {
+		upload, err := s.Get(qn_file.Name(path))
+		if err != nil || upload == nil {
+			continue
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
+
+// saveInfo writes the information of <upload> to its information file.
+func (s *TusStorageFile) saveInfo(upload *TusUpload) error {
+	content, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return qn_file.PutBytes(s.infoFilePath(upload.Id), content)
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_breaker"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_Breaker(t *testing.T) {
+	var (
+		p, _    = ports.PopRand()
+		s       = g.Server(p)
+		counter = qn_type.NewInt()
+	)
+	s.BindHandler("/fail", func(r *qn_http.Request) {
+		counter.Add(1)
+		r.Response.WriteStatus(500)
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		group := qn_breaker.NewGroup(qn_breaker.Config{
+			ConsecutiveFailures: 2,
+			CoolDown:            time.Minute,
+		})
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.SetBreaker(group)
+		for i := 0; i < 2; i++ {
+			r, err := client.Get("/fail")
+			t.Assert(err, nil)
+			t.Assert(r.StatusCode, 500)
+			r.Close()
+		}
+		_, err := client.Get("/fail")
+		t.Assert(err, qn_breaker.ErrOpen)
+		t.Assert(counter.Val(), 2)
+		t.Assert(group.Get(fmt.Sprintf("127.0.0.1:%d", p)).State(), qn_breaker.STATE_OPEN)
+	})
+}
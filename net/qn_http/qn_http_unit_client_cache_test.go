@@ -0,0 +1,123 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/os/qn_cache"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_Cache(t *testing.T) {
+	var (
+		p, _    = ports.PopRand()
+		s       = g.Server(p)
+		counter = qn_type.NewInt()
+	)
+	s.BindHandler("/max-age", func(r *qn_http.Request) {
+		if r.Method == "GET" {
+			r.Response.Header().Set("Cache-Control", "max-age=60")
+			r.Response.Header().Set("Vary", "X-Lang")
+		}
+		r.Response.Write(counter.Add(1))
+	})
+	s.BindHandler("/etag", func(r *qn_http.Request) {
+		counter.Add(1)
+		r.Response.Header().Set("Cache-Control", "no-cache")
+		r.Response.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			r.Response.WriteHeader(http.StatusNotModified)
+			return
+		}
+		r.Response.Write("etag")
+	})
+	s.BindHandler("/set-cookie", func(r *qn_http.Request) {
+		r.Response.Header().Set("Cache-Control", "max-age=60")
+		r.Cookie.Set("id", "1")
+		r.Response.Write(counter.Add(1))
+	})
+	s.BindHandler("/no-store", func(r *qn_http.Request) {
+		r.Response.Header().Set("Cache-Control", "no-store")
+		r.Response.Write(counter.Add(1))
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		counter.Set(0)
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.SetCache(qn_cache.New(100))
+
+		r, err := client.Get("/max-age")
+		t.Assert(err, nil)
+		t.Assert(r.ReadAllString(), "1")
+		t.Assert(r.CacheStatus(), qn_http.CLIENT_CACHE_MISS)
+		r.Close()
+
+		r, err = client.Get("/max-age")
+		t.Assert(err, nil)
+		t.Assert(r.ReadAllString(), "1")
+		t.Assert(r.CacheStatus(), qn_http.CLIENT_CACHE_HIT)
+		r.Close()
+
+		// Vary header.
+		t.Assert(client.Header(g.MapStrStr{"X-Lang": "en"}).GetContent("/max-age"), "2")
+
+		// Unsafe request invalidates the entry.
+		t.Assert(client.PostContent("/max-age"), "3")
+		t.Assert(client.GetContent("/max-age"), "4")
+		t.Assert(client.GetContent("/max-age"), "4")
+
+		// Partial, conditional and authorized requests bypass the cache.
+		r, err = client.Header(g.MapStrStr{"Range": "bytes=0-0"}).Get("/max-age")
+		t.Assert(err, nil)
+		t.Assert(r.CacheStatus(), "")
+		r.Close()
+		t.Assert(client.Header(g.MapStrStr{"If-None-Match": `"v0"`}).GetContent("/max-age"), "6")
+		t.Assert(client.Header(g.MapStrStr{"Authorization": "Bearer token"}).GetContent("/max-age"), "7")
+		t.Assert(client.Cookie(g.MapStrStr{"id": "1"}).GetContent("/max-age"), "8")
+		t.Assert(client.GetContent("/max-age"), "4")
+
+		// The response setting cookie is not stored.
+		t.Assert(client.GetContent("/set-cookie"), "9")
+		t.Assert(client.GetContent("/set-cookie"), "10")
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		counter.Set(0)
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.SetCache(qn_cache.New(100))
+
+		r, err := client.Get("/etag")
+		t.Assert(err, nil)
+		t.Assert(r.ReadAllString(), "etag")
+		t.Assert(r.CacheStatus(), qn_http.CLIENT_CACHE_MISS)
+		r.Close()
+
+		r, err = client.Get("/etag")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 200)
+		t.Assert(r.ReadAllString(), "etag")
+		t.Assert(r.CacheStatus(), qn_http.CLIENT_CACHE_REVALIDATED)
+		r.Close()
+		t.Assert(counter.Val(), 2)
+
+		t.Assert(client.GetContent("/no-store"), "3")
+		t.Assert(client.GetContent("/no-store"), "4")
+	})
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_CookieJar(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/set", func(r *qn_http.Request) {
+		r.Cookie.SetCookie("root", "1", "", "/", time.Hour)
+		r.Cookie.SetCookie("admin", "2", "", "/admin", time.Hour)
+	})
+	s.BindHandler("/get", func(r *qn_http.Request) {
+		r.Response.Write(r.Cookie.Get("root"), ",", r.Cookie.Get("admin"))
+	})
+	s.BindHandler("/admin/get", func(r *qn_http.Request) {
+		r.Response.Write(r.Cookie.Get("root"), ",", r.Cookie.Get("admin"))
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		path := qn_file.Join(qn_file.TempDir(), qn_time.TimestampNanoStr()+".json")
+		defer qn_file.Remove(path)
+
+		jar := qn_http.NewClientCookieJar()
+		client := qn_http.NewClient()
+		client.SetCookieJar(jar)
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/set"), "")
+		t.Assert(client.GetContent("/get"), "1,")
+		t.Assert(client.GetContent("/admin/get"), "1,2")
+		t.Assert(jar.Save(path), nil)
+
+		// Another client resumes the sessions from file.
+		newJar := qn_http.NewClientCookieJar()
+		t.Assert(newJar.Load(path), nil)
+		newClient := qn_http.NewClient()
+		newClient.SetCookieJar(newJar)
+		newClient.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(newClient.GetContent("/get"), "1,")
+		t.Assert(newClient.GetContent("/admin/get"), "1,2")
+
+		newJar.Clear()
+		t.Assert(newClient.GetContent("/admin/get"), ",")
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/set"), "")
+		t.Assert(client.GetContent("/get"), ",")
+
+		client.SetBrowserMode(true)
+		t.Assert(client.GetContent("/set"), "")
+		t.Assert(client.GetContent("/admin/get"), "1,2")
+		t.Assert(qn_http.NewClientCookieJar().Load("/none-exist-file"), nil)
+	})
+}
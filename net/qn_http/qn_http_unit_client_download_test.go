@@ -0,0 +1,157 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_Download(t *testing.T) {
+	var (
+		dir       = qn_file.TempDir(qn_time.TimestampNanoStr())
+		srcPath   = qn_file.Join(dir, "src.txt")
+		content   = strings.Repeat("0123456789", 1000)
+		md5Sum    = md5.Sum([]byte(content))
+		sha256Sum = sha256.Sum256([]byte(content))
+	)
+	defer qn_file.Remove(dir)
+	qn_file.PutContents(srcPath, content)
+
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/download", func(r *qn_http.Request) {
+		r.Response.ServeFile(srcPath)
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		path := qn_file.Join(dir, "single.txt")
+		err := client.Download("/download", path, qn_http.DownloadOptions{
+			Checksum: "md5:" + hex.EncodeToString(md5Sum[:]),
+		})
+		t.Assert(err, nil)
+		t.Assert(qn_file.GetContents(path), content)
+		t.Assert(qn_file.Exists(path+".download"), false)
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		var (
+			path        = qn_file.Join(dir, "parallel.txt")
+			transferred = qn_type.NewInt64()
+		)
+		err := client.Download("/download", path, qn_http.DownloadOptions{
+			Parallel: 4,
+			Checksum: "sha256:" + hex.EncodeToString(sha256Sum[:]),
+			Progress: func(n, total int64) {
+				if n > transferred.Val() {
+					transferred.Set(n)
+				}
+			},
+		})
+		t.Assert(err, nil)
+		t.Assert(qn_file.GetContents(path), content)
+		t.Assert(transferred.Val(), len(content))
+		for i := 0; i < 4; i++ {
+			t.Assert(qn_file.Exists(fmt.Sprintf("%s.download.%d", path, i)), false)
+		}
+	})
+	// Resuming.
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		r, err := client.Get("/download")
+		t.Assert(err, nil)
+		lastModified := r.Header.Get("Last-Modified")
+		r.Close()
+		t.AssertNE(lastModified, "")
+
+		path := qn_file.Join(dir, "resume.txt")
+		qn_file.PutContents(path+".download", content[:1234])
+		qn_file.PutContents(path+".download.validator", lastModified)
+		err = client.Download("/download", path, qn_http.DownloadOptions{
+			Resume: true,
+		})
+		t.Assert(err, nil)
+		t.Assert(qn_file.GetContents(path), content)
+		t.Assert(qn_file.Exists(path+".download.validator"), false)
+
+		qn_file.PutContents(fmt.Sprintf("%s.download.%d", path, 1), content[2500:3000])
+		qn_file.PutContents(path+".download.validator", lastModified)
+		err = client.Download("/download", path, qn_http.DownloadOptions{
+			Resume:   true,
+			Parallel: 4,
+		})
+		t.Assert(err, nil)
+		t.Assert(qn_file.GetContents(path), content)
+
+		// The completely downloaded temporary file.
+		qn_file.PutContents(path+".download", content)
+		qn_file.PutContents(path+".download.validator", lastModified)
+		err = client.Download("/download", path, qn_http.DownloadOptions{
+			Resume: true,
+		})
+		t.Assert(err, nil)
+		t.Assert(qn_file.GetContents(path), content)
+	})
+	// Resuming with changed content, which is downloaded from the beginning.
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		path := qn_file.Join(dir, "changed.txt")
+		qn_file.PutContents(path+".download", "old content")
+		qn_file.PutContents(path+".download.validator", "Mon, 02 Jan 2006 15:04:05 GMT")
+		err := client.Download("/download", path, qn_http.DownloadOptions{
+			Resume: true,
+		})
+		t.Assert(err, nil)
+		t.Assert(qn_file.GetContents(path), content)
+
+		// Longer temporary file than the content.
+		qn_file.PutContents(path+".download", content+"old")
+		qn_file.PutContents(path+".download.validator", "Mon, 02 Jan 2006 15:04:05 GMT")
+		err = client.Download("/download", path, qn_http.DownloadOptions{
+			Resume: true,
+		})
+		t.Assert(err, nil)
+		t.Assert(qn_file.GetContents(path), content)
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		path := qn_file.Join(dir, "mismatch.txt")
+		t.AssertNE(client.Download("/download", path, qn_http.DownloadOptions{Checksum: "md5:none"}), nil)
+		t.Assert(qn_file.Exists(path), false)
+		t.Assert(qn_file.Exists(path+".download"), false)
+		t.AssertNE(client.Download("/download", path, qn_http.DownloadOptions{Checksum: "crc:none"}), nil)
+		t.AssertNE(client.Download("/none", path), nil)
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_Middleware(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/sign", func(r *qn_http.Request) {
+		r.Response.Write(r.Header.Get("X-Sign"), ":", r.Header.Get("X-Token"))
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		var (
+			order  = make([]string, 0)
+			client = qn_http.NewClient()
+		)
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.Use(func(c *qn_http.Client, req *http.Request, next qn_http.ClientHandlerFunc) (*qn_http.ClientResponse, error) {
+			order = append(order, "1-before")
+			// The request is prepared with custom header.
+			req.Header.Set("X-Sign", "sign-"+req.Header.Get("X-Token"))
+			resp, err := next(req)
+			order = append(order, "1-after")
+			return resp, err
+		})
+		client.Use(func(c *qn_http.Client, req *http.Request, next qn_http.ClientHandlerFunc) (*qn_http.ClientResponse, error) {
+			order = append(order, "2-before")
+			resp, err := next(req)
+			order = append(order, "2-after")
+			return resp, err
+		})
+		// The middleware is inherited by chaining function.
+		t.Assert(client.Header(g.MapStrStr{"X-Token": "abc"}).GetContent("/sign"), "sign-abc:abc")
+		t.Assert(order, g.Slice{"1-before", "2-before", "2-after", "1-after"})
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.Use(func(c *qn_http.Client, req *http.Request, next qn_http.ClientHandlerFunc) (*qn_http.ClientResponse, error) {
+			req.Header.Set("X-Sign", "parent")
+			return next(req)
+		})
+		// Middleware added to the cloned client does not affect the parent.
+		cloned := client.Clone()
+		cloned.Use(func(c *qn_http.Client, req *http.Request, next qn_http.ClientHandlerFunc) (*qn_http.ClientResponse, error) {
+			req.Header.Set("X-Token", "cloned")
+			return next(req)
+		})
+		t.Assert(cloned.GetContent("/sign"), "parent:cloned")
+		t.Assert(client.GetContent("/sign"), "parent:")
+	})
+}
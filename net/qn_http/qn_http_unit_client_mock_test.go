@@ -0,0 +1,102 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_Mock(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		mock := qn_http.NewMockTransport()
+		mock.On("GET", "http://api.test/user/*").Reply(200, g.Map{"name": "john"})
+		mock.On("POST", "http://api.test/user").WithBodyContains("name=smith").Reply(201, "created").Times(1)
+		mock.On("", "http://api.test/error").ReplyError(errors.New("network error"))
+
+		client := qn_http.NewClient()
+		client.SetPrefix("http://api.test")
+		client.SetTransport(mock)
+
+		r, err := client.Get("/user/1")
+		t.Assert(err, nil)
+		t.Assert(r.Header.Get("Content-Type"), "application/json")
+		t.Assert(r.ReadAllString(), `{"name":"john"}`)
+		r.Close()
+
+		t.Assert(mock.Pending(), g.Slice{"POST http://api.test/user"})
+		t.Assert(client.PostContent("/user", "name=smith"), "created")
+		t.Assert(len(mock.Pending()), 0)
+
+		// Matched max times.
+		_, err = client.Post("/user", "name=smith")
+		t.AssertNE(err, nil)
+		_, err = client.Post("/user", "name=john")
+		t.AssertNE(err, nil)
+		_, err = client.Delete("/error")
+		t.AssertNE(err, nil)
+	})
+}
+
+func Test_Client_Recorder(t *testing.T) {
+	var (
+		p, _    = ports.PopRand()
+		s       = g.Server(p)
+		counter = qn_type.NewInt()
+	)
+	s.BindHandler("/hello", func(r *qn_http.Request) {
+		counter.Add(1)
+		r.Response.Header().Set("X-Name", r.GetString("name"))
+		r.Response.Write("hello ", r.GetString("name"))
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		dir := qn_file.TempDir(qn_time.TimestampNanoStr())
+		defer qn_file.Remove(dir)
+
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		// Replaying without fixture.
+		client.SetTransport(qn_http.NewClientRecorder(dir, qn_http.RECORDER_MODE_REPLAY))
+		_, err := client.Get("/hello?name=john")
+		t.AssertNE(err, nil)
+
+		// Recording.
+		client.SetTransport(qn_http.NewClientRecorder(dir, qn_http.RECORDER_MODE_AUTO))
+		t.Assert(client.GetContent("/hello?name=john"), "hello john")
+		t.Assert(client.PostContent("/hello", "name=smith"), "hello smith")
+		files, _ := qn_file.ScanDirFile(dir, "*.txt")
+		t.Assert(len(files), 2)
+		t.Assert(counter.Val(), 2)
+
+		// Replaying without sending requests.
+		client.SetTransport(qn_http.NewClientRecorder(dir, qn_http.RECORDER_MODE_REPLAY))
+		r, err := client.Get("/hello?name=john")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 200)
+		t.Assert(r.Header.Get("X-Name"), "john")
+		t.Assert(r.ReadAllString(), "hello john")
+		r.Close()
+		t.Assert(client.PostContent("/hello", "name=smith"), "hello smith")
+		t.Assert(counter.Val(), 2)
+	})
+}
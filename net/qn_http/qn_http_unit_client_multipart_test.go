@@ -0,0 +1,117 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/debug/qn_debug"
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_Multipart(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/upload", func(r *qn_http.Request) {
+		var (
+			file     = r.GetUploadFile("file")
+			reader   = r.GetUploadFile("reader")
+			contents = make([]string, 0)
+		)
+		for _, f := range []*qn_http.UploadFile{file, reader} {
+			if f == nil {
+				r.Response.WriteExit("upload file cannot be empty")
+			}
+			rc, _ := f.Open()
+			b, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			contents = append(contents, f.Filename+":"+string(b))
+		}
+		r.Response.Write(r.GetString("name"), "|", strings.Join(contents, "|"))
+	})
+	s.BindHandler("/part", func(r *qn_http.Request) {
+		r.Response.Write(r.GetUploadFile("data").Header.Get("Content-Type"))
+	})
+	s.BindHandler("/body", func(r *qn_http.Request) {
+		r.Response.Write(r.GetBodyString())
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		path := qn_debug.TestDataPath("upload", "file1.txt")
+		m := qn_http.NewMultipart()
+		m.AddField("name", "@file:john")
+		m.AddFile("file", path)
+		m.AddReader("reader", "reader.txt", strings.NewReader("reader"))
+		t.Assert(
+			client.PostContent("/upload", m),
+			fmt.Sprintf("@file:john|file1.txt:%s|reader.txt:reader", qn_file.GetContents(path)),
+		)
+
+		// The custom Content-Type does not overwrite the boundary of multipart body.
+		m = qn_http.NewMultipart()
+		m.AddField("name", "john")
+		m.AddFile("file", path)
+		m.AddReader("reader", "reader.txt", strings.NewReader("reader"))
+		t.Assert(
+			client.ContentJson().PostContent("/upload", m),
+			fmt.Sprintf("john|file1.txt:%s|reader.txt:reader", qn_file.GetContents(path)),
+		)
+
+		m = qn_http.NewMultipart().AddFile("file", "/none-exist-file")
+		_, err := client.Post("/upload", m)
+		t.AssertNE(err, nil)
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="data"; filename="data.json"`)
+		header.Set("Content-Type", "application/json")
+		m := qn_http.NewMultipart().AddPart(header, strings.NewReader(`{"id":1}`))
+		t.Assert(client.PostContent("/part", m), "application/json")
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		var (
+			sent   = qn_type.NewInt64()
+			total  = qn_type.NewInt64()
+			client = qn_http.NewClient()
+		)
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.SetUploadProgress(func(n, size int64) {
+			sent.Set(n)
+			total.Set(size)
+		})
+		// Streaming body of unknown size.
+		reader := ioutil.NopCloser(strings.NewReader("streaming"))
+		t.Assert(client.PostContent("/body", reader), "streaming")
+		t.Assert(sent.Val(), 9)
+		t.Assert(total.Val(), -1)
+
+		// Body of known size.
+		t.Assert(client.PostContent("/body", strings.NewReader("known")), "known")
+		t.Assert(sent.Val(), 5)
+		t.Assert(total.Val(), 5)
+	})
+}
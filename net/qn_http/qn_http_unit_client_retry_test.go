@@ -0,0 +1,72 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_RetryPolicy(t *testing.T) {
+	var (
+		p, _    = ports.PopRand()
+		s       = g.Server(p)
+		counter = qn_type.NewInt()
+	)
+	// It fails for the first two requests of every three requests.
+	s.BindHandler("/retry", func(r *qn_http.Request) {
+		if counter.Add(1)%3 != 0 {
+			r.Response.Header().Set("Retry-After", "0")
+			r.Response.WriteStatusExit(503)
+		}
+		r.Response.Write(r.GetBodyString())
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.SetRetryPolicy(qn_http.RetryPolicy{
+			Count:    2,
+			Interval: 10 * time.Millisecond,
+			Jitter:   0.5,
+		})
+		// The retry count is not shared between requests, and the body is replayed.
+		t.Assert(client.PutContent("/retry", "a"), "a")
+		t.Assert(counter.Val(), 3)
+		t.Assert(client.PutContent("/retry", "b"), "b")
+		t.Assert(counter.Val(), 6)
+
+		// Non-idempotent request is not retried.
+		r, err := client.Post("/retry", "c")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 503)
+		r.Close()
+		t.Assert(counter.Val(), 7)
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		counter.Set(0)
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		// The retry budget is exhausted.
+		r, err := client.RetryPolicy(qn_http.RetryPolicy{Count: 1}).Get("/retry")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 503)
+		r.Close()
+		t.Assert(counter.Val(), 2)
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_Scan(t *testing.T) {
+	type User struct {
+		Id   int
+		Name string
+	}
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/json", func(r *qn_http.Request) {
+		r.Response.WriteJson(g.Map{"id": r.GetInt("id"), "name": r.GetString("name")})
+	})
+	s.BindHandler("/xml", func(r *qn_http.Request) {
+		r.Response.WriteXml(g.Map{"id": 2, "name": "john"}, "user")
+	})
+	s.BindHandler("/text", func(r *qn_http.Request) {
+		r.Response.Write("text")
+	})
+	s.BindHandler("/error", func(r *qn_http.Request) {
+		r.Response.WriteStatus(404, "user not found")
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		var user *User
+		t.Assert(client.GetJson("/json", &user, "id=1&name=john"), nil)
+		t.Assert(user, &User{Id: 1, Name: "john"})
+
+		user = nil
+		t.Assert(client.PostJson("/json", &user, g.Map{"id": 3, "name": "smith"}), nil)
+		t.Assert(user, &User{Id: 3, Name: "smith"})
+
+		user = nil
+		t.Assert(client.GetJson("/xml", &user), nil)
+		t.Assert(user, &User{Id: 2, Name: "john"})
+
+		var users []User
+		t.AssertNE(client.GetJson("/xml", &users), nil)
+
+		t.AssertNE(client.GetJson("/text", &user), nil)
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+
+		var user *User
+		err := client.GetJson("/error", &user)
+		t.AssertNE(err, nil)
+		clientErr, ok := err.(*qn_http.ClientError)
+		t.Assert(ok, true)
+		t.Assert(clientErr.StatusCode, 404)
+		t.Assert(clientErr.Method, "GET")
+		t.Assert(clientErr.Url, fmt.Sprintf("http://127.0.0.1:%d/error", p))
+		t.Assert(clientErr.Body, "user not found")
+		t.Assert(user, nil)
+	})
+}
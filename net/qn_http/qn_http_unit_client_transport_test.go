@@ -0,0 +1,123 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/debug/qn_debug"
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Client_Transport(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/test", func(r *qn_http.Request) {
+		r.Response.Write("test")
+	})
+	s.EnableHTTPS(
+		qn_debug.TestDataPath("https", "server.crt"),
+		qn_debug.TestDataPath("https", "server.key"),
+	)
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("https://127.0.0.1:%d", p))
+		client.SetTransportConfig(qn_http.ClientTransportConfig{
+			DialTimeout:         time.Second,
+			MaxIdleConnsPerHost: 10,
+		})
+		client.SetKeepAlive(true)
+		t.Assert(client.GetContent("/test"), "test")
+
+		// The self-signed certificate of the server is not trusted,
+		// and configuring the cloned client does not affect the parent.
+		cloned := client.Clone()
+		cloned.SetTLSVerify(true)
+		_, err := cloned.Get("/test")
+		t.AssertNE(err, nil)
+		t.Assert(client.GetContent("/test"), "test")
+
+		// The transport is cloned only once for the cloned client.
+		transport := cloned.Transport
+		cloned.SetKeepAlive(false)
+		t.Assert(cloned.Transport == transport, true)
+		t.Assert(client.Transport == transport, false)
+	})
+	// The client certificate enables the verification.
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("https://127.0.0.1:%d", p))
+		t.Assert(client.SetClientCert(
+			qn_debug.TestDataPath("https", "server.crt"),
+			qn_debug.TestDataPath("https", "server.key"),
+		), nil)
+		_, err := client.Get("/test")
+		t.AssertNE(err, nil)
+		t.Assert(client.SetTLSVerify(false).GetContent("/test"), "test")
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		t.AssertNE(client.SetProxy("ftp://127.0.0.1:21"), nil)
+		t.Assert(client.SetProxy("socks5://127.0.0.1:1080"), nil)
+		t.Assert(client.SetProxy(""), nil)
+		t.AssertNE(client.AddRootCA(qn_debug.TestDataPath("https", "server.key")), nil)
+		t.AssertNE(client.SetClientCert("none.crt", "none.key"), nil)
+
+		// The nil TLS configuration is normalized.
+		client.SetTLSConfig(nil)
+		client.SetTLSVerify(true)
+	})
+	// The transport which is not *http.Transport is left alone.
+	qn_test.C(t, func(t *qn_test.T) {
+		mock := qn_http.NewMockTransport()
+		mock.On("GET", "http://api.test/test").Reply(200, "mock")
+		client := qn_http.NewClient()
+		client.SetTransport(mock)
+		client.SetTLSVerify(true).SetKeepAlive(true).SetTLSConfig(nil)
+		t.AssertNE(client.SetProxy("socks5://127.0.0.1:1080"), nil)
+		t.Assert(client.Transport == mock, true)
+		t.Assert(client.GetContent("http://api.test/test"), "mock")
+	})
+}
+
+func Test_Client_SecureMode(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/test", func(r *qn_http.Request) {
+		r.Response.Write("test")
+	})
+	s.EnableHTTPS(
+		qn_debug.TestDataPath("https", "server.crt"),
+		qn_debug.TestDataPath("https", "server.key"),
+	)
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		qn_http.SetClientSecureMode(true)
+		defer qn_http.SetClientSecureMode(false)
+
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("https://127.0.0.1:%d", p))
+		_, err := client.Get("/test")
+		t.AssertNE(err, nil)
+		t.Assert(client.SetTLSVerify(false).GetContent("/test"), "test")
+	})
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_array"
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Health_Basic(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	healthy := qn_type.NewBool(true)
+	s.AddReadinessCheck("db", func() error {
+		if !healthy.Val() {
+			return errors.New("db down")
+		}
+		return nil
+	})
+	s.EnableHealth("/status")
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/status/healthz"), `{"checks":{},"status":"ok"}`)
+		t.Assert(client.GetContent("/status/readyz"), `{"checks":{"db":"ok"},"status":"ok"}`)
+
+		healthy.Set(false)
+		r, err := client.Get("/status/readyz")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 503)
+		t.Assert(r.ReadAllString(), `{"checks":{"db":"db down"},"status":"failed"}`)
+		r.Close()
+
+		// Readiness only check does not affect liveness.
+		r, err = client.Get("/status/healthz")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 200)
+		r.Close()
+	})
+}
+
+func Test_Drain_Basic(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	phases := qn_array.NewStrArray(true)
+	s.BindDrainHook(qn_http.DRAIN_PHASE_START, func(s *qn_http.Server, phase string) {
+		phases.Append(phase)
+	})
+	s.BindDrainHook(qn_http.DRAIN_PHASE_SHUTDOWN, func(s *qn_http.Server, phase string) {
+		phases.Append(phase)
+	})
+	s.BindDrainHook(qn_http.DRAIN_PHASE_DONE, func(s *qn_http.Server, phase string) {
+		phases.Append(phase)
+	})
+	s.BindHandler("/slow", func(r *qn_http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		r.Response.Write("done")
+	})
+	s.EnableHealth()
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.SetDrainGracePeriod(300 * time.Millisecond)
+	s.SetShutdownTimeout(3 * time.Second)
+	s.Start()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/readyz"), `{"checks":{},"status":"ok"}`)
+
+		result := make(chan error)
+		go func() {
+			result <- s.Drain()
+		}()
+		time.Sleep(100 * time.Millisecond)
+		t.Assert(s.IsDraining(), true)
+
+		// The server still serves in the grace period, but it's not ready.
+		r, err := client.Get("/readyz")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 503)
+		t.Assert(r.ReadAllString(), `{"checks":{},"status":"draining"}`)
+		r.Close()
+
+		// The in-flight request is done before shutdown.
+		t.Assert(client.GetContent("/slow"), "done")
+
+		t.Assert(<-result, nil)
+		t.Assert(phases.Slice(), g.Slice{
+			qn_http.DRAIN_PHASE_START,
+			qn_http.DRAIN_PHASE_SHUTDOWN,
+			qn_http.DRAIN_PHASE_DONE,
+		})
+		t.Assert(len(s.GetInflightRequests()), 0)
+	})
+}
+
+func Test_Drain_Signal(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	phases := qn_array.NewStrArray(true)
+	for _, phase := range []string{
+		qn_http.DRAIN_PHASE_START,
+		qn_http.DRAIN_PHASE_SHUTDOWN,
+		qn_http.DRAIN_PHASE_DONE,
+	} {
+		s.BindDrainHook(phase, func(s *qn_http.Server, phase string) {
+			phases.Append(phase)
+		})
+	}
+	s.EnableHealth()
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.SetDrainGracePeriod(300 * time.Millisecond)
+	s.SetShutdownTimeout(3 * time.Second)
+	s.Start()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/readyz"), `{"checks":{},"status":"ok"}`)
+
+		// Shutting down by signal like SIGTERM drains the servers.
+		done := make(chan struct{})
+		go func() {
+			qn_http.ShutdownWebServers("terminated")
+			close(done)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		t.Assert(s.IsDraining(), true)
+		r, err := client.Get("/readyz")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 503)
+		r.Close()
+
+		<-done
+		t.Assert(phases.Slice(), g.Slice{
+			qn_http.DRAIN_PHASE_START,
+			qn_http.DRAIN_PHASE_SHUTDOWN,
+			qn_http.DRAIN_PHASE_DONE,
+		})
+		_, err = client.Get("/readyz")
+		t.AssertNE(err, nil)
+	})
+}
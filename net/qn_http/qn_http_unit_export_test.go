@@ -0,0 +1,14 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http
+
+// ShutdownWebServers exports shutdownWebServers for unit testing, which resets the process
+// status after shutting down, so that the other test cases are not affected.
+func ShutdownWebServers(signal string) {
+	shutdownWebServers(signal)
+	serverProcessStatus.Set(gADMIN_ACTION_NONE)
+}
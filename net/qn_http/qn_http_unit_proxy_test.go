@@ -0,0 +1,174 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Proxy_Balance(t *testing.T) {
+	upstreams := make([]string, 0)
+	for _, name := range []string{"a", "b"} {
+		name := name
+		p, _ := ports.PopRand()
+		s := g.Server(p)
+		s.BindHandler("/user/info", func(r *qn_http.Request) {
+			r.Response.Write(name, r.Header.Get("X-Proxy"))
+		})
+		s.SetPort(p)
+		s.SetDumpRouterMap(false)
+		s.Start()
+		defer s.Shutdown()
+		upstreams = append(upstreams, fmt.Sprintf("http://127.0.0.1:%d", p))
+	}
+	proxy, err := qn_http.NewProxy(qn_http.ProxyConfig{
+		Upstreams:   upstreams,
+		StripPrefix: "/api",
+		RequestHeaders: map[string]string{
+			"X-Proxy": "1",
+		},
+		ResponseHeaders: map[string]string{
+			"X-Upstream": "test",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.Group("/api", func(group *qn_http.RouterGroup) {
+		group.Middleware(func(r *qn_http.Request) {
+			r.Middleware.Next()
+			r.Response.Write("|")
+		})
+		group.ALL("/*any", proxy.Serve)
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/api/user/info"), "a1|")
+		t.Assert(client.GetContent("/api/user/info"), "b1|")
+		t.Assert(client.GetContent("/api/user/info"), "a1|")
+
+		r, err := client.Get("/api/user/info")
+		t.Assert(err, nil)
+		t.Assert(r.Header.Get("X-Upstream"), "test")
+		r.Close()
+	})
+}
+
+func Test_Proxy_Retry(t *testing.T) {
+	p1, _ := ports.PopRand()
+	s1 := g.Server(p1)
+	s1.BindHandler("/", func(r *qn_http.Request) {
+		r.Response.Write("ok")
+	})
+	s1.SetPort(p1)
+	s1.SetDumpRouterMap(false)
+	s1.Start()
+	defer s1.Shutdown()
+
+	// The second upstream is not listening.
+	p2, _ := ports.PopRand()
+	proxy, err := qn_http.NewProxy(qn_http.ProxyConfig{
+		Upstreams: []string{
+			fmt.Sprintf("http://127.0.0.1:%d", p2),
+			fmt.Sprintf("http://127.0.0.1:%d", p1),
+		},
+		Retry: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/", proxy.Serve)
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/"), "ok")
+		t.Assert(client.GetContent("/"), "ok")
+
+		// Non-idempotent request is not retried.
+		r, err := client.Post("/")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 502)
+		r.Close()
+	})
+}
+
+func Test_Proxy_Abort(t *testing.T) {
+	up, _ := ports.PopRand()
+	upstream := g.Server(up)
+	upstream.BindHandler("/broken", func(r *qn_http.Request) {
+		// The body is shorter than the Content-Length.
+		conn, buffer, err := r.Response.Writer.Hijack()
+		if err != nil {
+			return
+		}
+		buffer.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\npartial")
+		buffer.Flush()
+		conn.Close()
+	})
+	upstream.SetPort(up)
+	upstream.SetDumpRouterMap(false)
+	upstream.Start()
+	defer upstream.Shutdown()
+
+	proxy, err := qn_http.NewProxy(qn_http.ProxyConfig{
+		Upstreams: []string{fmt.Sprintf("http://127.0.0.1:%d", up)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindHandler("/*any", proxy.Serve)
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		// The client connection is aborted, instead of responding 500.
+		r, err := client.Get("/broken")
+		if err == nil {
+			t.AssertNE(r.StatusCode, 500)
+			_, err = ioutil.ReadAll(r.Body)
+			r.Close()
+		}
+		t.AssertNE(err, nil)
+	})
+}
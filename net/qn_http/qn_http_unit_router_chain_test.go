@@ -0,0 +1,129 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/internal/json"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+const chainPkg = "github.com/qnsoft/common/net/qn_http_test."
+
+func chainGlobalMiddleware(r *qn_http.Request) { r.Middleware.Next() }
+func chainGroupMiddleware1(r *qn_http.Request) { r.Middleware.Next() }
+func chainGroupMiddleware2(r *qn_http.Request) { r.Middleware.Next() }
+func chainBeforeServe(r *qn_http.Request)      {}
+func chainUserHandler(r *qn_http.Request)      { r.Response.Write("user") }
+func chainUserAnyHandler(r *qn_http.Request)   { r.Response.Write("any") }
+func chainGetBeforeServe(r *qn_http.Request)   {}
+func chainOrderHandler(r *qn_http.Request)     { r.Response.Write("order") }
+
+func Test_Router_Chain(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.BindMiddlewareDefault(chainGlobalMiddleware)
+	s.Group("/api", func(group *qn_http.RouterGroup) {
+		group.Middleware(chainGroupMiddleware1, chainGroupMiddleware2)
+		group.Hook("/*", qn_http.HOOK_BEFORE_SERVE, chainBeforeServe)
+		group.GET("/user/:id", chainUserHandler)
+		group.PUT("/user/*any", chainUserAnyHandler)
+		group.Hook("GET:/order", qn_http.HOOK_BEFORE_SERVE, chainGetBeforeServe)
+		group.ALL("/order", chainOrderHandler)
+	})
+	s.EnableRouterInspect()
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		chain := s.GetRouteChain("GET", "/api/user/1")
+		t.Assert(chain.Names(), g.Slice{
+			chainPkg + "chainBeforeServe",
+			chainPkg + "chainGlobalMiddleware",
+			chainPkg + "chainGroupMiddleware1",
+			chainPkg + "chainGroupMiddleware2",
+			chainPkg + "chainUserHandler",
+		})
+		t.Assert(chain[0].Type, qn_http.HOOK_BEFORE_SERVE)
+		t.Assert(chain[1].Type, qn_http.ROUTE_CHAIN_GLOBAL_MIDDLEWARE)
+		t.Assert(chain[2].Type, qn_http.ROUTE_CHAIN_MIDDLEWARE)
+		t.Assert(chain[4].Type, qn_http.ROUTE_CHAIN_HANDLER)
+		t.Assert(chain[4].Route, "/api/user/:id")
+
+		// Method not matched.
+		t.Assert(s.GetRouteChain("POST", "/api/user/1").Middleware(), g.Slice{
+			chainPkg + "chainGlobalMiddleware",
+		})
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		// The serving handler of the chain is always the registered one of the route.
+		handlers := map[string]string{
+			"/api/user/:id":  chainPkg + "chainUserHandler",
+			"/api/user/*any": chainPkg + "chainUserAnyHandler",
+		}
+		count := 0
+		for _, item := range s.GetRouterArray() {
+			if name, ok := handlers[item.Route]; ok {
+				count++
+				t.Assert(len(item.Chains), 1)
+				chain := item.Chains[item.Method]
+				t.Assert(chain.Middleware(), g.Slice{
+					chainPkg + "chainGlobalMiddleware",
+					chainPkg + "chainGroupMiddleware1",
+					chainPkg + "chainGroupMiddleware2",
+				})
+				t.Assert(chain[0].Name, chainPkg+"chainBeforeServe")
+				t.Assert(chain[len(chain)-1].Name, name)
+				t.Assert(chain[len(chain)-1].Route, item.Route)
+			}
+		}
+		t.Assert(count, 2)
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		// The handler bound to all methods has the chains of each method.
+		count := 0
+		for _, item := range s.GetRouterArray() {
+			if item.Route == "/api/order" && item.IsServiceHandler {
+				count++
+				t.Assert(item.Method, "ALL")
+				// The hook bound to GET method is only in the chain of GET.
+				getChain, postChain := item.Chains["GET"], item.Chains["POST"]
+				t.Assert(len(getChain), 6)
+				t.Assert(len(postChain), 5)
+				t.AssertIN(chainPkg+"chainGetBeforeServe", getChain.Names())
+				t.AssertNI(chainPkg+"chainGetBeforeServe", postChain.Names())
+				for _, chainItem := range getChain {
+					if chainItem.Name == chainPkg+"chainGetBeforeServe" {
+						t.Assert(chainItem.Method, "GET")
+					}
+				}
+				t.Assert(getChain[len(getChain)-1].Name, chainPkg+"chainOrderHandler")
+				t.Assert(postChain.Middleware(), getChain.Middleware())
+			}
+		}
+		t.Assert(count, 1)
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/api/user/1"), "user")
+
+		var chain qn_http.RouterChain
+		content := client.GetBytes("/debug/routes?path=/api/user/1")
+		t.Assert(json.Unmarshal(content, &chain), nil)
+		t.Assert(len(chain), 5)
+		t.Assert(chain[4].Name, chainPkg+"chainUserHandler")
+	})
+}
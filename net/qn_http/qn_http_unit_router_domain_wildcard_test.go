@@ -0,0 +1,97 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Router_DomainWildcard(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	s.Domain("*.example.com").BindHandler("/:name", func(r *qn_http.Request) {
+		r.Response.Write(r.GetRouterString("subdomain"), ":", r.Get("name"))
+	})
+	s.Domain("*.api.example.com").BindHandler("/:name", func(r *qn_http.Request) {
+		r.Response.Write("api-", r.GetRouterString("subdomain"), ":", r.Get("name"))
+	})
+	s.Domain("www.example.com").BindHandler("/:name", func(r *qn_http.Request) {
+		r.Response.Write("www:", r.Get("name"))
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/john"), "Not Found")
+		t.Assert(client.Header(g.MapStrStr{"Host": "example.com"}).GetContent("/john"), "Not Found")
+		t.Assert(client.Header(g.MapStrStr{"Host": "tenant1.example.com"}).GetContent("/john"), "tenant1:john")
+		t.Assert(client.Header(g.MapStrStr{"Host": "a.b.example.com"}).GetContent("/john"), "a.b:john")
+		t.Assert(client.Header(g.MapStrStr{"Host": "v1.api.example.com"}).GetContent("/john"), "api-v1:john")
+		t.Assert(client.Header(g.MapStrStr{"Host": "www.example.com"}).GetContent("/john"), "www:john")
+	})
+}
+
+func Test_Router_DomainConfig(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := g.Server(p)
+	path := fmt.Sprintf(`%s/qn_http/domain/%d`, qn_file.TempDir(), p)
+	defer qn_file.Remove(path)
+	qn_file.PutContents(path+"/index.htm", "tenant index")
+
+	d := s.Domain("*.example.com")
+	err := d.SetConfig(qn_http.DomainConfig{
+		ServerRoot:    path,
+		SessionIdName: "tenantsessionid",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.BindHandler("/session", func(r *qn_http.Request) {
+		r.Session.Set("id", 1)
+		r.Response.Write(r.Session.Id())
+	})
+	s.BindHandler("/session", func(r *qn_http.Request) {
+		r.Session.Set("id", 1)
+		r.Response.Write(r.Session.Id())
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		t.Assert(client.GetContent("/"), "Not Found")
+		t.Assert(client.Header(g.MapStrStr{"Host": "tenant1.example.com"}).GetContent("/"), "tenant index")
+
+		r, err := client.Get("/session")
+		t.Assert(err, nil)
+		t.AssertNE(r.GetCookie("gfsessionid"), "")
+		t.Assert(r.GetCookie("tenantsessionid"), "")
+		r.Close()
+
+		r, err = client.Header(g.MapStrStr{"Host": "tenant1.example.com"}).Get("/session")
+		t.Assert(err, nil)
+		t.Assert(r.GetCookie("gfsessionid"), "")
+		t.AssertNE(r.GetCookie("tenantsessionid"), "")
+		r.Close()
+	})
+}
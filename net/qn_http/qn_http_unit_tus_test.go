@@ -0,0 +1,184 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_http_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/frame/g"
+	"github.com/qnsoft/common/net/qn_http"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Tus_Basic(t *testing.T) {
+	p, _ := ports.PopRand()
+	path := qn_file.TempDir(qn_time.TimestampNanoStr())
+	if err := qn_file.Mkdir(path); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		done     = make(chan *qn_http.TusUpload, 1)
+		storage  = qn_http.NewTusStorageFile(path)
+		uploader = qn_http.NewTusHandler(qn_http.TusConfig{
+			Storage: storage,
+			MaxSize: 1024,
+			OnComplete: func(r *qn_http.Request, upload *qn_http.TusUpload) {
+				done <- upload
+			},
+		})
+	)
+	defer qn_file.Remove(path)
+	defer uploader.Close()
+
+	s := g.Server(p)
+	s.Group("/files", func(group *qn_http.RouterGroup) {
+		uploader.Bind(group)
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.SetHeader("Tus-Resumable", qn_http.TUS_VERSION)
+
+		// Creation.
+		r, err := client.Header(g.MapStrStr{
+			"Upload-Length":   "10",
+			"Upload-Metadata": "filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==",
+		}).Post("/files")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 201)
+		location := r.Header.Get("Location")
+		t.AssertNE(r.Header.Get("Upload-Expires"), "")
+		r.Close()
+		t.AssertNE(location, "")
+
+		// Too large.
+		r, err = client.Header(g.MapStrStr{"Upload-Length": "2048"}).Post("/files")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 413)
+		r.Close()
+
+		// Chunks.
+		patch := client.ContentType("application/offset+octet-stream")
+		r, err = patch.Header(g.MapStrStr{"Upload-Offset": "0"}).Patch(location, "01234")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 204)
+		t.Assert(r.Header.Get("Upload-Offset"), "5")
+		r.Close()
+
+		r, err = patch.Header(g.MapStrStr{"Upload-Offset": "0"}).Patch(location, "01234")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 409)
+		r.Close()
+
+		r, err = client.Head(location)
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 200)
+		t.Assert(r.Header.Get("Upload-Offset"), "5")
+		t.Assert(r.Header.Get("Upload-Length"), "10")
+		t.AssertNE(r.Header.Get("Upload-Expires"), "")
+		r.Close()
+
+		r, err = patch.Header(g.MapStrStr{"Upload-Offset": "5"}).Patch(location, "56789")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 204)
+		t.Assert(r.Header.Get("Upload-Offset"), "10")
+		r.Close()
+
+		select {
+		case upload := <-done:
+			t.Assert(upload.Metadata["filename"], "world_domination_plan.pdf")
+			t.Assert(qn_file.GetContents(storage.DataFilePath(upload.Id)), "0123456789")
+		case <-time.After(time.Second):
+			t.Error("upload not completed")
+		}
+
+		// Completed upload accepts no more content.
+		r, err = patch.Header(g.MapStrStr{"Upload-Offset": "10"}).Patch(location, "abc")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 403)
+		r.Close()
+
+		// Termination.
+		r, err = client.Delete(location)
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 204)
+		r.Close()
+
+		r, err = client.Head(location)
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 404)
+		r.Close()
+	})
+}
+
+func Test_Tus_Expire(t *testing.T) {
+	p, _ := ports.PopRand()
+	path := qn_file.TempDir(qn_time.TimestampNanoStr())
+	if err := qn_file.Mkdir(path); err != nil {
+		t.Fatal(err)
+	}
+	uploader := qn_http.NewTusHandler(qn_http.TusConfig{
+		Storage:       qn_http.NewTusStorageFile(path),
+		Expire:        500 * time.Millisecond,
+		CheckInterval: 100 * time.Millisecond,
+	})
+	defer qn_file.Remove(path)
+	defer uploader.Close()
+
+	s := g.Server(p)
+	s.Group("/files", func(group *qn_http.RouterGroup) {
+		uploader.Bind(group)
+	})
+	s.SetPort(p)
+	s.SetDumpRouterMap(false)
+	s.Start()
+	defer s.Shutdown()
+
+	time.Sleep(100 * time.Millisecond)
+	qn_test.C(t, func(t *qn_test.T) {
+		client := qn_http.NewClient()
+		client.SetPrefix(fmt.Sprintf("http://127.0.0.1:%d", p))
+		client.SetHeader("Tus-Resumable", qn_http.TUS_VERSION)
+
+		r, err := client.Header(g.MapStrStr{"Upload-Length": "10"}).Post("/files")
+		t.Assert(err, nil)
+		incomplete := r.Header.Get("Location")
+		r.Close()
+
+		r, err = client.Header(g.MapStrStr{"Upload-Length": "3"}).Post("/files")
+		t.Assert(err, nil)
+		completed := r.Header.Get("Location")
+		r.Close()
+		r, err = client.ContentType("application/offset+octet-stream").
+			Header(g.MapStrStr{"Upload-Offset": "0"}).Patch(completed, "abc")
+		t.Assert(err, nil)
+		t.Assert(r.StatusCode, 204)
+		_, err = http.ParseTime(r.Header.Get("Upload-Expires"))
+		t.Assert(err, nil)
+		r.Close()
+
+		// Both the incomplete and completed uploads are removed after expired.
+		time.Sleep(time.Second)
+		for _, location := range []string{incomplete, completed} {
+			r, err = client.Head(location)
+			t.Assert(err, nil)
+			t.Assert(r.StatusCode, 404)
+			r.Close()
+		}
+	})
+}
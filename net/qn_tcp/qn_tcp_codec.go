@@ -0,0 +1,305 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Codec is the interface for framing the data stream of connection,
+// which is used by Conn.SendFrame and Conn.RecvFrame.
+type Codec interface {
+	// Encode encodes <data> to a frame for sending.
+	Encode(data []byte) ([]byte, error)
+
+	// Decode reads a whole frame from <reader> and returns its data.
+	Decode(reader *bufio.Reader) ([]byte, error)
+}
+
+// LengthFieldCodec is the codec for frames having a length field, like:
+// Header(LengthOffset)|Length(LengthSize)|Body(Length+LengthAdjustment).
+//
+// The data of the codec is Header|Body, that the length field is inserted after the header
+// when encoding, and removed when decoding, so that decoding the encoded data returns it as is.
+type LengthFieldCodec struct {
+	// LengthOffset is the offset of the length field in the frame,
+	// which is the size of the header at the head of data.
+	LengthOffset int
+
+	// LengthSize is the size of the length field in bytes, which is 1, 2, 3, 4 or 8.
+	// It's 2 in default, which is the same as the simple package protocol.
+	LengthSize int
+
+	// LittleEndian specifies the length field is encoded in little endian order.
+	// It's big endian in default.
+	LittleEndian bool
+
+	// LengthAdjustment is added to the length field value to get the size of body,
+	// which is usually used if the length field value contains the header size.
+	LengthAdjustment int
+
+	// KeepLengthField specifies keeping the length field in the decoded data,
+	// which makes the decoded data the whole frame.
+	KeepLengthField bool
+
+	// MaxFrameSize is the max size of body, which is checked after applying LengthAdjustment.
+	// It's DefaultMaxFrameSize in default.
+	MaxFrameSize int
+}
+
+// DelimiterCodec is the codec for frames ending with a delimiter, like: Data|Delimiter.
+type DelimiterCodec struct {
+	// Delimiter is the delimiter of frames, which is "\n" in default.
+	Delimiter []byte
+
+	// KeepDelimiter specifies keeping the delimiter in the decoded data.
+	KeepDelimiter bool
+
+	// MaxFrameSize is the max size of frame including the delimiter.
+	// It's DefaultMaxFrameSize in default.
+	MaxFrameSize int
+}
+
+// FixedLengthCodec is the codec for frames having fixed length.
+type FixedLengthCodec struct {
+	// Length is the length of each frame.
+	Length int
+}
+
+// VarintCodec is the codec for frames prefixed with the data length encoded in unsigned varint,
+// like protobuf delimited messages: Length(varint)|Data(Length).
+type VarintCodec struct {
+	// MaxFrameSize is the max size of data.
+	// It's DefaultMaxFrameSize in default.
+	MaxFrameSize int
+}
+
+var (
+	// DefaultMaxFrameSize is the default max frame size for codecs.
+	DefaultMaxFrameSize = 1024 * 1024
+
+	// defaultCodec is the codec used if the connection has no codec,
+	// which is compatible with the simple package protocol.
+	defaultCodec Codec = &LengthFieldCodec{}
+)
+
+// Encode encodes <data> to a frame for sending.
+func (c *LengthFieldCodec) Encode(data []byte) ([]byte, error) {
+	lengthSize, err := c.getLengthSize()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.LengthOffset {
+		return nil, fmt.Errorf(`data size %d is lesser than length offset %d`, len(data), c.LengthOffset)
+	}
+	size := len(data) - c.LengthOffset
+	if size > getMaxFrameSize(c.MaxFrameSize) {
+		return nil, fmt.Errorf(
+			`data too long, data size %d exceeds allowed max frame size %d`,
+			size, getMaxFrameSize(c.MaxFrameSize),
+		)
+	}
+	length := uint64(size - c.LengthAdjustment)
+	if size < c.LengthAdjustment || (lengthSize < 8 && length >= 1<<(uint(lengthSize)*8)) {
+		return nil, fmt.Errorf(`length %d cannot be encoded in %d bytes`, size-c.LengthAdjustment, lengthSize)
+	}
+	var (
+		frame  = make([]byte, len(data)+lengthSize)
+		field  = make([]byte, 8)
+		header = frame[c.LengthOffset : c.LengthOffset+lengthSize]
+	)
+	copy(frame, data[:c.LengthOffset])
+	if c.LittleEndian {
+		binary.LittleEndian.PutUint64(field, length)
+		copy(header, field[:lengthSize])
+	} else {
+		binary.BigEndian.PutUint64(field, length)
+		copy(header, field[8-lengthSize:])
+	}
+	copy(frame[c.LengthOffset+lengthSize:], data[c.LengthOffset:])
+	return frame, nil
+}
+
+// Decode reads a whole frame from <reader> and returns its data.
+func (c *LengthFieldCodec) Decode(reader *bufio.Reader) ([]byte, error) {
+	lengthSize, err := c.getLengthSize()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, c.LengthOffset+lengthSize)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	var (
+		field  = make([]byte, 8)
+		length uint64
+	)
+	if c.LittleEndian {
+		copy(field, header[c.LengthOffset:])
+		length = binary.LittleEndian.Uint64(field)
+	} else {
+		copy(field[8-lengthSize:], header[c.LengthOffset:])
+		length = binary.BigEndian.Uint64(field)
+	}
+	// The huge length is rejected before adjusting for avoiding overflow.
+	if length > math.MaxInt64/2 {
+		return nil, fmt.Errorf(`invalid frame length %d`, length)
+	}
+	var (
+		size         = int64(length) + int64(c.LengthAdjustment)
+		maxFrameSize = getMaxFrameSize(c.MaxFrameSize)
+	)
+	if size < 0 {
+		return nil, fmt.Errorf(`invalid frame size %d`, size)
+	}
+	if size > int64(maxFrameSize) {
+		return nil, fmt.Errorf(`invalid frame size %d, which exceeds max frame size %d`, size, maxFrameSize)
+	}
+	frame := make([]byte, len(header)+int(size))
+	copy(frame, header)
+	if _, err = io.ReadFull(reader, frame[len(header):]); err != nil {
+		return nil, err
+	}
+	if c.KeepLengthField {
+		return frame, nil
+	}
+	// Removing the length field, the header is kept.
+	copy(frame[lengthSize:], frame[:c.LengthOffset])
+	return frame[lengthSize:], nil
+}
+
+// getLengthSize checks and returns the size of length field.
+func (c *LengthFieldCodec) getLengthSize() (int, error) {
+	switch c.LengthSize {
+	case 0:
+		return gPKG_HEADER_SIZE_DEFAULT, nil
+	case 1, 2, 3, 4, 8:
+		return c.LengthSize, nil
+	}
+	return 0, fmt.Errorf(`invalid length field size %d`, c.LengthSize)
+}
+
+// Encode encodes <data> to a frame for sending.
+// Note that <data> should not contain the delimiter.
+func (c *DelimiterCodec) Encode(data []byte) ([]byte, error) {
+	delimiter := c.getDelimiter()
+	if bytes.Contains(data, delimiter) {
+		return nil, fmt.Errorf(`data cannot contain the delimiter %q`, delimiter)
+	}
+	if len(data)+len(delimiter) > getMaxFrameSize(c.MaxFrameSize) {
+		return nil, fmt.Errorf(
+			`data too long, frame size %d exceeds allowed max frame size %d`,
+			len(data)+len(delimiter), getMaxFrameSize(c.MaxFrameSize),
+		)
+	}
+	frame := make([]byte, 0, len(data)+len(delimiter))
+	frame = append(frame, data...)
+	return append(frame, delimiter...), nil
+}
+
+// Decode reads a whole frame from <reader> and returns its data.
+func (c *DelimiterCodec) Decode(reader *bufio.Reader) ([]byte, error) {
+	var (
+		delimiter    = c.getDelimiter()
+		last         = delimiter[len(delimiter)-1]
+		maxFrameSize = getMaxFrameSize(c.MaxFrameSize)
+		frame        = make([]byte, 0)
+	)
+	for {
+		slice, err := reader.ReadSlice(last)
+		frame = append(frame, slice...)
+		if len(frame) > maxFrameSize {
+			return nil, fmt.Errorf(`frame too long, which exceeds max frame size %d`, maxFrameSize)
+		}
+		if err == nil && bytes.HasSuffix(frame, delimiter) {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+	if c.KeepDelimiter {
+		return frame, nil
+	}
+	return frame[:len(frame)-len(delimiter)], nil
+}
+
+// getDelimiter returns the delimiter of the codec.
+func (c *DelimiterCodec) getDelimiter() []byte {
+	if len(c.Delimiter) == 0 {
+		return []byte{'\n'}
+	}
+	return c.Delimiter
+}
+
+// Encode encodes <data> to a frame for sending.
+// The size of <data> should be the same as the frame length.
+func (c *FixedLengthCodec) Encode(data []byte) ([]byte, error) {
+	if c.Length <= 0 {
+		return nil, fmt.Errorf(`invalid frame length %d`, c.Length)
+	}
+	if len(data) != c.Length {
+		return nil, fmt.Errorf(`data size %d does not match frame length %d`, len(data), c.Length)
+	}
+	return data, nil
+}
+
+// Decode reads a whole frame from <reader> and returns its data.
+func (c *FixedLengthCodec) Decode(reader *bufio.Reader) ([]byte, error) {
+	if c.Length <= 0 {
+		return nil, fmt.Errorf(`invalid frame length %d`, c.Length)
+	}
+	frame := make([]byte, c.Length)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// Encode encodes <data> to a frame for sending.
+func (c *VarintCodec) Encode(data []byte) ([]byte, error) {
+	if len(data) > getMaxFrameSize(c.MaxFrameSize) {
+		return nil, fmt.Errorf(
+			`data too long, data size %d exceeds allowed max frame size %d`,
+			len(data), getMaxFrameSize(c.MaxFrameSize),
+		)
+	}
+	frame := make([]byte, binary.MaxVarintLen64+len(data))
+	n := binary.PutUvarint(frame, uint64(len(data)))
+	copy(frame[n:], data)
+	return frame[:n+len(data)], nil
+}
+
+// Decode reads a whole frame from <reader> and returns its data.
+func (c *VarintCodec) Decode(reader *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	maxFrameSize := getMaxFrameSize(c.MaxFrameSize)
+	if length > uint64(maxFrameSize) {
+		return nil, fmt.Errorf(`invalid frame size %d, which exceeds max frame size %d`, length, maxFrameSize)
+	}
+	frame := make([]byte, length)
+	if _, err = io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// getMaxFrameSize returns <size> if it's set, or else DefaultMaxFrameSize.
+func getMaxFrameSize(size int) int {
+	if size > 0 {
+		return size
+	}
+	return DefaultMaxFrameSize
+}
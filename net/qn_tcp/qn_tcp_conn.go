@@ -22,6 +22,7 @@ type Conn struct {
 	recvDeadline   time.Time     // Timeout point for reading.
 	sendDeadline   time.Time     // Timeout point for writing.
 	recvBufferWait time.Duration // Interval duration for reading buffer.
+	codec          Codec         // Codec for framing data, see SendFrame/RecvFrame.
 }
 
 const (
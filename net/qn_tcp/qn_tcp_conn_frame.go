@@ -0,0 +1,71 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp
+
+import (
+	"time"
+)
+
+// SetCodec sets the codec for framing data of the connection,
+// which is used by SendFrame and RecvFrame.
+func (c *Conn) SetCodec(codec Codec) {
+	c.codec = codec
+}
+
+// getCodec returns the codec of the connection,
+// or the default codec compatible with simple package protocol if it's not set.
+func (c *Conn) getCodec() Codec {
+	if c.codec != nil {
+		return c.codec
+	}
+	return defaultCodec
+}
+
+// SendFrame encodes <data> to a frame using the codec of the connection and sends it.
+func (c *Conn) SendFrame(data []byte, retry ...Retry) error {
+	frame, err := c.getCodec().Encode(data)
+	if err != nil {
+		return err
+	}
+	return c.Send(frame, retry...)
+}
+
+// SendFrameWithTimeout writes data to connection with timeout using the codec of the connection.
+func (c *Conn) SendFrameWithTimeout(data []byte, timeout time.Duration, retry ...Retry) (err error) {
+	if err := c.SetSendDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer c.SetSendDeadline(time.Time{})
+	err = c.SendFrame(data, retry...)
+	return
+}
+
+// RecvFrame blocks reading a whole frame using the codec of the connection and returns its data.
+func (c *Conn) RecvFrame() ([]byte, error) {
+	return c.getCodec().Decode(c.reader)
+}
+
+// RecvFrameWithTimeout reads a whole frame from connection with timeout
+// using the codec of the connection.
+func (c *Conn) RecvFrameWithTimeout(timeout time.Duration) (data []byte, err error) {
+	if err := c.SetRecvDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	defer c.SetRecvDeadline(time.Time{})
+	data, err = c.RecvFrame()
+	return
+}
+
+// SendRecvFrame writes data to connection and blocks reading response frame
+// using the codec of the connection.
+func (c *Conn) SendRecvFrame(data []byte, retry ...Retry) ([]byte, error) {
+	if err := c.SendFrame(data, retry...); err == nil {
+		return c.RecvFrame()
+	} else {
+		return nil, err
+	}
+}
@@ -11,15 +11,20 @@ import (
 
 	"github.com/qnsoft/common/container/gpool"
 	"github.com/qnsoft/common/container/qn_map"
+	"github.com/qnsoft/common/net/qn_breaker"
 )
 
 // PoolConn is a connection with pool feature for TCP.
 // Note that it is NOT a pool or connection manager,
 // it is just a TCP connection object.
 type PoolConn struct {
-	*Conn              // Underlying connection object.
-	pool   *gpool.Pool // Connection pool, which is not a really connection pool, but a connection reusable pool.
-	status int         // Status of current connection, which is used to mark this connection usable or not.
+	*Conn                        // Underlying connection object.
+	pool     *gpool.Pool         // Connection pool, which is not a really connection pool, but a connection reusable pool.
+	owner    *Pool               // Connection pool created by NewPool, which is nil if not used.
+	status   int                 // Status of current connection, which is used to mark this connection usable or not.
+	breaker  *qn_breaker.Breaker // Circuit breaker of the address, which is nil if not used.
+	failed   bool                // Whether any operation fails since borrowed, which is reported to the breaker.
+	returned bool                // Whether it's returned to the owner pool, which is protected by the mutex of owner.
 }
 
 const (
@@ -40,7 +45,7 @@ func NewPoolConn(addr string, timeout ...time.Duration) (*PoolConn, error) {
 		var pool *gpool.Pool
 		pool = gpool.New(gDEFAULT_POOL_EXPIRE, func() (interface{}, error) {
 			if conn, err := NewConn(addr, timeout...); err == nil {
-				return &PoolConn{conn, pool, gCONN_STATUS_ACTIVE}, nil
+				return &PoolConn{Conn: conn, pool: pool, status: gCONN_STATUS_ACTIVE}, nil
 			} else {
 				return nil, err
 			}
@@ -54,12 +59,38 @@ func NewPoolConn(addr string, timeout ...time.Duration) (*PoolConn, error) {
 	}
 }
 
+// NewPoolConnWithBreaker creates and returns a connection with pool feature, which is protected
+// by the circuit breaker of <addr> in <group>. It returns qn_breaker.ErrOpen if the breaker is open.
+//
+// The failure of connecting is reported to the breaker immediately, and the result of the
+// borrowed connection is reported once when it's closed: failure if any sending or receiving
+// fails, or else success.
+func NewPoolConnWithBreaker(addr string, group *qn_breaker.Group, timeout ...time.Duration) (*PoolConn, error) {
+	breaker := group.Get(addr)
+	if err := breaker.Allow(); err != nil {
+		return nil, err
+	}
+	conn, err := NewPoolConn(addr, timeout...)
+	if err != nil {
+		breaker.Report(false)
+		return nil, err
+	}
+	conn.breaker = breaker
+	return conn, nil
+}
+
 // Close puts back the connection to the pool if it's active,
 // or closes the connection if it's not active.
+// For the connection of Pool, it's put back unless it fails operation.
 //
 // Note that, if <c> calls Close function closing itself, <c> can not
-// be used again.
+// be used again. Closing the connection of Pool more than once does nothing
+// but returns ErrorPoolConnReturned.
 func (c *PoolConn) Close() error {
+	c.reportBreaker()
+	if c.owner != nil {
+		return c.owner.put(c)
+	}
 	if c.pool != nil && c.status == gCONN_STATUS_ACTIVE {
 		c.status = gCONN_STATUS_UNKNOWN
 		c.pool.Put(c)
@@ -73,7 +104,16 @@ func (c *PoolConn) Close() error {
 // writing data.
 func (c *PoolConn) Send(data []byte, retry ...Retry) error {
 	err := c.Conn.Send(data, retry...)
-	if err != nil && c.status == gCONN_STATUS_UNKNOWN {
+	if err != nil && c.status == gCONN_STATUS_UNKNOWN && c.owner != nil {
+		// The reused connection might be closed by the remote, it redials in the same slot.
+		c.Conn.Close()
+		if conn, e := c.owner.dial(); e == nil {
+			c.Conn = conn
+			err = c.Conn.Send(data, retry...)
+		} else {
+			err = e
+		}
+	} else if err != nil && c.status == gCONN_STATUS_UNKNOWN {
 		if v, e := c.pool.Get(); e == nil {
 			c.Conn = v.(*PoolConn).Conn
 			err = c.Send(data, retry...)
@@ -81,22 +121,14 @@ func (c *PoolConn) Send(data []byte, retry ...Retry) error {
 			err = e
 		}
 	}
-	if err != nil {
-		c.status = gCONN_STATUS_ERROR
-	} else {
-		c.status = gCONN_STATUS_ACTIVE
-	}
+	c.setStatusByError(err)
 	return err
 }
 
 // Recv receives data from the connection.
 func (c *PoolConn) Recv(length int, retry ...Retry) ([]byte, error) {
 	data, err := c.Conn.Recv(length, retry...)
-	if err != nil {
-		c.status = gCONN_STATUS_ERROR
-	} else {
-		c.status = gCONN_STATUS_ACTIVE
-	}
+	c.setStatusByError(err)
 	return data, err
 }
 
@@ -104,11 +136,7 @@ func (c *PoolConn) Recv(length int, retry ...Retry) ([]byte, error) {
 // Note that the returned result does not contain the last char '\n'.
 func (c *PoolConn) RecvLine(retry ...Retry) ([]byte, error) {
 	data, err := c.Conn.RecvLine(retry...)
-	if err != nil {
-		c.status = gCONN_STATUS_ERROR
-	} else {
-		c.status = gCONN_STATUS_ACTIVE
-	}
+	c.setStatusByError(err)
 	return data, err
 }
 
@@ -116,11 +144,7 @@ func (c *PoolConn) RecvLine(retry ...Retry) ([]byte, error) {
 // Note that the returned result contains the last bytes <til>.
 func (c *PoolConn) RecvTil(til []byte, retry ...Retry) ([]byte, error) {
 	data, err := c.Conn.RecvTil(til, retry...)
-	if err != nil {
-		c.status = gCONN_STATUS_ERROR
-	} else {
-		c.status = gCONN_STATUS_ACTIVE
-	}
+	c.setStatusByError(err)
 	return data, err
 }
 
@@ -161,3 +185,23 @@ func (c *PoolConn) SendRecvWithTimeout(data []byte, receive int, timeout time.Du
 		return nil, err
 	}
 }
+
+// setStatusByError marks the status of the connection by the result <err> of operation.
+func (c *PoolConn) setStatusByError(err error) {
+	if err != nil {
+		c.status = gCONN_STATUS_ERROR
+		c.failed = true
+	} else {
+		c.status = gCONN_STATUS_ACTIVE
+	}
+}
+
+// reportBreaker reports the result of the borrowed connection to the circuit breaker once,
+// which is failure if any operation fails.
+func (c *PoolConn) reportBreaker() {
+	if c.breaker != nil {
+		c.breaker.Report(!c.failed)
+		c.breaker = nil
+	}
+	c.failed = false
+}
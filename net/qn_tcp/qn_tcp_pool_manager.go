@@ -0,0 +1,302 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/qnsoft/common/os/qn_timer"
+)
+
+// Pool is a connection pool for a single address, which limits the idle and open connections,
+// and checks the health of idle connections before they are borrowed.
+//
+// Unlike NewPoolConn, which shares an unlimited pool for each address in package level,
+// the Pool can be configured for each upstream and closed explicitly.
+type Pool struct {
+	address string          // Remote address.
+	config  PoolConfig      // Pool configuration.
+	mu      sync.Mutex      // Mutex for idle connections, counters and status.
+	idle    []*poolIdleConn // Idle connections, the latest returned one is at the end.
+	sem     chan struct{}   // Semaphore limiting the open connections, which is nil if unlimited.
+	done    chan struct{}   // Closed when the pool is closed, which wakes up the waiters.
+	closed  bool            // Whether the pool is closed.
+	numOpen int             // Number of open connections, including idle and in use ones.
+	stats   PoolStats       // Accumulated statistics.
+	timer   *qn_timer.Entry // Timer entry for closing timeout idle connections.
+}
+
+// PoolConfig is the configuration for Pool.
+type PoolConfig struct {
+	MaxIdle     int                    // Max idle connections, which is DefaultPoolMaxIdle if 0, and no idle connection is kept if < 0.
+	MaxOpen     int                    // Max open connections including the ones in use, which is unlimited if <= 0.
+	IdleTimeout time.Duration          // Idle connections are closed after this duration, which is never if <= 0.
+	WaitTimeout time.Duration          // Max duration waiting for a connection if MaxOpen reached, which returns ErrorPoolExhausted immediately if <= 0.
+	DialTimeout time.Duration          // Timeout for dialing new connection.
+	Dial        func() (*Conn, error)  // Custom function creating new connection, which uses NewConn if nil.
+	Ping        func(conn *Conn) error // Health checking function for idle connection before it's borrowed, which is optional.
+	PingIdle    time.Duration          // Only the connections idle longer than this duration are checked by Ping, which is always if <= 0.
+}
+
+// PoolStats is the statistics of Pool.
+type PoolStats struct {
+	OpenConns    int           // Number of open connections, including idle and in use ones.
+	IdleConns    int           // Number of idle connections.
+	InUse        int           // Number of connections in use.
+	Hits         int64         // Number of idle connections reused.
+	Misses       int64         // Number of new connections dialed.
+	WaitCount    int64         // Number of times waiting for a connection.
+	WaitDuration time.Duration // Total duration waiting for connections.
+	Timeouts     int64         // Number of times waiting timeout.
+	PingFailures int64         // Number of idle connections failed health checking.
+	IdleClosed   int64         // Number of idle connections closed for MaxIdle or IdleTimeout.
+}
+
+// poolIdleConn is an idle connection in the pool.
+type poolIdleConn struct {
+	conn     *PoolConn // Pooled connection.
+	returnAt time.Time // Time when it is returned to the pool.
+}
+
+var (
+	// DefaultPoolMaxIdle is the default max idle connections of Pool.
+	DefaultPoolMaxIdle = 10
+
+	// ErrorPoolClosed is returned when getting connection from a closed pool.
+	ErrorPoolClosed = errors.New("pool is closed")
+
+	// ErrorPoolExhausted is returned when MaxOpen is reached and no connection is returned in WaitTimeout.
+	ErrorPoolExhausted = errors.New("pool exhausted")
+
+	// ErrorPoolConnReturned is returned when closing a connection which is already returned to the pool.
+	ErrorPoolConnReturned = errors.New("connection is already returned to pool")
+)
+
+// NewPool creates and returns a connection pool for <address>.
+// The optional parameter <config> specifies the configuration for the pool.
+func NewPool(address string, config ...PoolConfig) *Pool {
+	p := &Pool{
+		address: address,
+		done:    make(chan struct{}),
+	}
+	if len(config) > 0 {
+		p.config = config[0]
+	}
+	if p.config.MaxIdle == 0 {
+		p.config.MaxIdle = DefaultPoolMaxIdle
+	}
+	if p.config.MaxOpen > 0 {
+		if p.config.MaxIdle > p.config.MaxOpen {
+			p.config.MaxIdle = p.config.MaxOpen
+		}
+		p.sem = make(chan struct{}, p.config.MaxOpen)
+	}
+	if p.config.IdleTimeout > 0 {
+		interval := p.config.IdleTimeout / 2
+		if interval < time.Second {
+			interval = time.Second
+		}
+		p.timer = qn_timer.AddSingleton(interval, p.checkIdleConns)
+	}
+	return p
+}
+
+// Get borrows a connection from the pool, which should be returned to the pool by its Close.
+//
+// It reuses the latest returned idle connection if it's healthy, or else dials a new one.
+// If MaxOpen is reached, it waits at most WaitTimeout for a returned connection.
+func (p *Pool) Get() (*PoolConn, error) {
+	if err := p.acquire(); err != nil {
+		return nil, err
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.release()
+			return nil, ErrorPoolClosed
+		}
+		if len(p.idle) == 0 {
+			p.numOpen++
+			p.stats.Misses++
+			p.mu.Unlock()
+			break
+		}
+		item := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		item.conn.returned = false
+		p.mu.Unlock()
+
+		if p.config.Ping != nil && time.Since(item.returnAt) >= p.config.PingIdle {
+			if err := p.config.Ping(item.conn.Conn); err != nil {
+				item.conn.Conn.Close()
+				p.mu.Lock()
+				p.numOpen--
+				p.stats.PingFailures++
+				p.mu.Unlock()
+				continue
+			}
+		}
+		p.mu.Lock()
+		p.stats.Hits++
+		p.mu.Unlock()
+		item.conn.status = gCONN_STATUS_UNKNOWN
+		return item.conn, nil
+	}
+	conn, err := p.dial()
+	if err != nil {
+		p.mu.Lock()
+		p.numOpen--
+		p.mu.Unlock()
+		p.release()
+		return nil, err
+	}
+	return &PoolConn{Conn: conn, owner: p, status: gCONN_STATUS_ACTIVE}, nil
+}
+
+// Stats returns the statistics of the pool.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.OpenConns = p.numOpen
+	stats.IdleConns = len(p.idle)
+	stats.InUse = p.numOpen - len(p.idle)
+	return stats
+}
+
+// Close closes the pool and all its idle connections.
+// The connections in use are closed when they are returned.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.numOpen -= len(idle)
+	close(p.done)
+	p.mu.Unlock()
+	if p.timer != nil {
+		p.timer.Close()
+	}
+	var err error
+	for _, item := range idle {
+		if e := item.conn.Conn.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// put returns <c> to the pool, or closes it if it's broken, the pool is closed or MaxIdle is reached.
+// It returns ErrorPoolConnReturned and does nothing if <c> is already returned.
+func (p *Pool) put(c *PoolConn) error {
+	p.mu.Lock()
+	if c.returned {
+		p.mu.Unlock()
+		return ErrorPoolConnReturned
+	}
+	c.returned = true
+	defer p.release()
+	if c.status != gCONN_STATUS_ERROR && !p.closed && len(p.idle) < p.config.MaxIdle {
+		p.idle = append(p.idle, &poolIdleConn{
+			conn:     c,
+			returnAt: time.Now(),
+		})
+		p.mu.Unlock()
+		return nil
+	}
+	if c.status != gCONN_STATUS_ERROR && !p.closed {
+		p.stats.IdleClosed++
+	}
+	p.numOpen--
+	p.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// dial creates a new connection to the address of the pool.
+func (p *Pool) dial() (*Conn, error) {
+	if p.config.Dial != nil {
+		return p.config.Dial()
+	}
+	if p.config.DialTimeout > 0 {
+		return NewConn(p.address, p.config.DialTimeout)
+	}
+	return NewConn(p.address)
+}
+
+// acquire acquires a slot of open connection, waiting at most WaitTimeout if MaxOpen is reached.
+func (p *Pool) acquire() error {
+	if p.sem == nil {
+		return nil
+	}
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	if p.config.WaitTimeout <= 0 {
+		return ErrorPoolExhausted
+	}
+	var (
+		start = time.Now()
+		timer = time.NewTimer(p.config.WaitTimeout)
+		err   error
+	)
+	defer timer.Stop()
+	select {
+	case p.sem <- struct{}{}:
+	case <-p.done:
+		err = ErrorPoolClosed
+	case <-timer.C:
+		err = ErrorPoolExhausted
+	}
+	p.mu.Lock()
+	p.stats.WaitCount++
+	p.stats.WaitDuration += time.Since(start)
+	if err == ErrorPoolExhausted {
+		p.stats.Timeouts++
+	}
+	p.mu.Unlock()
+	return err
+}
+
+// release releases a slot of open connection.
+func (p *Pool) release() {
+	if p.sem != nil {
+		<-p.sem
+	}
+}
+
+// checkIdleConns closes the connections idle longer than IdleTimeout.
+func (p *Pool) checkIdleConns() {
+	var (
+		expired  []*poolIdleConn
+		deadline = time.Now().Add(-p.config.IdleTimeout)
+	)
+	p.mu.Lock()
+	// The idle connections are ordered by their returned time.
+	i := 0
+	for i < len(p.idle) && p.idle[i].returnAt.Before(deadline) {
+		i++
+	}
+	if i > 0 {
+		expired = append(expired, p.idle[:i]...)
+		p.idle = append(p.idle[:0], p.idle[i:]...)
+		p.numOpen -= i
+		p.stats.IdleClosed += int64(i)
+	}
+	p.mu.Unlock()
+	for _, item := range expired {
+		item.conn.Conn.Close()
+	}
+}
@@ -21,11 +21,7 @@ func (c *PoolConn) SendPkg(data []byte, option ...PkgOption) (err error) {
 			err = e
 		}
 	}
-	if err != nil {
-		c.status = gCONN_STATUS_ERROR
-	} else {
-		c.status = gCONN_STATUS_ACTIVE
-	}
+	c.setStatusByError(err)
 	return err
 }
 
@@ -33,11 +29,7 @@ func (c *PoolConn) SendPkg(data []byte, option ...PkgOption) (err error) {
 // The optional parameter <option> specifies the package options for receiving.
 func (c *PoolConn) RecvPkg(option ...PkgOption) ([]byte, error) {
 	data, err := c.Conn.RecvPkg(option...)
-	if err != nil {
-		c.status = gCONN_STATUS_ERROR
-	} else {
-		c.status = gCONN_STATUS_ACTIVE
-	}
+	c.setStatusByError(err)
 	return data, err
 }
 
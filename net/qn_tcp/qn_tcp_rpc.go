@@ -0,0 +1,171 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/qnsoft/common/encoding/qn_json"
+)
+
+// RpcEncoding is the interface for encoding the request and response payloads of rpc calls.
+// Note that the client and server should use the same encoding.
+type RpcEncoding interface {
+	// Marshal encodes <value> to bytes.
+	Marshal(value interface{}) ([]byte, error)
+
+	// Unmarshal decodes <data> to <pointer>.
+	Unmarshal(data []byte, pointer interface{}) error
+}
+
+// RpcError is the error responded by the rpc server, which is returned by RpcClient.Call.
+type RpcError struct {
+	Method  string // Called method name.
+	Message string // Error message responded by the server.
+}
+
+var (
+	// RpcEncodingJson encodes the payloads with JSON, which is the default encoding.
+	RpcEncodingJson RpcEncoding = rpcEncodingJson{}
+
+	// RpcEncodingBinary passes the payloads as raw bytes, which supports []byte, string and
+	// the types implementing encoding.BinaryMarshaler/BinaryUnmarshaler.
+	RpcEncodingBinary RpcEncoding = rpcEncodingBinary{}
+)
+
+const (
+	gRPC_KIND_REQUEST  = 1 // Message kind of request.
+	gRPC_KIND_RESPONSE = 2 // Message kind of response.
+	gRPC_STATUS_OK     = 0 // Response status for success.
+	gRPC_STATUS_ERROR  = 1 // Response status for error, whose payload is the error message.
+)
+
+// rpcMessage is the rpc request or response message, which is encoded as:
+// Request : Kind(1)|Id(8)|Timeout(8)|MethodLength(2)|Method|Payload.
+// Response: Kind(1)|Id(8)|Status(1)|Payload.
+type rpcMessage struct {
+	kind    byte   // Message kind.
+	id      uint64 // Request id, which is the same for the request and its response.
+	timeout int64  // Remaining timeout of request in milliseconds, which is 0 if no timeout.
+	method  string // Requested method name.
+	status  byte   // Response status.
+	payload []byte // Request or response payload.
+}
+
+// rpcCodec is the codec for framing rpc messages.
+var rpcCodec Codec = &LengthFieldCodec{LengthSize: 4}
+
+// Error implements the error interface.
+func (e *RpcError) Error() string {
+	return fmt.Sprintf(`rpc call "%s" failed: %s`, e.Method, e.Message)
+}
+
+// Marshal encodes <value> to bytes.
+func (rpcEncodingJson) Marshal(value interface{}) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return qn_json.Encode(value)
+}
+
+// Unmarshal decodes <data> to <pointer>.
+func (rpcEncodingJson) Unmarshal(data []byte, pointer interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return qn_json.DecodeTo(data, pointer)
+}
+
+// Marshal encodes <value> to bytes.
+func (rpcEncodingBinary) Marshal(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	}
+	return nil, fmt.Errorf(`unsupported binary payload type %T`, value)
+}
+
+// Unmarshal decodes <data> to <pointer>.
+func (rpcEncodingBinary) Unmarshal(data []byte, pointer interface{}) error {
+	switch v := pointer.(type) {
+	case *[]byte:
+		*v = append([]byte(nil), data...)
+		return nil
+	case *string:
+		*v = string(data)
+		return nil
+	case encoding.BinaryUnmarshaler:
+		return v.UnmarshalBinary(data)
+	}
+	return fmt.Errorf(`unsupported binary payload type %T`, pointer)
+}
+
+// rpcEncodingJson implements RpcEncoding with JSON.
+type rpcEncodingJson struct{}
+
+// rpcEncodingBinary implements RpcEncoding with raw bytes.
+type rpcEncodingBinary struct{}
+
+// encode encodes the message to bytes.
+func (m *rpcMessage) encode() []byte {
+	var buffer []byte
+	if m.kind == gRPC_KIND_REQUEST {
+		buffer = make([]byte, 19+len(m.method)+len(m.payload))
+		binary.BigEndian.PutUint64(buffer[9:], uint64(m.timeout))
+		binary.BigEndian.PutUint16(buffer[17:], uint16(len(m.method)))
+		copy(buffer[19:], m.method)
+		copy(buffer[19+len(m.method):], m.payload)
+	} else {
+		buffer = make([]byte, 10+len(m.payload))
+		buffer[9] = m.status
+		copy(buffer[10:], m.payload)
+	}
+	buffer[0] = m.kind
+	binary.BigEndian.PutUint64(buffer[1:], m.id)
+	return buffer
+}
+
+// decodeRpcMessage decodes <data> to message.
+func decodeRpcMessage(data []byte) (*rpcMessage, error) {
+	if len(data) < 10 {
+		return nil, errors.New("invalid rpc message")
+	}
+	m := &rpcMessage{
+		kind: data[0],
+		id:   binary.BigEndian.Uint64(data[1:]),
+	}
+	switch m.kind {
+	case gRPC_KIND_REQUEST:
+		if len(data) < 19 {
+			return nil, errors.New("invalid rpc request")
+		}
+		m.timeout = int64(binary.BigEndian.Uint64(data[9:]))
+		methodSize := int(binary.BigEndian.Uint16(data[17:]))
+		if len(data) < 19+methodSize {
+			return nil, errors.New("invalid rpc request")
+		}
+		m.method = string(data[19 : 19+methodSize])
+		m.payload = data[19+methodSize:]
+
+	case gRPC_KIND_RESPONSE:
+		m.status = data[9]
+		m.payload = data[10:]
+
+	default:
+		return nil, fmt.Errorf(`invalid rpc message kind %d`, m.kind)
+	}
+	return m, nil
+}
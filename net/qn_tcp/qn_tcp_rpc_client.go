@@ -0,0 +1,171 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+)
+
+// RpcClient is the rpc client multiplexing concurrent calls over a single connection.
+// Each call is identified by a request id, so the responses can arrive in any order.
+type RpcClient struct {
+	conn     *Conn                       // Underlying connection.
+	encoding RpcEncoding                 // Encoding for payloads.
+	writeMu  sync.Mutex                  // Mutex for writing frames.
+	mu       sync.Mutex                  // Mutex for pending calls and error.
+	pending  map[uint64]chan *rpcMessage // Pending calls waiting for responses.
+	nextId   *qn_type.Uint64             // Id for next request.
+	err      error                       // Error of connection, which fails all calls.
+}
+
+// NewRpcClient creates and returns a rpc client connecting to <address>.
+// The optional parameter <timeout> specifies the timeout for dialing connection.
+func NewRpcClient(address string, timeout ...time.Duration) (*RpcClient, error) {
+	conn, err := NewConn(address, timeout...)
+	if err != nil {
+		return nil, err
+	}
+	return NewRpcClientByConn(conn), nil
+}
+
+// NewRpcClientByConn creates and returns a rpc client using connection <conn>.
+// Note that the codec of <conn> is changed for rpc messages.
+func NewRpcClientByConn(conn *Conn) *RpcClient {
+	conn.SetCodec(rpcCodec)
+	c := &RpcClient{
+		conn:     conn,
+		encoding: RpcEncodingJson,
+		pending:  make(map[uint64]chan *rpcMessage),
+		nextId:   qn_type.NewUint64(),
+	}
+	go c.receive()
+	return c
+}
+
+// SetEncoding sets the encoding for payloads, which is RpcEncodingJson in default.
+func (c *RpcClient) SetEncoding(encoding RpcEncoding) {
+	c.encoding = encoding
+}
+
+// Call calls <method> of the server with <request>, and decodes the response to <response>
+// if it's not nil. The call is canceled if <ctx> is done, and the deadline of <ctx> is also
+// passed to the server handler.
+//
+// It returns *RpcError if the server responds error.
+func (c *RpcClient) Call(ctx context.Context, method string, request interface{}, response interface{}) error {
+	if len(method) > 0xFFFF {
+		return fmt.Errorf(`method name too long: %d`, len(method))
+	}
+	payload, err := c.encoding.Marshal(request)
+	if err != nil {
+		return err
+	}
+	message := &rpcMessage{
+		kind:    gRPC_KIND_REQUEST,
+		id:      c.nextId.Add(1),
+		method:  method,
+		payload: payload,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if message.timeout = time.Until(deadline).Milliseconds(); message.timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+	resultChan := make(chan *rpcMessage, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.pending[message.id] = resultChan
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, message.id)
+		c.mu.Unlock()
+	}()
+
+	c.writeMu.Lock()
+	err = c.conn.SendFrame(message.encode())
+	c.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case result := <-resultChan:
+		if result == nil {
+			c.mu.Lock()
+			err = c.err
+			c.mu.Unlock()
+			return err
+		}
+		if result.status != gRPC_STATUS_OK {
+			return &RpcError{
+				Method:  method,
+				Message: string(result.payload),
+			}
+		}
+		if response != nil {
+			return c.encoding.Unmarshal(result.payload, response)
+		}
+		return nil
+	}
+}
+
+// Close closes the connection, and all the pending calls fail.
+func (c *RpcClient) Close() error {
+	return c.conn.Close()
+}
+
+// receive reads the responses and dispatches them to the pending calls,
+// until the connection is closed.
+func (c *RpcClient) receive() {
+	for {
+		frame, err := c.conn.RecvFrame()
+		if err == nil {
+			var message *rpcMessage
+			if message, err = decodeRpcMessage(frame); err == nil && message.kind != gRPC_KIND_RESPONSE {
+				err = errors.New("invalid rpc response")
+			}
+			if err == nil {
+				// The pending call is removed once dispatched, so that the duplicated
+				// response from a misbehaving server never blocks the receiving.
+				c.mu.Lock()
+				if resultChan, ok := c.pending[message.id]; ok {
+					delete(c.pending, message.id)
+					select {
+					case resultChan <- message:
+					default:
+					}
+				}
+				c.mu.Unlock()
+				continue
+			}
+		}
+		// Fails all pending calls.
+		c.conn.Close()
+		c.mu.Lock()
+		c.err = fmt.Errorf(`rpc connection closed: %v`, err)
+		for _, resultChan := range c.pending {
+			select {
+			case resultChan <- nil:
+			default:
+			}
+		}
+		c.mu.Unlock()
+		return
+	}
+}
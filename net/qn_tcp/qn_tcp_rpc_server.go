@@ -0,0 +1,142 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qnsoft/common/os/qn_log"
+)
+
+// RpcServer is the rpc server routing the requests to handlers by method name.
+// The requests from the same connection are handled concurrently.
+type RpcServer struct {
+	*Server                            // Underlying TCP server.
+	mu       sync.RWMutex              // Mutex for handlers.
+	handlers map[string]RpcHandlerFunc // Method name to handler.
+	encoding RpcEncoding               // Encoding for payloads.
+}
+
+// RpcRequest is the rpc request passed to the handler.
+type RpcRequest struct {
+	Conn     *Conn       // Connection of the request.
+	Method   string      // Requested method name.
+	Payload  []byte      // Raw request payload.
+	encoding RpcEncoding // Encoding for payloads.
+}
+
+// RpcHandlerFunc is the handler of rpc method, whose result is encoded as the response payload.
+// The parameter <ctx> is done if the connection is closed or the deadline of the call exceeds.
+type RpcHandlerFunc = func(ctx context.Context, r *RpcRequest) (interface{}, error)
+
+// NewRpcServer creates and returns a new rpc server listening on <address>.
+// The parameter <name> is optional, which is used to specify the instance name of the server.
+func NewRpcServer(address string, name ...string) *RpcServer {
+	s := &RpcServer{
+		handlers: make(map[string]RpcHandlerFunc),
+		encoding: RpcEncodingJson,
+	}
+	s.Server = NewServer(address, s.handleConn, name...)
+	s.Server.SetCodec(rpcCodec)
+	return s
+}
+
+// BindHandler registers <handler> for <method>.
+func (s *RpcServer) BindHandler(method string, handler RpcHandlerFunc) {
+	s.mu.Lock()
+	s.handlers[method] = handler
+	s.mu.Unlock()
+}
+
+// SetEncoding sets the encoding for payloads, which is RpcEncodingJson in default.
+func (s *RpcServer) SetEncoding(encoding RpcEncoding) {
+	s.encoding = encoding
+}
+
+// Parse decodes the request payload to <pointer>.
+func (r *RpcRequest) Parse(pointer interface{}) error {
+	return r.encoding.Unmarshal(r.Payload, pointer)
+}
+
+// handleConn reads and handles the rpc requests of <conn> concurrently.
+func (s *RpcServer) handleConn(conn *Conn) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		writeMu     = sync.Mutex{}
+		wg          = sync.WaitGroup{}
+	)
+	defer func() {
+		cancel()
+		wg.Wait()
+		conn.Close()
+	}()
+	for {
+		frame, err := conn.RecvFrame()
+		if err != nil {
+			return
+		}
+		message, err := decodeRpcMessage(frame)
+		if err != nil || message.kind != gRPC_KIND_REQUEST {
+			qn_log.Errorf(`invalid rpc request from %s: %v`, conn.RemoteAddr(), err)
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response := s.call(ctx, conn, message)
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if err := conn.SendFrame(response.encode()); err != nil {
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// call calls the handler of the request <message> and returns the response message.
+func (s *RpcServer) call(ctx context.Context, conn *Conn, message *rpcMessage) (response *rpcMessage) {
+	response = &rpcMessage{
+		kind: gRPC_KIND_RESPONSE,
+		id:   message.id,
+	}
+	s.mu.RLock()
+	handler, ok := s.handlers[message.method]
+	s.mu.RUnlock()
+	if !ok {
+		response.status = gRPC_STATUS_ERROR
+		response.payload = []byte(fmt.Sprintf(`method "%s" not found`, message.method))
+		return
+	}
+	if message.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(message.timeout)*time.Millisecond)
+		defer cancel()
+	}
+	defer func() {
+		if exception := recover(); exception != nil {
+			response.status = gRPC_STATUS_ERROR
+			response.payload = []byte(fmt.Sprintf(`%v`, exception))
+		}
+	}()
+	result, err := handler(ctx, &RpcRequest{
+		Conn:     conn,
+		Method:   message.method,
+		Payload:  message.payload,
+		encoding: s.encoding,
+	})
+	if err == nil {
+		response.payload, err = s.encoding.Marshal(result)
+	}
+	if err != nil {
+		response.status = gRPC_STATUS_ERROR
+		response.payload = []byte(err.Error())
+	}
+	return
+}
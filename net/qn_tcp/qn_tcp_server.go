@@ -11,8 +11,10 @@ import (
 	"errors"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/qnsoft/common/container/qn_map"
+	"github.com/qnsoft/common/container/qn_type"
 	"github.com/qnsoft/common/os/qn_log"
 	qn_conv "github.com/qnsoft/common/util/qn_conv"
 )
@@ -20,15 +22,27 @@ import (
 const (
 	// Default TCP server name.
 	gDEFAULT_SERVER = "default"
+	// Min and max backoff duration for temporary accepting error.
+	gACCEPT_BACKOFF_MIN = 5 * time.Millisecond
+	gACCEPT_BACKOFF_MAX = time.Second
 )
 
 // TCP Server.
 type Server struct {
-	mu        sync.Mutex   // Used for Server.listen concurrent safety.
-	listen    net.Listener // Listener.
-	address   string       // Server listening address.
-	handler   func(*Conn)  // Connection handler.
-	tlsConfig *tls.Config  // TLS configuration.
+	mu           sync.Mutex        // Used for Server.listen concurrent safety.
+	listen       net.Listener      // Listener.
+	address      string            // Server listening address.
+	handler      func(*Conn)       // Connection handler.
+	tlsConfig    *tls.Config       // TLS configuration.
+	maxConns     int               // Max count of concurrent connections, no limit if 0.
+	idleTimeout  time.Duration     // Idle timeout for connections, no timeout if 0.
+	onConnect    func(*Conn)       // Hook called before the connection is handled.
+	onDisconnect func(*Conn)       // Hook called after the connection handler returns.
+	codec        Codec             // Codec for framing data of connections.
+	conns        *qn_map.AnyAnyMap // Live connections, *Conn to its *serverConn.
+	wg           sync.WaitGroup    // Running connection handlers.
+	closed       *qn_type.Bool     // Whether the server is closed.
+	done         chan struct{}     // Closed when the server is closed.
 }
 
 // Map for name to server, for singleton purpose.
@@ -53,6 +67,8 @@ func NewServer(address string, handler func(*Conn), name ...string) *Server {
 	s := &Server{
 		address: address,
 		handler: handler,
+		conns:   qn_map.NewAnyAnyMap(true),
+		closed:  qn_type.NewBool(),
 	}
 	if len(name) > 0 && name[0] != "" {
 		serverMapping.Set(name[0], s)
@@ -103,10 +119,48 @@ func (s *Server) SetTLSConfig(tlsConfig *tls.Config) {
 	s.tlsConfig = tlsConfig
 }
 
+// SetMaxConns sets the max count of concurrent connections for server.
+// The server stops accepting new connections when the limit is reached,
+// and the new connections wait in the listening backlog. It's no limit if <max> is 0.
+func (s *Server) SetMaxConns(max int) {
+	s.maxConns = max
+}
+
+// SetIdleTimeout sets the idle timeout for connections of server.
+// The connection is closed if there's no reading or writing in <timeout>.
+// It's no timeout if <timeout> is 0.
+func (s *Server) SetIdleTimeout(timeout time.Duration) {
+	s.idleTimeout = timeout
+}
+
+// SetCodec sets the codec for framing data of the accepted connections,
+// so that the handler can read whole frames using Conn.RecvFrame.
+func (s *Server) SetCodec(codec Codec) {
+	s.codec = codec
+}
+
+// SetOnConnect sets the hook <f>, which is called before the connection is passed to handler.
+func (s *Server) SetOnConnect(f func(*Conn)) {
+	s.onConnect = f
+}
+
+// SetOnDisconnect sets the hook <f>, which is called after the connection handler returns.
+func (s *Server) SetOnDisconnect(f func(*Conn)) {
+	s.onDisconnect = f
+}
+
 // Close closes the listener and shutdowns the server.
+// The running connections are not closed, use Shutdown for graceful shutdown.
+// Note that the closed server cannot be run again.
 func (s *Server) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if !s.closed.Cas(false, true) {
+		return nil
+	}
+	if s.done != nil {
+		close(s.done)
+	}
 	if s.listen == nil {
 		return nil
 	}
@@ -120,11 +174,10 @@ func (s *Server) Run() (err error) {
 		qn_log.Error(err)
 		return
 	}
+	var listen net.Listener
 	if s.tlsConfig != nil {
 		// TLS Server
-		s.mu.Lock()
-		s.listen, err = tls.Listen("tcp", s.address, s.tlsConfig)
-		s.mu.Unlock()
+		listen, err = tls.Listen("tcp", s.address, s.tlsConfig)
 		if err != nil {
 			qn_log.Error(err)
 			return
@@ -136,20 +189,77 @@ func (s *Server) Run() (err error) {
 			qn_log.Error(err)
 			return err
 		}
-		s.mu.Lock()
-		s.listen, err = net.ListenTCP("tcp", addr)
-		s.mu.Unlock()
+		listen, err = net.ListenTCP("tcp", addr)
 		if err != nil {
 			qn_log.Error(err)
 			return err
 		}
 	}
+	s.mu.Lock()
+	// The server might be closed before it starts listening.
+	if s.closed.Val() {
+		s.mu.Unlock()
+		listen.Close()
+		return nil
+	}
+	s.listen = listen
+	s.done = make(chan struct{})
+	done := s.done
+	s.mu.Unlock()
+	if s.idleTimeout > 0 {
+		go s.checkIdleConns(done)
+	}
 	// Listening loop.
+	var (
+		semaphore chan struct{}
+		backoff   time.Duration
+	)
+	if s.maxConns > 0 {
+		semaphore = make(chan struct{}, s.maxConns)
+	}
 	for {
-		if conn, err := s.listen.Accept(); err != nil {
+		if semaphore != nil {
+			select {
+			case semaphore <- struct{}{}:
+			case <-done:
+				return nil
+			}
+		}
+		conn, err := listen.Accept()
+		if err != nil {
+			if semaphore != nil {
+				<-semaphore
+			}
+			if s.closed.Val() {
+				return err
+			}
+			// Backoff for the temporary error like "too many open files".
+			if e, ok := err.(net.Error); ok && e.Temporary() {
+				if backoff == 0 {
+					backoff = gACCEPT_BACKOFF_MIN
+				} else if backoff *= 2; backoff > gACCEPT_BACKOFF_MAX {
+					backoff = gACCEPT_BACKOFF_MAX
+				}
+				qn_log.Errorf("accept error: %v, retrying in %v", err, backoff)
+				time.Sleep(backoff)
+				continue
+			}
 			return err
-		} else if conn != nil {
-			go s.handler(NewConnByNetConn(conn))
 		}
+		backoff = 0
+		// The connection handler is added under the lock, so that it's never missed by
+		// Shutdown, which waits for the handlers after the server is marked closed.
+		s.mu.Lock()
+		if s.closed.Val() {
+			s.mu.Unlock()
+			conn.Close()
+			if semaphore != nil {
+				<-semaphore
+			}
+			return nil
+		}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn, semaphore)
 	}
 }
@@ -0,0 +1,125 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/os/qn_log"
+)
+
+// serverConn wraps the accepted net.Conn for tracking its last active time.
+type serverConn struct {
+	net.Conn
+	lastActive *qn_type.Int64 // Last reading or writing timestamp in nanoseconds.
+}
+
+// Read implements the io.Reader interface and updates the last active time.
+func (c *serverConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	if n > 0 {
+		c.lastActive.Set(time.Now().UnixNano())
+	}
+	return
+}
+
+// Write implements the io.Writer interface and updates the last active time.
+func (c *serverConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	if n > 0 {
+		c.lastActive.Set(time.Now().UnixNano())
+	}
+	return
+}
+
+// Conns returns a snapshot of the live connections of the server.
+func (s *Server) Conns() []*Conn {
+	conns := make([]*Conn, 0, s.conns.Size())
+	for _, k := range s.conns.Keys() {
+		conns = append(conns, k.(*Conn))
+	}
+	return conns
+}
+
+// Shutdown gracefully shuts down the server: it stops accepting new connections and then
+// waits for all the connection handlers to return. If <ctx> is done before that, it closes
+// all the live connections and returns the error of <ctx>.
+//
+// Note that the connection handler should return when its connection is closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.Close()
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return err
+	case <-ctx.Done():
+		for _, conn := range s.Conns() {
+			conn.Close()
+		}
+		return ctx.Err()
+	}
+}
+
+// serveConn calls the hooks and handler for the accepted connection <conn>.
+func (s *Server) serveConn(conn net.Conn, semaphore chan struct{}) {
+	sc := &serverConn{
+		Conn:       conn,
+		lastActive: qn_type.NewInt64(time.Now().UnixNano()),
+	}
+	c := NewConnByNetConn(sc)
+	c.SetCodec(s.codec)
+	s.conns.Set(c, sc)
+	defer func() {
+		if exception := recover(); exception != nil {
+			qn_log.Errorf("connection handler panics: %v", exception)
+		}
+		if s.onDisconnect != nil {
+			s.onDisconnect(c)
+		}
+		s.conns.Remove(c)
+		if semaphore != nil {
+			<-semaphore
+		}
+		s.wg.Done()
+	}()
+	if s.onConnect != nil {
+		s.onConnect(c)
+	}
+	s.handler(c)
+}
+
+// checkIdleConns closes the connections idle for longer than the idle timeout,
+// until <done> is closed.
+func (s *Server) checkIdleConns(done chan struct{}) {
+	interval := s.idleTimeout / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(-s.idleTimeout).UnixNano()
+			s.conns.Iterator(func(k, v interface{}) bool {
+				if v.(*serverConn).lastActive.Val() < deadline {
+					k.(*Conn).Close()
+				}
+				return true
+			})
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/net/qn_tcp"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Codec_Encode_Decode(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		codecs := []qn_tcp.Codec{
+			&qn_tcp.LengthFieldCodec{},
+			&qn_tcp.LengthFieldCodec{LengthSize: 4, LittleEndian: true},
+			&qn_tcp.LengthFieldCodec{LengthSize: 8},
+			&qn_tcp.DelimiterCodec{Delimiter: []byte("\r\n")},
+			&qn_tcp.VarintCodec{},
+		}
+		for _, codec := range codecs {
+			buffer := bytes.NewBuffer(nil)
+			for _, data := range []string{"hello", "", "world"} {
+				frame, err := codec.Encode([]byte(data))
+				t.Assert(err, nil)
+				buffer.Write(frame)
+			}
+			reader := bufio.NewReader(buffer)
+			for _, data := range []string{"hello", "", "world"} {
+				frame, err := codec.Decode(reader)
+				t.Assert(err, nil)
+				t.Assert(string(frame), data)
+			}
+		}
+	})
+	// Length field with header and adjustment: Type(1)|Length(2, including header)|Body.
+	qn_test.C(t, func(t *qn_test.T) {
+		codec := &qn_tcp.LengthFieldCodec{
+			LengthOffset:     1,
+			LengthSize:       2,
+			LengthAdjustment: -3,
+			MaxFrameSize:     5,
+		}
+		frame, err := codec.Encode([]byte("\x01hello"))
+		t.Assert(err, nil)
+		t.Assert(frame, []byte("\x01\x00\x08hello"))
+		data, err := codec.Decode(bufio.NewReader(bytes.NewReader(frame)))
+		t.Assert(err, nil)
+		t.Assert(string(data), "\x01hello")
+
+		codec.KeepLengthField = true
+		data, err = codec.Decode(bufio.NewReader(bytes.NewReader(frame)))
+		t.Assert(err, nil)
+		t.Assert(data, frame)
+
+		_, err = codec.Decode(bufio.NewReader(bytes.NewReader([]byte("\x01\x00\x09hello!"))))
+		t.AssertNE(err, nil)
+	})
+	// Fixed length.
+	qn_test.C(t, func(t *qn_test.T) {
+		codec := &qn_tcp.FixedLengthCodec{Length: 3}
+		_, err := codec.Encode([]byte("ab"))
+		t.AssertNE(err, nil)
+		reader := bufio.NewReader(bytes.NewReader([]byte("abcdef")))
+		data, err := codec.Decode(reader)
+		t.Assert(err, nil)
+		t.Assert(string(data), "abc")
+		data, err = codec.Decode(reader)
+		t.Assert(err, nil)
+		t.Assert(string(data), "def")
+	})
+	// Max frame size.
+	qn_test.C(t, func(t *qn_test.T) {
+		_, err := (&qn_tcp.LengthFieldCodec{LengthSize: 1}).Encode(make([]byte, 256))
+		t.AssertNE(err, nil)
+		_, err = (&qn_tcp.VarintCodec{MaxFrameSize: 10}).Encode(make([]byte, 11))
+		t.AssertNE(err, nil)
+
+		frame, _ := (&qn_tcp.LengthFieldCodec{LengthSize: 4}).Encode(make([]byte, 100))
+		_, err = (&qn_tcp.LengthFieldCodec{LengthSize: 4, MaxFrameSize: 10}).Decode(
+			bufio.NewReader(bytes.NewReader(frame)),
+		)
+		t.AssertNE(err, nil)
+
+		_, err = (&qn_tcp.DelimiterCodec{MaxFrameSize: 10}).Decode(
+			bufio.NewReader(bytes.NewReader(bytes.Repeat([]byte("a"), 100))),
+		)
+		t.AssertNE(err, nil)
+	})
+}
+
+func Test_Codec_Server(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_tcp.NewServer(fmt.Sprintf(`:%d`, p), func(conn *qn_tcp.Conn) {
+		defer conn.Close()
+		for {
+			data, err := conn.RecvFrame()
+			if err != nil {
+				break
+			}
+			conn.SendFrame(append([]byte("> "), data...))
+		}
+	})
+	s.SetCodec(&qn_tcp.DelimiterCodec{})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		conn, err := qn_tcp.NewConn(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		defer conn.Close()
+		conn.SetCodec(&qn_tcp.DelimiterCodec{})
+		// Multiple frames in one writing.
+		t.Assert(conn.Send([]byte("a\nb\n")), nil)
+		data, err := conn.RecvFrameWithTimeout(time.Second)
+		t.Assert(err, nil)
+		t.Assert(string(data), "> a")
+		data, err = conn.RecvFrameWithTimeout(time.Second)
+		t.Assert(err, nil)
+		t.Assert(string(data), "> b")
+		data, err = conn.SendRecvFrame([]byte("c"))
+		t.Assert(err, nil)
+		t.Assert(string(data), "> c")
+	})
+}
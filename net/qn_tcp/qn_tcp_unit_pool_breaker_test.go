@@ -0,0 +1,66 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/net/qn_breaker"
+	"github.com/qnsoft/common/net/qn_tcp"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Pool_Breaker(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_tcp.NewServer(fmt.Sprintf(`:%d`, p), func(conn *qn_tcp.Conn) {
+		defer conn.Close()
+		for {
+			data, err := conn.RecvPkg()
+			if err != nil {
+				break
+			}
+			conn.SendPkg(data)
+		}
+	})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		var (
+			addr  = fmt.Sprintf("127.0.0.1:%d", p)
+			group = qn_breaker.NewGroup(qn_breaker.Config{
+				ConsecutiveFailures: 1,
+				CoolDown:            100 * time.Millisecond,
+				HalfOpenRequests:    2,
+			})
+			breaker = group.Get(addr)
+		)
+		t.Assert(breaker.Allow(), nil)
+		breaker.Report(false)
+		t.Assert(breaker.State(), qn_breaker.STATE_OPEN)
+		_, err := qn_tcp.NewPoolConnWithBreaker(addr, group)
+		t.Assert(err, qn_breaker.ErrOpen)
+
+		// Each borrowed connection is reported once, no matter how many operations it does.
+		time.Sleep(150 * time.Millisecond)
+		for i := 0; i < 2; i++ {
+			conn, err := qn_tcp.NewPoolConnWithBreaker(addr, group)
+			t.Assert(err, nil)
+			for j := 0; j < 3; j++ {
+				data, err := conn.SendRecvPkg([]byte("hello"))
+				t.Assert(err, nil)
+				t.Assert(string(data), "hello")
+			}
+			t.Assert(breaker.State(), qn_breaker.STATE_HALF_OPEN)
+			conn.Close()
+		}
+		t.Assert(breaker.State(), qn_breaker.STATE_CLOSED)
+	})
+}
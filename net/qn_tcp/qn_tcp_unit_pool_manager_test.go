@@ -0,0 +1,164 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/net/qn_tcp"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Pool_Manager(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_tcp.NewServer(fmt.Sprintf(`:%d`, p), func(conn *qn_tcp.Conn) {
+		defer conn.Close()
+		for {
+			data, err := conn.RecvPkg()
+			if err != nil {
+				break
+			}
+			conn.SendPkg(data)
+		}
+	})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	address := fmt.Sprintf("127.0.0.1:%d", p)
+	// Reusing and stats.
+	qn_test.C(t, func(t *qn_test.T) {
+		pool := qn_tcp.NewPool(address)
+		defer pool.Close()
+
+		conn, err := pool.Get()
+		t.Assert(err, nil)
+		result, err := conn.SendRecvPkg([]byte("hello"))
+		t.Assert(err, nil)
+		t.Assert(string(result), "hello")
+		t.Assert(pool.Stats().InUse, 1)
+		t.Assert(conn.Close(), nil)
+		t.Assert(pool.Stats().IdleConns, 1)
+
+		conn2, err := pool.Get()
+		t.Assert(err, nil)
+		t.Assert(conn2 == conn, true)
+		conn2.Close()
+
+		stats := pool.Stats()
+		t.Assert(stats.OpenConns, 1)
+		t.Assert(stats.Hits, 1)
+		t.Assert(stats.Misses, 1)
+	})
+	// Closing more than once.
+	qn_test.C(t, func(t *qn_test.T) {
+		pool := qn_tcp.NewPool(address, qn_tcp.PoolConfig{
+			MaxOpen: 1,
+		})
+		defer pool.Close()
+
+		conn, err := pool.Get()
+		t.Assert(err, nil)
+		t.Assert(conn.Close(), nil)
+		t.Assert(conn.Close(), qn_tcp.ErrorPoolConnReturned)
+		stats := pool.Stats()
+		t.Assert(stats.OpenConns, 1)
+		t.Assert(stats.IdleConns, 1)
+
+		conn2, err := pool.Get()
+		t.Assert(err, nil)
+		t.Assert(conn2 == conn, true)
+		_, err = pool.Get()
+		t.Assert(err, qn_tcp.ErrorPoolExhausted)
+		t.Assert(conn2.Close(), nil)
+	})
+	// Max open and waiting.
+	qn_test.C(t, func(t *qn_test.T) {
+		pool := qn_tcp.NewPool(address, qn_tcp.PoolConfig{
+			MaxOpen:     1,
+			WaitTimeout: 200 * time.Millisecond,
+		})
+		defer pool.Close()
+
+		conn, err := pool.Get()
+		t.Assert(err, nil)
+		_, err = pool.Get()
+		t.Assert(err, qn_tcp.ErrorPoolExhausted)
+		t.Assert(pool.Stats().Timeouts, 1)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			conn.Close()
+		}()
+		conn2, err := pool.Get()
+		t.Assert(err, nil)
+		t.Assert(conn2 == conn, true)
+		conn2.Close()
+		t.Assert(pool.Stats().WaitCount, 2)
+	})
+	// Max idle.
+	qn_test.C(t, func(t *qn_test.T) {
+		pool := qn_tcp.NewPool(address, qn_tcp.PoolConfig{
+			MaxIdle: 1,
+		})
+		defer pool.Close()
+
+		conn1, err := pool.Get()
+		t.Assert(err, nil)
+		conn2, err := pool.Get()
+		t.Assert(err, nil)
+		conn1.Close()
+		conn2.Close()
+		stats := pool.Stats()
+		t.Assert(stats.OpenConns, 1)
+		t.Assert(stats.IdleConns, 1)
+		t.Assert(stats.IdleClosed, 1)
+	})
+	// Health checking on borrow.
+	qn_test.C(t, func(t *qn_test.T) {
+		healthy := qn_type.NewBool(true)
+		pool := qn_tcp.NewPool(address, qn_tcp.PoolConfig{
+			Ping: func(conn *qn_tcp.Conn) error {
+				if !healthy.Val() {
+					return errors.New("unhealthy")
+				}
+				return nil
+			},
+		})
+		defer pool.Close()
+
+		conn, err := pool.Get()
+		t.Assert(err, nil)
+		conn.Close()
+		healthy.Set(false)
+		conn2, err := pool.Get()
+		t.Assert(err, nil)
+		t.Assert(conn2 == conn, false)
+		conn2.Close()
+		t.Assert(pool.Stats().PingFailures, 1)
+		t.Assert(pool.Stats().OpenConns, 1)
+	})
+	// Closing.
+	qn_test.C(t, func(t *qn_test.T) {
+		pool := qn_tcp.NewPool(address)
+		conn1, err := pool.Get()
+		t.Assert(err, nil)
+		conn2, err := pool.Get()
+		t.Assert(err, nil)
+		conn1.Close()
+		t.Assert(pool.Close(), nil)
+		t.Assert(pool.Stats().OpenConns, 1)
+		conn2.Close()
+		t.Assert(pool.Stats().OpenConns, 0)
+		_, err = pool.Get()
+		t.Assert(err, qn_tcp.ErrorPoolClosed)
+	})
+}
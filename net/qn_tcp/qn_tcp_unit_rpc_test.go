@@ -0,0 +1,147 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/net/qn_tcp"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+type rpcSumReq struct {
+	A, B  int
+	Sleep int
+}
+
+func Test_Rpc_Call(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_tcp.NewRpcServer(fmt.Sprintf(":%d", p))
+	s.BindHandler("sum", func(ctx context.Context, r *qn_tcp.RpcRequest) (interface{}, error) {
+		var req *rpcSumReq
+		if err := r.Parse(&req); err != nil {
+			return nil, err
+		}
+		select {
+		case <-time.After(time.Duration(req.Sleep) * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return req.A + req.B, nil
+	})
+	s.BindHandler("error", func(ctx context.Context, r *qn_tcp.RpcRequest) (interface{}, error) {
+		return nil, errors.New("custom error")
+	})
+	s.BindHandler("panic", func(ctx context.Context, r *qn_tcp.RpcRequest) (interface{}, error) {
+		panic("custom panic")
+	})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		client, err := qn_tcp.NewRpcClient(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		defer client.Close()
+
+		var result int
+		t.Assert(client.Call(context.Background(), "sum", &rpcSumReq{A: 1, B: 2}, &result), nil)
+		t.Assert(result, 3)
+
+		// Concurrent calls, the slower ones do not block the faster ones.
+		var (
+			wg      = sync.WaitGroup{}
+			results = make([]int, 10)
+			errs    = make([]error, 10)
+		)
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := &rpcSumReq{A: i, B: i, Sleep: (10 - i) * 20}
+				errs[i] = client.Call(context.Background(), "sum", req, &results[i])
+			}(i)
+		}
+		wg.Wait()
+		t.Assert(time.Since(start) < time.Second, true)
+		for i := 0; i < 10; i++ {
+			t.Assert(errs[i], nil)
+			t.Assert(results[i], i*2)
+		}
+	})
+	// Errors.
+	qn_test.C(t, func(t *qn_test.T) {
+		client, err := qn_tcp.NewRpcClient(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		defer client.Close()
+
+		err = client.Call(context.Background(), "error", nil, nil)
+		rpcErr, ok := err.(*qn_tcp.RpcError)
+		t.Assert(ok, true)
+		t.Assert(rpcErr.Message, "custom error")
+
+		err = client.Call(context.Background(), "panic", nil, nil)
+		t.Assert(err.(*qn_tcp.RpcError).Message, "custom panic")
+
+		err = client.Call(context.Background(), "none", nil, nil)
+		t.AssertNE(err.(*qn_tcp.RpcError), nil)
+
+		// Timeout.
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err = client.Call(ctx, "sum", &rpcSumReq{Sleep: 1000}, nil)
+		t.Assert(err, context.DeadlineExceeded)
+
+		// The connection is still usable after timeout.
+		var result int
+		t.Assert(client.Call(context.Background(), "sum", &rpcSumReq{A: 2, B: 3}, &result), nil)
+		t.Assert(result, 5)
+	})
+	// Closed connection.
+	qn_test.C(t, func(t *qn_test.T) {
+		client, err := qn_tcp.NewRpcClient(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		client.Close()
+		time.Sleep(100 * time.Millisecond)
+		t.AssertNE(client.Call(context.Background(), "sum", &rpcSumReq{}, nil), nil)
+	})
+}
+
+func Test_Rpc_Binary(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_tcp.NewRpcServer(fmt.Sprintf(":%d", p))
+	s.SetEncoding(qn_tcp.RpcEncodingBinary)
+	s.BindHandler("echo", func(ctx context.Context, r *qn_tcp.RpcRequest) (interface{}, error) {
+		return append([]byte("echo:"), r.Payload...), nil
+	})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		client, err := qn_tcp.NewRpcClient(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		defer client.Close()
+		client.SetEncoding(qn_tcp.RpcEncodingBinary)
+
+		var result []byte
+		t.Assert(client.Call(context.Background(), "echo", []byte("hello"), &result), nil)
+		t.Assert(string(result), "echo:hello")
+
+		var str string
+		t.Assert(client.Call(context.Background(), "echo", "world", &str), nil)
+		t.Assert(str, "echo:world")
+
+		t.AssertNE(client.Call(context.Background(), "echo", 1, nil), nil)
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_tcp_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/net/qn_tcp"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Server_Conns(t *testing.T) {
+	var (
+		p, _         = ports.PopRand()
+		connected    = qn_type.NewInt()
+		disconnected = qn_type.NewInt()
+	)
+	s := qn_tcp.NewServer(fmt.Sprintf(`:%d`, p), func(conn *qn_tcp.Conn) {
+		defer conn.Close()
+		for {
+			data, err := conn.RecvPkg()
+			if err != nil {
+				break
+			}
+			conn.SendPkg(data)
+		}
+	})
+	s.SetMaxConns(1)
+	s.SetIdleTimeout(500 * time.Millisecond)
+	s.SetOnConnect(func(conn *qn_tcp.Conn) {
+		connected.Add(1)
+	})
+	s.SetOnDisconnect(func(conn *qn_tcp.Conn) {
+		disconnected.Add(1)
+	})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		conn1, err := qn_tcp.NewConn(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		defer conn1.Close()
+		data, err := conn1.SendRecvPkg([]byte("1"))
+		t.Assert(err, nil)
+		t.Assert(data, "1")
+		t.Assert(len(s.Conns()), 1)
+
+		// The second connection waits for the first one as max connections is 1.
+		conn2, err := qn_tcp.NewConn(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		defer conn2.Close()
+		t.Assert(conn2.SendPkg([]byte("2")), nil)
+		time.Sleep(100 * time.Millisecond)
+		t.Assert(connected.Val(), 1)
+
+		conn1.Close()
+		data, err = conn2.RecvPkg()
+		t.Assert(err, nil)
+		t.Assert(data, "2")
+		t.Assert(connected.Val(), 2)
+		t.Assert(disconnected.Val(), 1)
+
+		// Idle timeout.
+		time.Sleep(800 * time.Millisecond)
+		t.Assert(len(s.Conns()), 0)
+		t.Assert(disconnected.Val(), 2)
+		_, err = conn2.RecvPkg()
+		t.AssertNE(err, nil)
+	})
+}
+
+func Test_Server_Shutdown(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_tcp.NewServer(fmt.Sprintf(`:%d`, p), func(conn *qn_tcp.Conn) {
+		defer conn.Close()
+		for {
+			data, err := conn.RecvPkg()
+			if err != nil {
+				break
+			}
+			conn.SendPkg(data)
+		}
+	})
+	go s.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		conn, err := qn_tcp.NewConn(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		defer conn.Close()
+		data, err := conn.SendRecvPkg([]byte("1"))
+		t.Assert(err, nil)
+		t.Assert(data, "1")
+
+		// The handler is still running, it's closed forcibly after the deadline.
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		t.Assert(s.Shutdown(ctx), context.DeadlineExceeded)
+		time.Sleep(100 * time.Millisecond)
+		t.Assert(len(s.Conns()), 0)
+		_, err = conn.RecvPkg()
+		t.AssertNE(err, nil)
+
+		_, err = qn_tcp.NewConn(fmt.Sprintf("127.0.0.1:%d", p))
+		t.AssertNE(err, nil)
+		t.Assert(s.Shutdown(context.Background()), nil)
+	})
+}
+
+func Test_Server_CloseBeforeRun(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_tcp.NewServer(fmt.Sprintf(`:%d`, p), func(conn *qn_tcp.Conn) {
+		conn.Close()
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		t.Assert(s.Close(), nil)
+		result := make(chan error, 1)
+		go func() {
+			result <- s.Run()
+		}()
+		select {
+		case err := <-result:
+			t.Assert(err, nil)
+		case <-time.After(time.Second):
+			t.Error("closed server is still running")
+		}
+	})
+}
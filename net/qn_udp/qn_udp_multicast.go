@@ -0,0 +1,140 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_udp
+
+import (
+	"fmt"
+	"net"
+)
+
+// NewMulticastConn creates and returns a UDP connection listening on multicast <group>,
+// like "239.0.0.1:9999". The group is joined on the network interface named <ifaceName>,
+// or the system default interface if it's not given.
+//
+// Note that the returned connection is not connected, it can only reply to the remote address
+// of last received data, use NewConn for sending data to the group.
+func NewMulticastConn(group string, ifaceName ...string) (*Conn, error) {
+	addr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, err
+	}
+	iface, err := getInterface(ifaceName...)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP("udp", iface, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnByNetConn(conn), nil
+}
+
+// NewBroadcastConn creates and returns a UDP connection for sending data to broadcast <address>,
+// like "255.255.255.255:9999" or "192.168.1.255:9999".
+// The optional parameter <localAddress> specifies the local address for the connection.
+func NewBroadcastConn(address string, localAddress ...string) (*Conn, error) {
+	conn, err := NewConn(address, localAddress...)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetBroadcast(true); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// SendBroadcast writes data to broadcast <address> using UDP connection and then closes the connection.
+// Note that it is used for short connection usage.
+func SendBroadcast(address string, data []byte, retry ...Retry) error {
+	conn, err := NewBroadcastConn(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Send(data, retry...)
+}
+
+// JoinGroup joins multicast <group> on the network interface named <ifaceName>,
+// or the system default interface if it's not given. The port of <group> is ignored.
+//
+// Note that joining IPv6 group is not supported on windows, use NewMulticastConn instead.
+func (c *Conn) JoinGroup(group string, ifaceName ...string) error {
+	return c.setGroup(true, group, ifaceName...)
+}
+
+// LeaveGroup leaves multicast <group> on the network interface named <ifaceName>,
+// or the system default interface if it's not given. The port of <group> is ignored.
+func (c *Conn) LeaveGroup(group string, ifaceName ...string) error {
+	return c.setGroup(false, group, ifaceName...)
+}
+
+// SetMulticastTTL sets the TTL (hop limit for IPv6) of the multicast data sent by the connection,
+// which is 1 in default and limits the data in the local network.
+func (c *Conn) SetMulticastTTL(ttl int) error {
+	return c.setMulticastTTL(ttl)
+}
+
+// SetMulticastLoopback enables/disables the multicast data sent by the connection
+// to be looped back to the local host, which is enabled in default.
+func (c *Conn) SetMulticastLoopback(enabled bool) error {
+	return c.setMulticastLoopback(enabled)
+}
+
+// SetBroadcast enables/disables the connection sending data to broadcast address.
+func (c *Conn) SetBroadcast(enabled bool) error {
+	return c.setBroadcast(enabled)
+}
+
+// isIPv6 checks whether the connection is using IPv6.
+func (c *Conn) isIPv6() bool {
+	if addr, ok := c.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP.To4() == nil && len(addr.IP) == net.IPv6len
+	}
+	return false
+}
+
+// resolveGroupIP resolves and returns the multicast IP of <group>, which can be with or without port.
+func resolveGroupIP(group string) (net.IP, error) {
+	host := group
+	if h, _, err := net.SplitHostPort(group); err == nil {
+		host = h
+	}
+	addr, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if !addr.IP.IsMulticast() {
+		return nil, fmt.Errorf(`"%s" is not a multicast address`, group)
+	}
+	return addr.IP, nil
+}
+
+// getInterface returns the network interface named <name>,
+// or nil for the system default interface if <name> is not given.
+func getInterface(name ...string) (*net.Interface, error) {
+	if len(name) == 0 || name[0] == "" {
+		return nil, nil
+	}
+	return net.InterfaceByName(name[0])
+}
+
+// getInterfaceIPv4 returns the first IPv4 address of <iface>.
+func getInterfaceIPv4(iface *net.Interface) (net.IP, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			if ip4 := ipNet.IP.To4(); ip4 != nil {
+				return ip4, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf(`no IPv4 address found for interface "%s"`, iface.Name)
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package qn_udp
+
+import "errors"
+
+// errorSockoptNotSupported is returned for setting socket options on unsupported platforms.
+var errorSockoptNotSupported = errors.New("socket option is not supported on this platform")
+
+func (c *Conn) setMulticastTTL(ttl int) error {
+	return errorSockoptNotSupported
+}
+
+func (c *Conn) setMulticastLoopback(enabled bool) error {
+	return errorSockoptNotSupported
+}
+
+func (c *Conn) setBroadcast(enabled bool) error {
+	return errorSockoptNotSupported
+}
+
+func (c *Conn) setGroup(join bool, group string, ifaceName ...string) error {
+	return errorSockoptNotSupported
+}
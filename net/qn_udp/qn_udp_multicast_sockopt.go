@@ -0,0 +1,107 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || windows
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris windows
+
+package qn_udp
+
+import "syscall"
+
+// setMulticastTTL sets the TTL (hop limit for IPv6) of the multicast data sent by the connection.
+func (c *Conn) setMulticastTTL(ttl int) error {
+	if c.isIPv6() {
+		return c.setsockoptInt(syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, ttl)
+	}
+	return c.setsockoptInt(syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, ttl)
+}
+
+// setMulticastLoopback enables/disables the multicast loopback of the connection.
+func (c *Conn) setMulticastLoopback(enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	if c.isIPv6() {
+		return c.setsockoptInt(syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_LOOP, value)
+	}
+	return c.setsockoptInt(syscall.IPPROTO_IP, syscall.IP_MULTICAST_LOOP, value)
+}
+
+// setBroadcast enables/disables the broadcast of the connection.
+func (c *Conn) setBroadcast(enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	return c.setsockoptInt(syscall.SOL_SOCKET, syscall.SO_BROADCAST, value)
+}
+
+// setGroup joins or leaves multicast <group> on the network interface named <ifaceName>.
+func (c *Conn) setGroup(join bool, group string, ifaceName ...string) error {
+	ip, err := resolveGroupIP(group)
+	if err != nil {
+		return err
+	}
+	iface, err := getInterface(ifaceName...)
+	if err != nil {
+		return err
+	}
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		mreq := &syscall.IPMreq{}
+		copy(mreq.Multiaddr[:], ip4)
+		if iface != nil {
+			ifaceIP, err := getInterfaceIPv4(iface)
+			if err != nil {
+				return err
+			}
+			copy(mreq.Interface[:], ifaceIP)
+		}
+		opt := syscall.IP_ADD_MEMBERSHIP
+		if !join {
+			opt = syscall.IP_DROP_MEMBERSHIP
+		}
+		if e := rawConn.Control(func(fd uintptr) {
+			err = setsockoptIPMreq(fd, syscall.IPPROTO_IP, opt, mreq)
+		}); e != nil {
+			return e
+		}
+		return err
+	}
+	mreq := &syscall.IPv6Mreq{}
+	copy(mreq.Multiaddr[:], ip.To16())
+	if iface != nil {
+		mreq.Interface = uint32(iface.Index)
+	}
+	opt := syscall.IPV6_JOIN_GROUP
+	if !join {
+		opt = syscall.IPV6_LEAVE_GROUP
+	}
+	if e := rawConn.Control(func(fd uintptr) {
+		err = setsockoptIPv6Mreq(fd, syscall.IPPROTO_IPV6, opt, mreq)
+	}); e != nil {
+		return e
+	}
+	return err
+}
+
+// setsockoptInt sets the integer socket option for the connection.
+func (c *Conn) setsockoptInt(level, opt, value int) error {
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	if e := rawConn.Control(func(fd uintptr) {
+		err = setsockoptInt(fd, level, opt, value)
+	}); e != nil {
+		return e
+	}
+	return err
+}
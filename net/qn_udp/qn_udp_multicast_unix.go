@@ -0,0 +1,24 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package qn_udp
+
+import "syscall"
+
+func setsockoptInt(fd uintptr, level, opt, value int) error {
+	return syscall.SetsockoptInt(int(fd), level, opt, value)
+}
+
+func setsockoptIPMreq(fd uintptr, level, opt int, mreq *syscall.IPMreq) error {
+	return syscall.SetsockoptIPMreq(int(fd), level, opt, mreq)
+}
+
+func setsockoptIPv6Mreq(fd uintptr, level, opt int, mreq *syscall.IPv6Mreq) error {
+	return syscall.SetsockoptIPv6Mreq(int(fd), level, opt, mreq)
+}
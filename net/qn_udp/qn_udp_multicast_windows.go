@@ -0,0 +1,30 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+//go:build windows
+// +build windows
+
+package qn_udp
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setsockoptInt(fd uintptr, level, opt, value int) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), level, opt, value)
+}
+
+func setsockoptIPMreq(fd uintptr, level, opt int, mreq *syscall.IPMreq) error {
+	return syscall.SetsockoptIPMreq(syscall.Handle(fd), level, opt, mreq)
+}
+
+// setsockoptIPv6Mreq is not supported on windows, as syscall.SetsockoptIPv6Mreq always
+// returns EWINDOWS. Note that NewMulticastConn and Server.SetMulticast still work for IPv6
+// group, which join the group by the net package.
+func setsockoptIPv6Mreq(fd uintptr, level, opt int, mreq *syscall.IPv6Mreq) error {
+	return errors.New("joining or leaving IPv6 multicast group is not supported on windows")
+}
@@ -9,8 +9,10 @@ package qn_udp
 import (
 	"errors"
 	"net"
+	"sync"
 
 	"github.com/qnsoft/common/container/qn_map"
+	"github.com/qnsoft/common/container/qn_type"
 	"github.com/qnsoft/common/os/qn_log"
 	qn_conv "github.com/qnsoft/common/util/qn_conv"
 )
@@ -21,9 +23,19 @@ const (
 
 // Server is the UDP server.
 type Server struct {
-	conn    *Conn       // UDP server connection object.
-	address string      // UDP server listening address.
-	handler func(*Conn) // Handler for UDP connection.
+	mu            sync.Mutex     // Mutex for connection object.
+	conn          *Conn          // UDP server connection object.
+	address       string         // UDP server listening address.
+	handler       func(*Conn)    // Handler for UDP connection.
+	packetHandler func(*Packet)  // Handler for UDP packet, which has priority over handler.
+	workers       int            // Number of workers handling packets.
+	queueSize     int            // Size of the queue for packets waiting to be handled.
+	bufferSize    int            // Max size of single packet.
+	readBuffer    int            // Size of the operating system's receive buffer for the connection.
+	multicast     bool           // Whether listening on the multicast group of the address.
+	multicastIf   string         // Network interface name for joining the multicast group.
+	closed        *qn_type.Bool  // Whether the server is closed.
+	wg            sync.WaitGroup // Waiting group for packet workers.
 }
 
 var (
@@ -50,8 +62,11 @@ func GetServer(name ...interface{}) *Server {
 // GetServer function to retrieve its instance.
 func NewServer(address string, handler func(*Conn), name ...string) *Server {
 	s := &Server{
-		address: address,
-		handler: handler,
+		address:    address,
+		handler:    handler,
+		queueSize:  gDEFAULT_PACKET_QUEUE_SIZE,
+		bufferSize: gDEFAULT_PACKET_BUFFER_SIZE,
+		closed:     qn_type.NewBool(),
 	}
 	if len(name) > 0 && name[0] != "" {
 		serverMapping.Set(name[0], s)
@@ -69,15 +84,36 @@ func (s *Server) SetHandler(handler func(*Conn)) {
 	s.handler = handler
 }
 
+// SetMulticast makes the server listen on the multicast group of its address, like "239.0.0.1:9999",
+// which is joined on the network interface named <ifaceName>, or the system default interface
+// if it's not given.
+func (s *Server) SetMulticast(ifaceName ...string) {
+	s.multicast = true
+	if len(ifaceName) > 0 {
+		s.multicastIf = ifaceName[0]
+	}
+}
+
 // Close closes the connection.
-// It will make server shutdowns immediately.
+// It will make server shutdowns immediately, use Shutdown for graceful shutdown.
+// Note that the closed server cannot be run again.
 func (s *Server) Close() error {
+	if !s.closed.Cas(false, true) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
 	return s.conn.Close()
 }
 
 // Run starts listening UDP connection.
+// If the packet handler is set, it reads and handles the packets with workers concurrently,
+// or else it hands the connection to the connection handler.
 func (s *Server) Run() error {
-	if s.handler == nil {
+	if s.handler == nil && s.packetHandler == nil {
 		err := errors.New("start running failed: socket handler not defined")
 		qn_log.Error(err)
 		return err
@@ -87,12 +123,39 @@ func (s *Server) Run() error {
 		qn_log.Error(err)
 		return err
 	}
-	conn, err := net.ListenUDP("udp", addr)
+	var (
+		conn  *net.UDPConn
+		iface *net.Interface
+	)
+	if s.multicast {
+		if iface, err = getInterface(s.multicastIf); err != nil {
+			qn_log.Error(err)
+			return err
+		}
+		conn, err = net.ListenMulticastUDP("udp", iface, addr)
+	} else {
+		conn, err = net.ListenUDP("udp", addr)
+	}
 	if err != nil {
 		qn_log.Error(err)
 		return err
 	}
+	s.mu.Lock()
+	// The server might be closed before it starts listening.
+	if s.closed.Val() {
+		s.mu.Unlock()
+		conn.Close()
+		return nil
+	}
 	s.conn = NewConnByNetConn(conn)
+	if s.packetHandler != nil {
+		// The reading loop is added under the lock, so that it's never missed by Shutdown.
+		s.wg.Add(1)
+	}
+	s.mu.Unlock()
+	if s.packetHandler != nil {
+		return s.servePackets(conn)
+	}
 	s.handler(s.conn)
 	return nil
 }
@@ -0,0 +1,154 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_udp
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"time"
+
+	"github.com/qnsoft/common/os/qn_log"
+)
+
+// Packet is the UDP packet received by the server.
+type Packet struct {
+	Data       []byte       // Packet data.
+	RemoteAddr *net.UDPAddr // Remote address of the packet.
+	conn       *net.UDPConn // Server connection, which is used for replying.
+}
+
+const (
+	gDEFAULT_PACKET_QUEUE_SIZE  = 1024  // Default size of the queue for packets waiting to be handled.
+	gDEFAULT_PACKET_BUFFER_SIZE = 65535 // Default max size of single packet.
+)
+
+// Reply sends <data> to the remote address of the packet.
+func (p *Packet) Reply(data []byte) error {
+	_, err := p.conn.WriteToUDP(data, p.RemoteAddr)
+	return err
+}
+
+// SetPacketHandler sets the packet handler for UDP server, which is called concurrently
+// by the workers for each received packet.
+func (s *Server) SetPacketHandler(handler func(*Packet)) {
+	s.packetHandler = handler
+}
+
+// SetWorkers sets the number of workers handling packets, which is runtime.NumCPU() in default.
+func (s *Server) SetWorkers(workers int) {
+	s.workers = workers
+}
+
+// SetQueueSize sets the size of the queue for packets waiting to be handled.
+// The server stops reading if the queue is full, and the further packets are buffered
+// by the operating system, see SetReadBuffer.
+func (s *Server) SetQueueSize(size int) {
+	s.queueSize = size
+}
+
+// SetBufferSize sets the max size of single packet, and the exceeding data is discarded.
+func (s *Server) SetBufferSize(size int) {
+	s.bufferSize = size
+}
+
+// SetReadBuffer sets the size of the operating system's receive buffer for the connection,
+// which should be enlarged for bursting packets.
+func (s *Server) SetReadBuffer(bytes int) {
+	s.readBuffer = bytes
+}
+
+// Shutdown gracefully shutdowns the server, which stops reading packets and waits the queued
+// packets handled before closing the connection. If <ctx> is done before that, it closes the
+// connection immediately and returns ctx.Err().
+func (s *Server) Shutdown(ctx context.Context) error {
+	if !s.closed.Cas(false, true) {
+		return nil
+	}
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	if s.packetHandler != nil {
+		// Unblocks the reading loop.
+		conn.SetReadDeadline(time.Now())
+		finished := make(chan struct{})
+		go func() {
+			s.wg.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			conn.Close()
+			return ctx.Err()
+		}
+	}
+	return conn.Close()
+}
+
+// servePackets reads the packets from <conn> and dispatches them to the workers.
+// Note that the reading loop is already added to the waiting group by Run.
+func (s *Server) servePackets(conn *net.UDPConn) error {
+	defer s.wg.Done()
+	if s.readBuffer > 0 {
+		if err := conn.SetReadBuffer(s.readBuffer); err != nil {
+			qn_log.Error(err)
+		}
+	}
+	workers := s.workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	queue := make(chan *Packet, s.queueSize)
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.handlePackets(queue)
+	}
+	defer close(queue)
+	buffer := make([]byte, s.bufferSize)
+	for {
+		size, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if s.closed.Val() {
+				return nil
+			}
+			if e, ok := err.(net.Error); ok && e.Temporary() {
+				continue
+			}
+			qn_log.Error(err)
+			return err
+		}
+		data := make([]byte, size)
+		copy(data, buffer[:size])
+		queue <- &Packet{
+			Data:       data,
+			RemoteAddr: addr,
+			conn:       conn,
+		}
+	}
+}
+
+// handlePackets calls the packet handler for the packets from <queue> until it's closed.
+func (s *Server) handlePackets(queue <-chan *Packet) {
+	defer s.wg.Done()
+	for packet := range queue {
+		s.handlePacket(packet)
+	}
+}
+
+// handlePacket calls the packet handler for <packet>, and recovers the panics of handler.
+func (s *Server) handlePacket(packet *Packet) {
+	defer func() {
+		if exception := recover(); exception != nil {
+			qn_log.Errorf("packet handler panics: %v", exception)
+		}
+	}()
+	s.packetHandler(packet)
+}
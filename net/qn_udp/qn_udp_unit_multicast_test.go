@@ -0,0 +1,85 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_udp_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/net/qn_udp"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Multicast_Conn(t *testing.T) {
+	p, _ := ports.PopRand()
+	group := fmt.Sprintf("239.0.0.1:%d", p)
+	qn_test.C(t, func(t *qn_test.T) {
+		listener, err := qn_udp.NewMulticastConn(group)
+		t.Assert(err, nil)
+		defer listener.Close()
+		t.Assert(listener.JoinGroup("239.0.0.2"), nil)
+		t.Assert(listener.LeaveGroup("239.0.0.2"), nil)
+		t.AssertNE(listener.JoinGroup("127.0.0.1"), nil)
+
+		conn, err := qn_udp.NewConn(group)
+		t.Assert(err, nil)
+		defer conn.Close()
+		t.Assert(conn.SetMulticastTTL(2), nil)
+		t.Assert(conn.SetMulticastLoopback(true), nil)
+		t.Assert(conn.Send([]byte("hello")), nil)
+
+		data, err := listener.RecvWithTimeout(-1, time.Second)
+		t.Assert(err, nil)
+		t.Assert(string(data), "hello")
+	})
+}
+
+func Test_Multicast_Server(t *testing.T) {
+	p, _ := ports.PopRand()
+	group := fmt.Sprintf("239.0.0.1:%d", p)
+	s := qn_udp.NewServer(group, nil)
+	s.SetMulticast()
+	s.SetPacketHandler(func(packet *qn_udp.Packet) {
+		packet.Reply(append([]byte("> "), packet.Data...))
+	})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		// The reply is sent from the unicast address of the server,
+		// so the client should be an unconnected socket.
+		groupAddr, err := net.ResolveUDPAddr("udp", group)
+		t.Assert(err, nil)
+		udpConn, err := net.ListenUDP("udp", &net.UDPAddr{})
+		t.Assert(err, nil)
+		conn := qn_udp.NewConnByNetConn(udpConn)
+		defer conn.Close()
+		t.Assert(conn.SetMulticastLoopback(true), nil)
+		_, err = conn.WriteToUDP([]byte("discover"), groupAddr)
+		t.Assert(err, nil)
+
+		t.Assert(conn.SetReadDeadline(time.Now().Add(time.Second)), nil)
+		buffer := make([]byte, 1024)
+		size, _, err := conn.ReadFromUDP(buffer)
+		t.Assert(err, nil)
+		t.Assert(string(buffer[:size]), "> discover")
+	})
+}
+
+func Test_Broadcast(t *testing.T) {
+	p, _ := ports.PopRand()
+	qn_test.C(t, func(t *qn_test.T) {
+		conn, err := qn_udp.NewBroadcastConn(fmt.Sprintf("255.255.255.255:%d", p))
+		t.Assert(err, nil)
+		defer conn.Close()
+		t.Assert(conn.SetBroadcast(false), nil)
+		t.Assert(conn.SetBroadcast(true), nil)
+	})
+}
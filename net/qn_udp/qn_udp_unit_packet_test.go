@@ -0,0 +1,118 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_udp_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_type"
+	"github.com/qnsoft/common/net/qn_udp"
+	"github.com/qnsoft/common/test/qn_test"
+	qn_conv "github.com/qnsoft/common/util/qn_conv"
+)
+
+func Test_Packet_Handler(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_udp.NewServer(fmt.Sprintf("127.0.0.1:%d", p), nil)
+	s.SetWorkers(4)
+	s.SetReadBuffer(1024 * 1024)
+	s.SetPacketHandler(func(packet *qn_udp.Packet) {
+		if string(packet.Data) == "panic" {
+			panic("custom panic")
+		}
+		packet.Reply(append([]byte("> "), packet.Data...))
+	})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		// The panic of handler does not stop the server.
+		t.Assert(qn_udp.Send(fmt.Sprintf("127.0.0.1:%d", p), []byte("panic")), nil)
+		for i := 0; i < 100; i++ {
+			result, err := qn_udp.SendRecv(fmt.Sprintf("127.0.0.1:%d", p), []byte(qn_conv.String(i)), -1)
+			t.Assert(err, nil)
+			t.Assert(string(result), fmt.Sprintf(`> %d`, i))
+		}
+	})
+}
+
+func Test_Packet_Concurrent(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_udp.NewServer(fmt.Sprintf("127.0.0.1:%d", p), nil)
+	s.SetWorkers(10)
+	s.SetPacketHandler(func(packet *qn_udp.Packet) {
+		time.Sleep(200 * time.Millisecond)
+		packet.Reply(packet.Data)
+	})
+	go s.Run()
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		conn, err := qn_udp.NewConn(fmt.Sprintf("127.0.0.1:%d", p))
+		t.Assert(err, nil)
+		defer conn.Close()
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			t.Assert(conn.Send([]byte(qn_conv.String(i))), nil)
+		}
+		for i := 0; i < 10; i++ {
+			_, err := conn.RecvWithTimeout(-1, time.Second)
+			t.Assert(err, nil)
+		}
+		t.Assert(time.Since(start) < time.Second, true)
+	})
+}
+
+func Test_Packet_Shutdown(t *testing.T) {
+	p, _ := ports.PopRand()
+	handled := qn_type.NewInt()
+	s := qn_udp.NewServer(fmt.Sprintf("127.0.0.1:%d", p), nil)
+	s.SetWorkers(1)
+	s.SetPacketHandler(func(packet *qn_udp.Packet) {
+		time.Sleep(50 * time.Millisecond)
+		handled.Add(1)
+	})
+	go s.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		for i := 0; i < 5; i++ {
+			t.Assert(qn_udp.Send(fmt.Sprintf("127.0.0.1:%d", p), []byte("data")), nil)
+		}
+		time.Sleep(20 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		// The queued packets are handled before shutdown.
+		t.Assert(s.Shutdown(ctx), nil)
+		t.Assert(handled.Val(), 5)
+		t.Assert(s.Shutdown(ctx), nil)
+	})
+}
+
+func Test_Packet_CloseBeforeRun(t *testing.T) {
+	p, _ := ports.PopRand()
+	s := qn_udp.NewServer(fmt.Sprintf("127.0.0.1:%d", p), nil)
+	s.SetPacketHandler(func(packet *qn_udp.Packet) {})
+	qn_test.C(t, func(t *qn_test.T) {
+		t.Assert(s.Shutdown(context.Background()), nil)
+		result := make(chan error, 1)
+		go func() {
+			result <- s.Run()
+		}()
+		select {
+		case err := <-result:
+			t.Assert(err, nil)
+		case <-time.After(time.Second):
+			t.Error("closed server is still running")
+		}
+	})
+}
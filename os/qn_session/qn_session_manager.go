@@ -16,9 +16,11 @@ import (
 
 // Manager for sessions.
 type Manager struct {
-	ttl         time.Duration   // TTL for sessions.
-	storage     Storage         // Storage interface for session storage.
-	sessionData *qn_cache.Cache // Session data cache for session TTL.
+	ttl         time.Duration     // TTL for sessions.
+	storage     Storage           // Storage interface for session storage.
+	sessionData *qn_cache.Cache   // Session data cache for session TTL.
+	index       *qn_map.StrAnyMap // User key to session ids index, used if storage is not StorageIndexer.
+	revokedIds  *qn_cache.Cache   // Revoked session ids, which cannot be restored by the sessions being used.
 }
 
 // New creates and returns a new session manager.
@@ -26,6 +28,8 @@ func New(ttl time.Duration, storage ...Storage) *Manager {
 	m := &Manager{
 		ttl:         ttl,
 		sessionData: qn_cache.New(),
+		index:       qn_map.NewStrAnyMap(true),
+		revokedIds:  qn_cache.New(),
 	}
 	if len(storage) > 0 && storage[0] != nil {
 		m.storage = storage[0]
@@ -65,6 +69,12 @@ func (m *Manager) TTL() time.Duration {
 }
 
 // UpdateSessionTTL updates the ttl for given session.
+//
+// The session data is not cached in memory if the storage is StorageEncoder, as the session
+// id itself contains the data, which changes whenever the data changes.
 func (m *Manager) UpdateSessionTTL(id string, data *qn_map.StrAnyMap) {
+	if _, ok := m.storage.(StorageEncoder); ok {
+		return
+	}
 	m.sessionData.Set(id, data, m.ttl)
 }
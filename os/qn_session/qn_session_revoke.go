@@ -0,0 +1,206 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session
+
+import (
+	"github.com/qnsoft/common/container/qn_map"
+	"github.com/qnsoft/common/container/qn_set"
+	"github.com/qnsoft/common/internal/intlog"
+	qn_conv "github.com/qnsoft/common/util/qn_conv"
+)
+
+const (
+	// gSESSION_USER_KEY is the reserved session key storing the bound user key.
+	gSESSION_USER_KEY = "__session_user_key__"
+)
+
+// Regenerate creates a new session id for current session and migrates the session data
+// to the new id, then deletes the old session from storage. It's commonly called after
+// the user logs in, for protection against session fixation attack.
+//
+// The new session data is written to storage before the old session is deleted, and the
+// index of the bound user key is also updated.
+func (s *Session) Regenerate() error {
+	s.init()
+	var (
+		oldId = s.id
+		newId = ""
+		data  = s.Map()
+	)
+	if s.idFunc != nil {
+		newId = s.idFunc(s.manager.ttl)
+	}
+	if newId == "" {
+		newId = s.manager.storage.New(s.manager.ttl)
+	}
+	if newId == "" {
+		newId = NewSessionId()
+	}
+	newData := qn_map.NewStrAnyMapFrom(data, true)
+	if len(data) > 0 {
+		if err := s.manager.storage.SetMap(newId, data, s.manager.ttl); err != nil && err != ErrorDisabled {
+			return err
+		}
+	}
+	if err := s.manager.storage.SetSession(newId, newData, s.manager.ttl); err != nil {
+		return err
+	}
+	s.id = newId
+	s.data = newData
+	s.dirty = true
+	s.manager.UpdateSessionTTL(newId, newData)
+	if userKey := qn_conv.String(data[gSESSION_USER_KEY]); userKey != "" {
+		if err := s.manager.addIndex(userKey, newId); err != nil {
+			return err
+		}
+		if err := s.manager.removeIndex(userKey, oldId); err != nil {
+			return err
+		}
+	}
+	return s.manager.Revoke(oldId)
+}
+
+// Bind binds current session to <userKey>, like user id, which adds the session id to the
+// index of <userKey>, so that the sessions of the user can be listed and revoked by the manager.
+//
+// Note that the user key is stored in the session data with a reserved key.
+func (s *Session) Bind(userKey string) error {
+	oldUserKey := s.UserKey()
+	if err := s.Set(gSESSION_USER_KEY, userKey); err != nil {
+		return err
+	}
+	if oldUserKey != "" && oldUserKey != userKey {
+		if err := s.manager.removeIndex(oldUserKey, s.id); err != nil {
+			return err
+		}
+	}
+	return s.manager.addIndex(userKey, s.id)
+}
+
+// UserKey returns the user key bound to current session by Bind.
+// It returns empty string if it's not bound.
+func (s *Session) UserKey() string {
+	return s.GetString(gSESSION_USER_KEY)
+}
+
+// Sessions retrieves and returns the ids of the alive sessions bound to <userKey>.
+// The expired or deleted sessions are removed from the index.
+func (m *Manager) Sessions(userKey string) ([]string, error) {
+	ids, err := m.getIndex(userKey)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		alive = make([]string, 0, len(ids))
+		dead  = make([]string, 0)
+	)
+	for _, id := range ids {
+		if m.sessionData.Contains(id) {
+			alive = append(alive, id)
+			continue
+		}
+		data, err := m.storage.GetSession(id, m.ttl, nil)
+		if err != nil {
+			intlog.Errorf("session restoring failed for id '%s': %v", id, err)
+		}
+		if data != nil {
+			alive = append(alive, id)
+		} else {
+			dead = append(dead, id)
+		}
+	}
+	if len(dead) > 0 {
+		if err = m.removeIndex(userKey, dead...); err != nil {
+			return nil, err
+		}
+	}
+	return alive, nil
+}
+
+// Revoke deletes the session of <id> from both memory and storage.
+// The revoked session is never stored again even it's being used and closed.
+func (m *Manager) Revoke(id string) error {
+	m.revokedIds.Set(id, struct{}{}, m.ttl)
+	m.sessionData.Remove(id)
+	if indexer, ok := m.storage.(StorageIndexer); ok {
+		if err := indexer.Delete(id); err != ErrorDisabled {
+			return err
+		}
+	}
+	if err := m.storage.RemoveAll(id); err != nil && err != ErrorDisabled {
+		return err
+	}
+	return nil
+}
+
+// RevokeAll deletes all the sessions bound to <userKey>, which is commonly used for
+// logging out the user from all devices.
+//
+// Note that for StorageFile and StorageMemory, the sessions cached in memory of other
+// processes cannot be revoked, use redis storage for the multiple processes scenario.
+func (m *Manager) RevokeAll(userKey string) error {
+	ids, err := m.getIndex(userKey)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = m.Revoke(id); err != nil {
+			return err
+		}
+	}
+	return m.removeIndex(userKey, ids...)
+}
+
+// isRevoked checks whether the session of <id> is revoked.
+func (m *Manager) isRevoked(id string) bool {
+	return m.revokedIds.Contains(id)
+}
+
+// addIndex adds session <id> to the index of <userKey>.
+func (m *Manager) addIndex(userKey string, id string) error {
+	if indexer, ok := m.storage.(StorageIndexer); ok {
+		if err := indexer.AddIndex(userKey, id, m.ttl); err != ErrorDisabled {
+			return err
+		}
+	}
+	m.index.GetOrSetFuncLock(userKey, func() interface{} {
+		return qn_set.NewStrSet(true)
+	}).(*qn_set.StrSet).Add(id)
+	return nil
+}
+
+// removeIndex removes session <ids> from the index of <userKey>.
+func (m *Manager) removeIndex(userKey string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if indexer, ok := m.storage.(StorageIndexer); ok {
+		if err := indexer.RemoveIndex(userKey, ids...); err != ErrorDisabled {
+			return err
+		}
+	}
+	if v := m.index.Get(userKey); v != nil {
+		set := v.(*qn_set.StrSet)
+		for _, id := range ids {
+			set.Remove(id)
+		}
+	}
+	return nil
+}
+
+// getIndex retrieves and returns the session ids in the index of <userKey>.
+func (m *Manager) getIndex(userKey string) ([]string, error) {
+	if indexer, ok := m.storage.(StorageIndexer); ok {
+		if ids, err := indexer.GetIndex(userKey); err != ErrorDisabled {
+			return ids, err
+		}
+	}
+	if v := m.index.Get(userKey); v != nil {
+		return v.(*qn_set.StrSet).Slice(), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,537 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/qnsoft/common/internal/json"
+)
+
+// Serializer is the interface for serializing the session data to bytes for storage.
+// Any other format can be used by implementing this interface.
+type Serializer interface {
+	// Serialize encodes session data <data> to bytes.
+	Serialize(data map[string]interface{}) ([]byte, error)
+
+	// Unserialize decodes <content> to session data.
+	Unserialize(content []byte) (map[string]interface{}, error)
+}
+
+var (
+	// SerializerJson serializes the session data with JSON, which is the default serializer.
+	// Note that the typed values lose their types, like struct is decoded as map.
+	SerializerJson Serializer = serializerJson{}
+
+	// SerializerGob serializes the session data with gob, which keeps the types of values.
+	// Note that the custom types should be registered using gob.Register before use.
+	SerializerGob Serializer = serializerGob{}
+
+	// SerializerMsgpack serializes the session data with msgpack, which is more compact than JSON
+	// and keeps the basic types of values. Note that the integers are decoded as int64 (or uint64
+	// if it overflows int64), the time.Time values keep their type, and struct is not supported.
+	SerializerMsgpack Serializer = serializerMsgpack{}
+
+	// DefaultSerializer is the default serializer for session storages.
+	DefaultSerializer = SerializerJson
+)
+
+// serializerJson implements Serializer with JSON.
+type serializerJson struct{}
+
+// serializerGob implements Serializer with gob.
+type serializerGob struct{}
+
+// serializerMsgpack implements Serializer with msgpack.
+type serializerMsgpack struct{}
+
+// msgpackDecoder decodes msgpack values from bytes.
+type msgpackDecoder struct {
+	data []byte // Content to decode.
+	pos  int    // Position of the next byte to read.
+}
+
+const (
+	gMSGPACK_EXT_TIMESTAMP = -1 // Extension type of the timestamp in msgpack specification.
+)
+
+var (
+	// errorMsgpackShort is returned when the msgpack content is truncated.
+	errorMsgpackShort = errors.New("invalid msgpack content: unexpected end")
+)
+
+// Serialize encodes session data <data> to bytes.
+func (serializerJson) Serialize(data map[string]interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+// Unserialize decodes <content> to session data.
+func (serializerJson) Unserialize(content []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Serialize encodes session data <data> to bytes.
+func (serializerGob) Serialize(data map[string]interface{}) ([]byte, error) {
+	buffer := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buffer).Encode(data); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Unserialize decodes <content> to session data.
+func (serializerGob) Unserialize(content []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := gob.NewDecoder(bytes.NewReader(content)).Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Serialize encodes session data <data> to bytes.
+func (serializerMsgpack) Serialize(data map[string]interface{}) ([]byte, error) {
+	buffer := bytes.NewBuffer(nil)
+	if err := msgpackEncode(buffer, data); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Unserialize decodes <content> to session data.
+func (serializerMsgpack) Unserialize(content []byte) (map[string]interface{}, error) {
+	decoder := &msgpackDecoder{data: content}
+	v, err := decoder.decode()
+	if err != nil {
+		return nil, err
+	}
+	if decoder.pos != len(content) {
+		return nil, errors.New("invalid msgpack content: extra data after the map")
+	}
+	switch m := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return m, nil
+	}
+	return nil, errors.New(fmt.Sprintf("invalid msgpack content: map expected but got %T", v))
+}
+
+// msgpackEncode encodes <v> to <buffer> in msgpack format.
+func msgpackEncode(buffer *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buffer.WriteByte(0xc0)
+	case bool:
+		if value {
+			buffer.WriteByte(0xc3)
+		} else {
+			buffer.WriteByte(0xc2)
+		}
+	case int:
+		msgpackEncodeInt(buffer, int64(value))
+	case int8:
+		msgpackEncodeInt(buffer, int64(value))
+	case int16:
+		msgpackEncodeInt(buffer, int64(value))
+	case int32:
+		msgpackEncodeInt(buffer, int64(value))
+	case int64:
+		msgpackEncodeInt(buffer, value)
+	case uint:
+		msgpackEncodeUint(buffer, uint64(value))
+	case uint8:
+		msgpackEncodeUint(buffer, uint64(value))
+	case uint16:
+		msgpackEncodeUint(buffer, uint64(value))
+	case uint32:
+		msgpackEncodeUint(buffer, uint64(value))
+	case uint64:
+		msgpackEncodeUint(buffer, value)
+	case float32:
+		buffer.WriteByte(0xca)
+		binary.Write(buffer, binary.BigEndian, math.Float32bits(value))
+	case float64:
+		buffer.WriteByte(0xcb)
+		binary.Write(buffer, binary.BigEndian, math.Float64bits(value))
+	case string:
+		msgpackEncodeString(buffer, value)
+	case []byte:
+		msgpackEncodeHeader(buffer, len(value), 0, 0xc4, 0xc5, 0xc6)
+		buffer.Write(value)
+	case time.Time:
+		// Timestamp 96 format: the nanoseconds in uint32 and the seconds in int64.
+		buffer.Write([]byte{0xc7, 12, 0xff})
+		binary.Write(buffer, binary.BigEndian, uint32(value.Nanosecond()))
+		binary.Write(buffer, binary.BigEndian, value.Unix())
+	case []interface{}:
+		msgpackEncodeHeader(buffer, len(value), 0x90, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := msgpackEncode(buffer, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		msgpackEncodeHeader(buffer, len(value), 0x80, 0, 0xde, 0xdf)
+		for k, item := range value {
+			msgpackEncodeString(buffer, k)
+			if err := msgpackEncode(buffer, item); err != nil {
+				return err
+			}
+		}
+	default:
+		return msgpackEncodeReflect(buffer, reflect.ValueOf(v))
+	}
+	return nil
+}
+
+// msgpackEncodeReflect encodes the value of named basic type, pointer, slice, array or map.
+func msgpackEncodeReflect(buffer *bytes.Buffer, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			buffer.WriteByte(0xc0)
+			return nil
+		}
+		return msgpackEncode(buffer, rv.Elem().Interface())
+	case reflect.Bool:
+		return msgpackEncode(buffer, rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		msgpackEncodeInt(buffer, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		msgpackEncodeUint(buffer, rv.Uint())
+	case reflect.Float32:
+		return msgpackEncode(buffer, float32(rv.Float()))
+	case reflect.Float64:
+		return msgpackEncode(buffer, rv.Float())
+	case reflect.String:
+		msgpackEncodeString(buffer, rv.String())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return msgpackEncode(buffer, rv.Bytes())
+		}
+		msgpackEncodeHeader(buffer, rv.Len(), 0x90, 0, 0xdc, 0xdd)
+		for i := 0; i < rv.Len(); i++ {
+			if err := msgpackEncode(buffer, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		msgpackEncodeHeader(buffer, rv.Len(), 0x80, 0, 0xde, 0xdf)
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := msgpackEncode(buffer, iter.Key().Interface()); err != nil {
+				return err
+			}
+			if err := msgpackEncode(buffer, iter.Value().Interface()); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New(fmt.Sprintf("unsupported type for msgpack: %s", rv.Type().String()))
+	}
+	return nil
+}
+
+// msgpackEncodeInt encodes signed integer <i> in the most compact format.
+func msgpackEncodeInt(buffer *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0:
+		msgpackEncodeUint(buffer, uint64(i))
+	case i >= -32:
+		buffer.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8:
+		buffer.Write([]byte{0xd0, byte(int8(i))})
+	case i >= math.MinInt16:
+		buffer.WriteByte(0xd1)
+		binary.Write(buffer, binary.BigEndian, int16(i))
+	case i >= math.MinInt32:
+		buffer.WriteByte(0xd2)
+		binary.Write(buffer, binary.BigEndian, int32(i))
+	default:
+		buffer.WriteByte(0xd3)
+		binary.Write(buffer, binary.BigEndian, i)
+	}
+}
+
+// msgpackEncodeUint encodes unsigned integer <u> in the most compact format.
+func msgpackEncodeUint(buffer *bytes.Buffer, u uint64) {
+	switch {
+	case u <= math.MaxInt8:
+		buffer.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		buffer.Write([]byte{0xcc, byte(u)})
+	case u <= math.MaxUint16:
+		buffer.WriteByte(0xcd)
+		binary.Write(buffer, binary.BigEndian, uint16(u))
+	case u <= math.MaxUint32:
+		buffer.WriteByte(0xce)
+		binary.Write(buffer, binary.BigEndian, uint32(u))
+	default:
+		buffer.WriteByte(0xcf)
+		binary.Write(buffer, binary.BigEndian, u)
+	}
+}
+
+// msgpackEncodeString encodes string <s>.
+func msgpackEncodeString(buffer *bytes.Buffer, s string) {
+	msgpackEncodeHeader(buffer, len(s), 0xa0, 0xd9, 0xda, 0xdb)
+	buffer.WriteString(s)
+}
+
+// msgpackEncodeHeader encodes the length <n> of string, binary, array or map, using the fix format
+// <fix> (0 if not available) for small length, or else the 8, 16 or 32 bits length format.
+func msgpackEncodeHeader(buffer *bytes.Buffer, n int, fix, format8, format16, format32 byte) {
+	switch {
+	case fix == 0xa0 && n < 32, fix != 0 && fix != 0xa0 && n < 16:
+		buffer.WriteByte(fix | byte(n))
+	case format8 != 0 && n <= math.MaxUint8:
+		buffer.Write([]byte{format8, byte(n)})
+	case n <= math.MaxUint16:
+		buffer.WriteByte(format16)
+		binary.Write(buffer, binary.BigEndian, uint16(n))
+	default:
+		buffer.WriteByte(format32)
+		binary.Write(buffer, binary.BigEndian, uint32(n))
+	}
+}
+
+// decode decodes and returns the next value.
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b >= 0x80 && b <= 0x8f:
+		return d.decodeMap(int(b & 0x0f))
+	case b >= 0x90 && b <= 0x9f:
+		return d.decodeArray(int(b & 0x0f))
+	case b >= 0xa0 && b <= 0xbf:
+		return d.decodeString(int(b & 0x1f))
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLength(b - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		content, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), content...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLength(b - 0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+	case 0xca:
+		content, err := d.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(content)), nil
+	case 0xcb:
+		content, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(content)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		content, err := d.read(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		u := msgpackUint(content)
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		content, err := d.read(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign extension from the highest bit of the content.
+		shift := uint(64 - size*8)
+		return int64(msgpackUint(content)<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLength(b - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(n)
+	case 0xdc, 0xdd:
+		n, err := d.readLength(b - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n)
+	case 0xde, 0xdf:
+		n, err := d.readLength(b - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n)
+	}
+	return nil, errors.New(fmt.Sprintf("invalid msgpack content: unknown format 0x%x", b))
+}
+
+// decodeString decodes the string of length <n>.
+func (d *msgpackDecoder) decodeString(n int) (interface{}, error) {
+	content, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(content), nil
+}
+
+// decodeArray decodes the array of <n> elements as []interface{}.
+func (d *msgpackDecoder) decodeArray(n int) (interface{}, error) {
+	// Each element takes one byte at least.
+	if n > len(d.data)-d.pos {
+		return nil, errorMsgpackShort
+	}
+	array := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		array[i] = v
+	}
+	return array, nil
+}
+
+// decodeMap decodes the map of <n> pairs as map[string]interface{} if all keys are string,
+// or else map[interface{}]interface{}.
+func (d *msgpackDecoder) decodeMap(n int) (interface{}, error) {
+	// Each pair takes two bytes at least.
+	if n > (len(d.data)-d.pos)/2 {
+		return nil, errorMsgpackShort
+	}
+	var (
+		strMap = make(map[string]interface{}, n)
+		anyMap map[interface{}]interface{}
+	)
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := k.(string); ok && anyMap == nil {
+			strMap[s] = v
+			continue
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, errors.New(fmt.Sprintf("invalid msgpack content: unsupported map key type %T", k))
+		}
+		if anyMap == nil {
+			anyMap = make(map[interface{}]interface{}, n)
+			for s, item := range strMap {
+				anyMap[s] = item
+			}
+		}
+		anyMap[k] = v
+	}
+	if anyMap != nil {
+		return anyMap, nil
+	}
+	return strMap, nil
+}
+
+// decodeExt decodes the extension value of <n> bytes data, only the timestamp is supported.
+func (d *msgpackDecoder) decodeExt(n int) (interface{}, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	content, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(b) != gMSGPACK_EXT_TIMESTAMP {
+		return nil, errors.New(fmt.Sprintf("invalid msgpack content: unsupported extension type %d", int8(b)))
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(content)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(content)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(content[4:])), int64(binary.BigEndian.Uint32(content))), nil
+	}
+	return nil, errors.New(fmt.Sprintf("invalid msgpack content: invalid timestamp length %d", n))
+}
+
+// readLength reads the length in 8, 16 or 32 bits, which is specified by <exp> as 0, 1 or 2.
+func (d *msgpackDecoder) readLength(exp byte) (int, error) {
+	content, err := d.read(1 << exp)
+	if err != nil {
+		return 0, err
+	}
+	return int(msgpackUint(content)), nil
+}
+
+// readByte reads the next byte.
+func (d *msgpackDecoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errorMsgpackShort
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+// read reads the next <n> bytes, the returned bytes share the content of decoder.
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errorMsgpackShort
+	}
+	content := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return content, nil
+}
+
+// msgpackUint converts the big endian bytes <content> to unsigned integer.
+func msgpackUint(content []byte) uint64 {
+	var u uint64
+	for _, b := range content {
+		u = u<<8 | uint64(b)
+	}
+	return u
+}
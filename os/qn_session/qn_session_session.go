@@ -38,6 +38,10 @@ func (s *Session) init() {
 	if s.start {
 		return
 	}
+	// The revoked session id cannot be used any more.
+	if s.id != "" && s.manager.isRevoked(s.id) {
+		s.id = ""
+	}
 	if s.id != "" {
 		var err error
 		// Retrieve memory session data from manager.
@@ -80,7 +84,7 @@ func (s *Session) init() {
 //
 // NOTE that this function must be called ever after a session request done.
 func (s *Session) Close() {
-	if s.start && s.id != "" {
+	if s.start && s.id != "" && !s.manager.isRevoked(s.id) {
 		size := s.data.Size()
 		if s.manager.storage != nil {
 			if s.dirty {
@@ -99,6 +103,26 @@ func (s *Session) Close() {
 	}
 }
 
+// Flush encodes the dirty session data to the session id, if the storage stores the session
+// data in the session id, like StorageCookie. It does nothing for other storages.
+//
+// NOTE that it should be called before the session id is sent to client.
+func (s *Session) Flush() error {
+	if !s.start || !s.dirty {
+		return nil
+	}
+	encoder, ok := s.manager.storage.(StorageEncoder)
+	if !ok {
+		return nil
+	}
+	id, err := encoder.Encode(s.data, s.manager.ttl)
+	if err != nil {
+		return err
+	}
+	s.id = id
+	return nil
+}
+
 // Set sets key-value pair to this session.
 func (s *Session) Set(key string, value interface{}) error {
 	s.init()
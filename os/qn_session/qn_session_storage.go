@@ -60,3 +60,31 @@ type Storage interface {
 	// This function is called ever after session, which is not dirty, is closed.
 	UpdateTTL(id string, ttl time.Duration) error
 }
+
+// StorageIndexer is the optional interface for session storage, which supports deleting
+// sessions and indexing the session ids by user key. The session manager uses its own
+// in-memory index if the storage does not implement this interface, or its functions
+// return ErrorDisabled.
+type StorageIndexer interface {
+	// Delete deletes the session of given session id completely from storage.
+	Delete(id string) error
+
+	// AddIndex adds session id <id> to the index of <userKey>.
+	// The parameter <ttl> specifies the TTL for the index.
+	AddIndex(userKey string, id string, ttl time.Duration) error
+
+	// RemoveIndex removes session ids <ids> from the index of <userKey>.
+	RemoveIndex(userKey string, ids ...string) error
+
+	// GetIndex retrieves and returns the session ids in the index of <userKey>.
+	GetIndex(userKey string) ([]string, error)
+}
+
+// StorageEncoder is the optional interface for session storage, which stores the session data
+// in the session id itself, like StorageCookie. The session id is re-encoded from the session
+// data by Session.Flush if the session is dirty.
+type StorageEncoder interface {
+	// Encode encodes <data> and returns it as the new session id.
+	// The parameter <ttl> specifies the TTL for the session id.
+	Encode(data *qn_map.StrAnyMap, ttl time.Duration) (id string, err error)
+}
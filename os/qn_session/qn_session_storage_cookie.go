@@ -0,0 +1,304 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_map"
+	"github.com/qnsoft/common/encoding/qn_binary"
+	"github.com/qnsoft/common/os/qn_time"
+)
+
+// StorageCookie implements the Session Storage interface with client side cookie.
+//
+// It stores the whole session data in the session id, which is the cookie value, so that no
+// data is kept at server side. The session id is authenticated with HMAC-SHA256, and it's
+// also encrypted with AES-GCM if crypto feature is enabled.
+//
+// The first key is used for signing and encrypting, and all the keys are used for verifying
+// and decrypting, which allows key rotation: put the new key at first and keep the old keys
+// until all the old sessions expire.
+//
+// Note that the expiry of the session does not slide on access: the TTL is counted from
+// the time the session id is encoded, which is renewed only if the session data is modified.
+type StorageCookie struct {
+	keys          []storageCookieKey
+	cryptoEnabled bool
+	maxSize       int
+	serializer    Serializer
+}
+
+// storageCookieKey is the derived keys from a secret key.
+type storageCookieKey struct {
+	signKey  []byte      // Key for HMAC-SHA256.
+	cryptKey cipher.AEAD // AES-GCM cipher.
+}
+
+const (
+	gSTORAGE_COOKIE_FLAG_PLAIN     = 0 // Flag byte of the plain session data.
+	gSTORAGE_COOKIE_FLAG_ENCRYPTED = 1 // Flag byte of the encrypted session data.
+)
+
+var (
+	// DefaultStorageCookieMaxSize is the max size of the session id in bytes,
+	// as the browsers commonly limit the cookie size to 4096 bytes including its name.
+	DefaultStorageCookieMaxSize = 4000
+
+	// ErrorStorageCookieInvalid is returned when the session id is malformed or tampered.
+	ErrorStorageCookieInvalid = errors.New("invalid session cookie")
+)
+
+// NewStorageCookie creates and returns a cookie storage object for session.
+// The parameter <key> is the secret key for signing and encrypting, and the optional
+// parameter <oldKeys> are the old keys for verifying and decrypting the old sessions.
+//
+// Note that the session expires in TTL after it's modified lastly, no matter how it's accessed,
+// as there's no server side state for renewing it. Set the session data, like a timestamp,
+// for renewing the session if sliding expiry is needed.
+func NewStorageCookie(key []byte, oldKeys ...[]byte) *StorageCookie {
+	s := &StorageCookie{
+		maxSize:    DefaultStorageCookieMaxSize,
+		serializer: DefaultSerializer,
+	}
+	s.SetKeys(append([][]byte{key}, oldKeys...)...)
+	return s
+}
+
+// SetKeys sets the secret keys for session storage, which is used for key rotation.
+// The first key is used for signing and encrypting.
+func (s *StorageCookie) SetKeys(keys ...[]byte) {
+	derivedKeys := make([]storageCookieKey, 0, len(keys))
+	for _, key := range keys {
+		if len(key) == 0 {
+			panic("secret key for cookie storage cannot be empty")
+		}
+		var (
+			signKey  = sha256.Sum256(append([]byte("sign:"), key...))
+			cryptKey = sha256.Sum256(append([]byte("crypt:"), key...))
+		)
+		block, err := aes.NewCipher(cryptKey[:])
+		if err != nil {
+			panic(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+		derivedKeys = append(derivedKeys, storageCookieKey{
+			signKey:  signKey[:],
+			cryptKey: aead,
+		})
+	}
+	if len(derivedKeys) == 0 {
+		panic("secret key for cookie storage cannot be empty")
+	}
+	s.keys = derivedKeys
+}
+
+// SetCryptoEnabled enables/disables the crypto feature for session storage.
+// Note that the session not encrypted is treated as invalid if crypto feature is enabled,
+// and vice versa.
+func (s *StorageCookie) SetCryptoEnabled(enabled bool) {
+	s.cryptoEnabled = enabled
+}
+
+// SetMaxSize sets the max size in bytes of the session id for session storage.
+func (s *StorageCookie) SetMaxSize(size int) {
+	s.maxSize = size
+}
+
+// SetSerializer sets the serializer for session storage, which is DefaultSerializer in default.
+func (s *StorageCookie) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+}
+
+// New creates a session id.
+// This function can be used for custom session creation.
+func (s *StorageCookie) New(ttl time.Duration) (id string) {
+	return ""
+}
+
+// Get retrieves session value with given key.
+// It returns nil if the key does not exist in the session.
+func (s *StorageCookie) Get(id string, key string) interface{} {
+	return nil
+}
+
+// GetMap retrieves all key-value pairs as map from storage.
+func (s *StorageCookie) GetMap(id string) map[string]interface{} {
+	return nil
+}
+
+// GetSize retrieves the size of key-value pairs from storage.
+func (s *StorageCookie) GetSize(id string) int {
+	return -1
+}
+
+// Set sets key-value session pair to the storage.
+// The parameter <ttl> specifies the TTL for the session id (not for the key-value pair).
+func (s *StorageCookie) Set(id string, key string, value interface{}, ttl time.Duration) error {
+	return ErrorDisabled
+}
+
+// SetMap batch sets key-value session pairs with map to the storage.
+// The parameter <ttl> specifies the TTL for the session id(not for the key-value pair).
+func (s *StorageCookie) SetMap(id string, data map[string]interface{}, ttl time.Duration) error {
+	return ErrorDisabled
+}
+
+// Remove deletes key with its value from storage.
+func (s *StorageCookie) Remove(id string, key string) error {
+	return ErrorDisabled
+}
+
+// RemoveAll deletes all key-value pairs from storage.
+func (s *StorageCookie) RemoveAll(id string) error {
+	return ErrorDisabled
+}
+
+// GetSession returns the session data as *qn_map.StrAnyMap for given session id from storage.
+//
+// The parameter <ttl> specifies the TTL for this session, and it returns nil if the TTL is exceeded.
+// The parameter <data> is the current old session data stored in memory,
+// and for some storage it might be nil if memory storage is disabled.
+//
+// This function is called ever when session starts.
+func (s *StorageCookie) GetSession(id string, ttl time.Duration, data *qn_map.StrAnyMap) (*qn_map.StrAnyMap, error) {
+	if data != nil {
+		return data, nil
+	}
+	m, err := s.Decode(id, ttl)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return qn_map.NewStrAnyMapFrom(m, true), nil
+}
+
+// SetSession updates the data map for specified session id.
+// It does nothing as the session data is stored in the session id by Encode.
+func (s *StorageCookie) SetSession(id string, data *qn_map.StrAnyMap, ttl time.Duration) error {
+	return nil
+}
+
+// UpdateTTL updates the TTL for specified session id.
+// It does nothing as the expiry does not slide, the TTL is renewed only if the session
+// is dirty and encoded again.
+func (s *StorageCookie) UpdateTTL(id string, ttl time.Duration) error {
+	return nil
+}
+
+// Encode encodes <data> and returns it as the new session id,
+// which is signed and optionally encrypted with the first key.
+// It returns error if the size of the session id exceeds the max size.
+func (s *StorageCookie) Encode(data *qn_map.StrAnyMap, ttl time.Duration) (id string, err error) {
+	content, err := s.serializer.Serialize(data.Map())
+	if err != nil {
+		return "", err
+	}
+	var (
+		key     = s.keys[0]
+		payload = make([]byte, 0, 1+8+len(content))
+	)
+	// The issued timestamp is used for expiration checking.
+	content = append(qn_binary.EncodeInt64(qn_time.TimestampMilli()), content...)
+	if s.cryptoEnabled {
+		nonce := make([]byte, key.cryptKey.NonceSize())
+		if _, err = rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = append(payload, gSTORAGE_COOKIE_FLAG_ENCRYPTED)
+		payload = append(payload, nonce...)
+		payload = key.cryptKey.Seal(payload, nonce, content, nil)
+	} else {
+		payload = append(payload, gSTORAGE_COOKIE_FLAG_PLAIN)
+		payload = append(payload, content...)
+	}
+	id = base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(key.sign(payload))
+	if s.maxSize > 0 && len(id) > s.maxSize {
+		return "", errors.New(fmt.Sprintf(
+			"session cookie size %d exceeds the max size %d", len(id), s.maxSize,
+		))
+	}
+	return id, nil
+}
+
+// Decode verifies and decodes the session id <id> to session data map.
+// It returns nil if the session is expired, or ErrorStorageCookieInvalid if it's invalid.
+func (s *StorageCookie) Decode(id string, ttl time.Duration) (map[string]interface{}, error) {
+	array := strings.Split(id, ".")
+	if len(array) != 2 {
+		return nil, ErrorStorageCookieInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(array[0])
+	if err != nil || len(payload) == 0 {
+		return nil, ErrorStorageCookieInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(array[1])
+	if err != nil {
+		return nil, ErrorStorageCookieInvalid
+	}
+	var key *storageCookieKey
+	for i := range s.keys {
+		if hmac.Equal(s.keys[i].sign(payload), signature) {
+			key = &s.keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return nil, ErrorStorageCookieInvalid
+	}
+	var content []byte
+	switch payload[0] {
+	case gSTORAGE_COOKIE_FLAG_PLAIN:
+		if s.cryptoEnabled {
+			return nil, ErrorStorageCookieInvalid
+		}
+		content = payload[1:]
+
+	case gSTORAGE_COOKIE_FLAG_ENCRYPTED:
+		if !s.cryptoEnabled {
+			return nil, ErrorStorageCookieInvalid
+		}
+		nonceSize := key.cryptKey.NonceSize()
+		if len(payload) < 1+nonceSize {
+			return nil, ErrorStorageCookieInvalid
+		}
+		content, err = key.cryptKey.Open(nil, payload[1:1+nonceSize], payload[1+nonceSize:], nil)
+		if err != nil {
+			return nil, ErrorStorageCookieInvalid
+		}
+
+	default:
+		return nil, ErrorStorageCookieInvalid
+	}
+	if len(content) < 8 {
+		return nil, ErrorStorageCookieInvalid
+	}
+	timestampMilli := qn_binary.DecodeToInt64(content[:8])
+	if timestampMilli+ttl.Nanoseconds()/1e6 < qn_time.TimestampMilli() {
+		return nil, nil
+	}
+	return s.serializer.Unserialize(content[8:])
+}
+
+// sign returns the HMAC-SHA256 signature of <payload>.
+func (k storageCookieKey) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, k.signKey)
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,305 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_map"
+	qn_conv "github.com/qnsoft/common/util/qn_conv"
+)
+
+// StorageCrypto is a Storage decorator, which transparently encrypts the session data with
+// AES-GCM before it's passed to the underlying storage, and decrypts it after it's retrieved.
+//
+// The ciphertext is prefixed with the key id, so the keys can be rotated: set the new key as
+// the current key using SetKey and keep the old keys using AddKey until the old sessions expire.
+type StorageCrypto struct {
+	mu         sync.RWMutex
+	storage    Storage                // Underlying storage.
+	keyId      string                 // Current key id for encrypting.
+	keys       map[string]cipher.AEAD // Key id to cipher for decrypting.
+	serializer Serializer             // Serializer for session data before encrypting.
+}
+
+const (
+	// gSTORAGE_CRYPTO_DATA_KEY is the key storing the encrypted session data
+	// in the data map passed to the underlying storage.
+	gSTORAGE_CRYPTO_DATA_KEY = "__session_crypto__"
+)
+
+// NewStorageCrypto creates and returns a storage which encrypts the session data of <storage>
+// with AES key <key> identified by <keyId>. The length of <key> should be 16, 24 or 32.
+//
+// Note that it cannot decorate the StorageEncoder storages like StorageCookie, which store the
+// session data in the session id, use their own crypto feature like StorageCookie.SetCryptoEnabled
+// instead. It panics if <storage> is StorageEncoder.
+func NewStorageCrypto(storage Storage, keyId string, key []byte) *StorageCrypto {
+	if storage == nil {
+		panic("storage for crypto storage cannot be empty")
+	}
+	if _, ok := storage.(StorageEncoder); ok {
+		panic("crypto storage cannot decorate storage storing session data in session id")
+	}
+	s := &StorageCrypto{
+		storage:    storage,
+		keys:       make(map[string]cipher.AEAD),
+		serializer: DefaultSerializer,
+	}
+	s.SetKey(keyId, key)
+	return s
+}
+
+// SetKey adds AES key <key> identified by <keyId> and uses it as the current key for encrypting.
+func (s *StorageCrypto) SetKey(keyId string, key []byte) {
+	s.AddKey(keyId, key)
+	s.mu.Lock()
+	s.keyId = keyId
+	s.mu.Unlock()
+}
+
+// AddKey adds AES key <key> identified by <keyId> for decrypting, which is commonly the old key.
+// Note that the key id cannot contain char ':'.
+func (s *StorageCrypto) AddKey(keyId string, key []byte) {
+	if keyId == "" || strings.Contains(keyId, ":") {
+		panic(fmt.Sprintf(`invalid key id "%s" for crypto storage`, keyId))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	s.keys[keyId] = aead
+	s.mu.Unlock()
+}
+
+// RemoveKey removes the key of <keyId>, the sessions encrypted with the key cannot be decrypted
+// any more. It cannot remove the current key.
+func (s *StorageCrypto) RemoveKey(keyId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if keyId != s.keyId {
+		delete(s.keys, keyId)
+	}
+}
+
+// SetSerializer sets the serializer for session data, which is DefaultSerializer in default.
+func (s *StorageCrypto) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+}
+
+// New creates a session id.
+// This function can be used for custom session creation.
+func (s *StorageCrypto) New(ttl time.Duration) (id string) {
+	return s.storage.New(ttl)
+}
+
+// Get retrieves session value with given key.
+// It returns nil if the key does not exist in the session.
+func (s *StorageCrypto) Get(id string, key string) interface{} {
+	v := s.storage.Get(id, key)
+	if v == nil {
+		return nil
+	}
+	m, err := s.decrypt(qn_conv.String(v))
+	if err != nil {
+		return nil
+	}
+	return m[key]
+}
+
+// GetMap retrieves all key-value pairs as map from storage.
+func (s *StorageCrypto) GetMap(id string) map[string]interface{} {
+	data := s.storage.GetMap(id)
+	if data == nil {
+		return nil
+	}
+	m := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if v == nil {
+			continue
+		}
+		if decrypted, err := s.decrypt(qn_conv.String(v)); err == nil {
+			m[k] = decrypted[k]
+		}
+	}
+	return m
+}
+
+// GetSize retrieves the size of key-value pairs from storage.
+func (s *StorageCrypto) GetSize(id string) int {
+	return s.storage.GetSize(id)
+}
+
+// Set sets key-value session pair to the storage.
+// The parameter <ttl> specifies the TTL for the session id (not for the key-value pair).
+func (s *StorageCrypto) Set(id string, key string, value interface{}, ttl time.Duration) error {
+	encrypted, err := s.encrypt(map[string]interface{}{key: value})
+	if err != nil {
+		return err
+	}
+	return s.storage.Set(id, key, encrypted, ttl)
+}
+
+// SetMap batch sets key-value session pairs with map to the storage.
+// The parameter <ttl> specifies the TTL for the session id(not for the key-value pair).
+func (s *StorageCrypto) SetMap(id string, data map[string]interface{}, ttl time.Duration) error {
+	m := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		encrypted, err := s.encrypt(map[string]interface{}{k: v})
+		if err != nil {
+			return err
+		}
+		m[k] = encrypted
+	}
+	return s.storage.SetMap(id, m, ttl)
+}
+
+// Remove deletes key with its value from storage.
+func (s *StorageCrypto) Remove(id string, key string) error {
+	return s.storage.Remove(id, key)
+}
+
+// RemoveAll deletes all key-value pairs from storage.
+func (s *StorageCrypto) RemoveAll(id string) error {
+	return s.storage.RemoveAll(id)
+}
+
+// GetSession returns the session data as *qn_map.StrAnyMap for given session id from storage.
+//
+// The parameter <ttl> specifies the TTL for this session, and it returns nil if the TTL is exceeded.
+// The parameter <data> is the current old session data stored in memory,
+// and for some storage it might be nil if memory storage is disabled.
+//
+// This function is called ever when session starts.
+func (s *StorageCrypto) GetSession(id string, ttl time.Duration, data *qn_map.StrAnyMap) (*qn_map.StrAnyMap, error) {
+	r, err := s.storage.GetSession(id, ttl, data)
+	if err != nil || r == nil {
+		return r, err
+	}
+	v := r.Get(gSTORAGE_CRYPTO_DATA_KEY)
+	if v == nil {
+		return r, nil
+	}
+	m, err := s.decrypt(qn_conv.String(v))
+	if err != nil {
+		return nil, err
+	}
+	r.Replace(m)
+	return r, nil
+}
+
+// SetSession updates the data map for specified session id.
+// This function is called ever after session, which is changed dirty, is closed.
+// It passes the encrypted data map to the underlying storage.
+func (s *StorageCrypto) SetSession(id string, data *qn_map.StrAnyMap, ttl time.Duration) error {
+	encrypted, err := s.encrypt(data.Map())
+	if err != nil {
+		return err
+	}
+	return s.storage.SetSession(id, qn_map.NewStrAnyMapFrom(map[string]interface{}{
+		gSTORAGE_CRYPTO_DATA_KEY: encrypted,
+	}, true), ttl)
+}
+
+// UpdateTTL updates the TTL for specified session id.
+// This function is called ever after session, which is not dirty, is closed.
+func (s *StorageCrypto) UpdateTTL(id string, ttl time.Duration) error {
+	return s.storage.UpdateTTL(id, ttl)
+}
+
+// Delete deletes the session of given session id completely from storage.
+func (s *StorageCrypto) Delete(id string) error {
+	if indexer, ok := s.storage.(StorageIndexer); ok {
+		return indexer.Delete(id)
+	}
+	return ErrorDisabled
+}
+
+// AddIndex adds session id <id> to the index of <userKey>.
+// The parameter <ttl> specifies the TTL for the index.
+func (s *StorageCrypto) AddIndex(userKey string, id string, ttl time.Duration) error {
+	if indexer, ok := s.storage.(StorageIndexer); ok {
+		return indexer.AddIndex(userKey, id, ttl)
+	}
+	return ErrorDisabled
+}
+
+// RemoveIndex removes session ids <ids> from the index of <userKey>.
+func (s *StorageCrypto) RemoveIndex(userKey string, ids ...string) error {
+	if indexer, ok := s.storage.(StorageIndexer); ok {
+		return indexer.RemoveIndex(userKey, ids...)
+	}
+	return ErrorDisabled
+}
+
+// GetIndex retrieves and returns the session ids in the index of <userKey>.
+func (s *StorageCrypto) GetIndex(userKey string) ([]string, error) {
+	if indexer, ok := s.storage.(StorageIndexer); ok {
+		return indexer.GetIndex(userKey)
+	}
+	return nil, ErrorDisabled
+}
+
+// encrypt serializes and encrypts <data> with the current key,
+// and returns the ciphertext in format: <key id>:<base64 of nonce and sealed data>.
+func (s *StorageCrypto) encrypt(data map[string]interface{}) (string, error) {
+	content, err := s.serializer.Serialize(data)
+	if err != nil {
+		return "", err
+	}
+	s.mu.RLock()
+	var (
+		keyId = s.keyId
+		aead  = s.keys[keyId]
+	)
+	s.mu.RUnlock()
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, content, []byte(keyId))
+	return keyId + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt decrypts and unserializes the ciphertext <value> with the key of its key id.
+func (s *StorageCrypto) decrypt(value string) (map[string]interface{}, error) {
+	array := strings.SplitN(value, ":", 2)
+	if len(array) != 2 {
+		return nil, errors.New("invalid encrypted session data")
+	}
+	s.mu.RLock()
+	aead, ok := s.keys[array[0]]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, errors.New(fmt.Sprintf(`key "%s" not found for encrypted session data`, array[0]))
+	}
+	sealed, err := base64.StdEncoding.DecodeString(array[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted session data")
+	}
+	content, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(array[0]))
+	if err != nil {
+		return nil, err
+	}
+	return s.serializer.Unserialize(content)
+}
@@ -0,0 +1,745 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/qnsoft/common/container/qn_map"
+	"github.com/qnsoft/common/internal/intlog"
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/os/qn_timer"
+)
+
+// StorageEmbedded implements the Session Storage interface with a single embedded data file,
+// which is suitable for single-node deployment with large amount of sessions.
+//
+// The data file is an append-only log of checksummed records, and the index of session ids
+// and their expiration is kept in memory. The expired sessions are purged using the
+// expiration index, and the data file is compacted when most of it is obsolete.
+//
+// A partially written record at the end of the data file caused by crash is detected by
+// checksum and truncated when the file is opened, so the data file is always consistent.
+// A broken record followed by valid records means the data file is corrupted, which fails
+// opening the data file instead of discarding the following records. Enable sync feature
+// to make sure that each write is flushed to disk, or else the last writes might be lost
+// after crash.
+//
+// The data file can be opened by only one storage at the same time, which is guaranteed by an
+// exclusive lock on the lock file "<path>.lock", even across processes like the parent and
+// child processes during graceful restart. Note that the lock is not supported on platforms
+// other than windows, linux, darwin and BSDs.
+type StorageEmbedded struct {
+	mu          sync.RWMutex
+	path        string                          // Data file path.
+	file        *os.File                        // Data file.
+	lockFile    *os.File                        // Lock file, which is locked exclusively until the storage is closed.
+	size        int64                           // Data file size, which is also the offset for next record.
+	garbage     int64                           // Size of the obsolete records in data file.
+	items       map[string]*storageEmbeddedItem // Session id to its record.
+	expiry      storageEmbeddedHeap             // Expiration index of session ids.
+	updatingIds map[string]struct{}             // Session ids whose TTL is updated but not written.
+	serializer  Serializer                      // Serializer for session data.
+	syncEnabled bool                            // Whether to sync the data file after each write.
+	timer       *qn_timer.Entry                 // Timer for TTL updating, purging and compaction.
+}
+
+// storageEmbeddedItem is the index item of a session record in data file.
+type storageEmbeddedItem struct {
+	offset   int64 // Offset of the record.
+	size     int64 // Size of the record.
+	expireAt int64 // Expiration timestamp in milliseconds.
+}
+
+// storageEmbeddedExpiry is the item of the expiration index.
+type storageEmbeddedExpiry struct {
+	id       string
+	expireAt int64
+}
+
+// storageEmbeddedHeap is the min heap of the expiration index, which might contain outdated
+// items after the TTL of the session is updated, which are ignored when they are popped.
+type storageEmbeddedHeap []storageEmbeddedExpiry
+
+const (
+	gSTORAGE_EMBEDDED_MAGIC           = "QNSESS01"       // Header of the data file.
+	gSTORAGE_EMBEDDED_HEADER_SIZE     = 8                // Size of record header: checksum(4) + body length(4).
+	gSTORAGE_EMBEDDED_BODY_FIXED_SIZE = 11               // Size of body fixed fields: op(1) + expireAt(8) + id length(2).
+	gSTORAGE_EMBEDDED_MAX_BODY_SIZE   = 64 * 1024 * 1024 // Max size of record body, for detecting corrupted records.
+	gSTORAGE_EMBEDDED_OP_SET          = 1                // Record storing session data.
+	gSTORAGE_EMBEDDED_OP_TOUCH        = 2                // Record updating session TTL.
+	gSTORAGE_EMBEDDED_OP_DELETE       = 3                // Record deleting session.
+)
+
+var (
+	DefaultStorageEmbeddedPath            = qn_file.TempDir("gsessions.db")
+	DefaultStorageEmbeddedSyncEnabled     = false
+	DefaultStorageEmbeddedLoopInterval    = 10 * time.Second
+	DefaultStorageEmbeddedCompactMinSize  = int64(4 * 1024 * 1024)
+	DefaultStorageEmbeddedCompactMinRatio = 0.5
+
+	// errorStorageEmbeddedLocked is returned when the data file is locked by another storage.
+	errorStorageEmbeddedLocked = errors.New("data file is being used by another storage, which might be in another process")
+)
+
+// NewStorageEmbedded creates and returns an embedded storage object for session.
+// The optional parameter <path> specifies the data file path, which is created if it does not exist.
+// It panics if the data file is being used by another storage, see StorageEmbedded.
+func NewStorageEmbedded(path ...string) *StorageEmbedded {
+	storagePath := DefaultStorageEmbeddedPath
+	if len(path) > 0 && path[0] != "" {
+		storagePath = path[0]
+	}
+	if err := qn_file.Mkdir(qn_file.Dir(storagePath)); err != nil {
+		panic(fmt.Sprintf("mkdir '%s' failed: %v", qn_file.Dir(storagePath), err))
+	}
+	s := &StorageEmbedded{
+		path:        storagePath,
+		items:       make(map[string]*storageEmbeddedItem),
+		updatingIds: make(map[string]struct{}),
+		serializer:  DefaultSerializer,
+		syncEnabled: DefaultStorageEmbeddedSyncEnabled,
+	}
+	if err := s.lock(); err != nil {
+		panic(fmt.Sprintf("lock '%s' failed: %v", storagePath, err))
+	}
+	if err := s.open(); err != nil {
+		s.lockFile.Close()
+		panic(fmt.Sprintf("open '%s' failed: %v", storagePath, err))
+	}
+	// Batch updates the TTL, purges the expired sessions and compacts the data file timely.
+	s.timer = qn_timer.AddSingleton(DefaultStorageEmbeddedLoopInterval, func() {
+		if err := s.doLoop(); err != nil {
+			intlog.Error(err)
+		}
+	})
+	return s
+}
+
+// SetSerializer sets the serializer for session storage, which is DefaultSerializer in default.
+func (s *StorageEmbedded) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+}
+
+// SetSyncEnabled enables/disables syncing the data file to disk after each write.
+func (s *StorageEmbedded) SetSyncEnabled(enabled bool) {
+	s.mu.Lock()
+	s.syncEnabled = enabled
+	s.mu.Unlock()
+}
+
+// New creates a session id.
+// This function can be used for custom session creation.
+func (s *StorageEmbedded) New(ttl time.Duration) (id string) {
+	return ""
+}
+
+// Get retrieves session value with given key.
+// It returns nil if the key does not exist in the session.
+func (s *StorageEmbedded) Get(id string, key string) interface{} {
+	return nil
+}
+
+// GetMap retrieves all key-value pairs as map from storage.
+func (s *StorageEmbedded) GetMap(id string) map[string]interface{} {
+	return nil
+}
+
+// GetSize retrieves the size of key-value pairs from storage.
+func (s *StorageEmbedded) GetSize(id string) int {
+	return -1
+}
+
+// Set sets key-value session pair to the storage.
+// The parameter <ttl> specifies the TTL for the session id (not for the key-value pair).
+func (s *StorageEmbedded) Set(id string, key string, value interface{}, ttl time.Duration) error {
+	return ErrorDisabled
+}
+
+// SetMap batch sets key-value session pairs with map to the storage.
+// The parameter <ttl> specifies the TTL for the session id(not for the key-value pair).
+func (s *StorageEmbedded) SetMap(id string, data map[string]interface{}, ttl time.Duration) error {
+	return ErrorDisabled
+}
+
+// Remove deletes key with its value from storage.
+func (s *StorageEmbedded) Remove(id string, key string) error {
+	return ErrorDisabled
+}
+
+// RemoveAll deletes all key-value pairs from storage.
+func (s *StorageEmbedded) RemoveAll(id string) error {
+	return ErrorDisabled
+}
+
+// GetSession returns the session data as *qn_map.StrAnyMap for given session id from storage.
+//
+// The parameter <ttl> specifies the TTL for this session, and it returns nil if the TTL is exceeded.
+// The parameter <data> is the current old session data stored in memory,
+// and for some storage it might be nil if memory storage is disabled.
+//
+// This function is called ever when session starts.
+func (s *StorageEmbedded) GetSession(id string, ttl time.Duration, data *qn_map.StrAnyMap) (*qn_map.StrAnyMap, error) {
+	if data != nil {
+		return data, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.file == nil {
+		return nil, errors.New("storage is closed")
+	}
+	item, ok := s.items[id]
+	if !ok || item.expireAt < qn_time.TimestampMilli() {
+		return nil, nil
+	}
+	buffer := make([]byte, item.size)
+	if _, err := s.file.ReadAt(buffer, item.offset); err != nil {
+		return nil, err
+	}
+	_, _, _, content, err := decodeStorageEmbeddedRecord(buffer)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.serializer.Unserialize(content)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, nil
+	}
+	return qn_map.NewStrAnyMapFrom(m, true), nil
+}
+
+// SetSession updates the data map for specified session id.
+// This function is called ever after session, which is changed dirty, is closed.
+// This copy all session data map from memory to storage.
+func (s *StorageEmbedded) SetSession(id string, data *qn_map.StrAnyMap, ttl time.Duration) error {
+	content, err := s.serializer.Serialize(data.Map())
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expireAt := qn_time.TimestampMilli() + ttl.Nanoseconds()/1e6
+	record, err := encodeStorageEmbeddedRecord(gSTORAGE_EMBEDDED_OP_SET, expireAt, id, content)
+	if err != nil {
+		return err
+	}
+	offset, err := s.doWrite(record)
+	if err != nil {
+		return err
+	}
+	delete(s.updatingIds, id)
+	s.doSet(id, offset, int64(len(record)), expireAt)
+	return nil
+}
+
+// UpdateTTL updates the TTL for specified session id.
+// This function is called ever after session, which is not dirty, is closed.
+// It updates the TTL in memory and writes it to the data file asynchronously.
+func (s *StorageEmbedded) UpdateTTL(id string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.items[id]; ok {
+		item.expireAt = qn_time.TimestampMilli() + ttl.Nanoseconds()/1e6
+		heap.Push(&s.expiry, storageEmbeddedExpiry{id: id, expireAt: item.expireAt})
+		s.updatingIds[id] = struct{}{}
+	}
+	return nil
+}
+
+// Delete deletes the session of given session id completely from storage.
+func (s *StorageEmbedded) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil
+	}
+	record, err := encodeStorageEmbeddedRecord(gSTORAGE_EMBEDDED_OP_DELETE, 0, id, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = s.doWrite(record); err != nil {
+		return err
+	}
+	delete(s.items, id)
+	delete(s.updatingIds, id)
+	s.garbage += item.size + int64(len(record))
+	return nil
+}
+
+// AddIndex is not supported, the session manager uses its own index.
+func (s *StorageEmbedded) AddIndex(userKey string, id string, ttl time.Duration) error {
+	return ErrorDisabled
+}
+
+// RemoveIndex is not supported, the session manager uses its own index.
+func (s *StorageEmbedded) RemoveIndex(userKey string, ids ...string) error {
+	return ErrorDisabled
+}
+
+// GetIndex is not supported, the session manager uses its own index.
+func (s *StorageEmbedded) GetIndex(userKey string) ([]string, error) {
+	return nil, ErrorDisabled
+}
+
+// Size returns the count of the alive sessions in storage.
+func (s *StorageEmbedded) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items)
+}
+
+// Compact rewrites the data file with only the alive sessions, which reclaims the space of
+// the obsolete records. It's automatically called if most of the data file is obsolete.
+//
+// The new data file is written to a temporary file and then renamed to the data file,
+// so the data file is always complete even if it crashes during compaction.
+//
+// Note that all the session operations are blocked during compaction.
+func (s *StorageEmbedded) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doCompact()
+}
+
+// Close writes the pending TTL updates, closes the data file and releases its lock.
+func (s *StorageEmbedded) Close() error {
+	s.timer.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.file != nil {
+		err = s.doUpdateTTL()
+		if closeErr := s.file.Close(); err == nil {
+			err = closeErr
+		}
+		s.file = nil
+	}
+	if s.lockFile != nil {
+		if closeErr := s.lockFile.Close(); err == nil {
+			err = closeErr
+		}
+		s.lockFile = nil
+	}
+	return err
+}
+
+// lock opens the lock file of the data file and locks it exclusively.
+// The lock file is used instead of the data file, as the data file is replaced by compaction.
+func (s *StorageEmbedded) lock() error {
+	file, err := qn_file.OpenWithFlagPerm(s.path+".lock", os.O_RDWR|os.O_CREATE, qn_file.DefaultPermOpen)
+	if err != nil {
+		return err
+	}
+	if err = lockStorageEmbeddedFile(file); err != nil {
+		file.Close()
+		return err
+	}
+	s.lockFile = file
+	return nil
+}
+
+// open opens the data file and loads the index from it.
+func (s *StorageEmbedded) open() error {
+	file, err := qn_file.OpenWithFlagPerm(s.path, os.O_RDWR|os.O_CREATE, qn_file.DefaultPermOpen)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.size = 0
+	s.garbage = 0
+	s.items = make(map[string]*storageEmbeddedItem)
+	s.expiry = s.expiry[:0]
+	if err = s.load(); err != nil {
+		file.Close()
+		s.file = nil
+		return err
+	}
+	return nil
+}
+
+// load reads all the records from the data file and builds the index.
+// The broken record at the end of the data file is truncated, and it returns error if there's
+// broken record in the middle of the data file.
+func (s *StorageEmbedded) load() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < int64(len(gSTORAGE_EMBEDDED_MAGIC)) {
+		// New data file, or crashed when writing its header.
+		if err = s.file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err = s.file.WriteAt([]byte(gSTORAGE_EMBEDDED_MAGIC), 0); err != nil {
+			return err
+		}
+		s.size = int64(len(gSTORAGE_EMBEDDED_MAGIC))
+		return nil
+	}
+	var (
+		reader = bufio.NewReader(io.NewSectionReader(s.file, 0, info.Size()))
+		magic  = make([]byte, len(gSTORAGE_EMBEDDED_MAGIC))
+		now    = qn_time.TimestampMilli()
+	)
+	if _, err = io.ReadFull(reader, magic); err != nil {
+		return err
+	}
+	if string(magic) != gSTORAGE_EMBEDDED_MAGIC {
+		return errors.New("invalid session data file")
+	}
+	offset := int64(len(magic))
+	for {
+		record, err := readStorageEmbeddedRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			torn, tornErr := s.isTornTail(offset, info.Size())
+			if tornErr != nil {
+				return tornErr
+			}
+			if !torn {
+				return errors.New(fmt.Sprintf("corrupted session record at offset %d: %v", offset, err))
+			}
+			intlog.Errorf("broken session record at offset %d truncated: %v", offset, err)
+			if err = s.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		op, expireAt, id, _, _ := decodeStorageEmbeddedRecord(record)
+		size := int64(len(record))
+		switch op {
+		case gSTORAGE_EMBEDDED_OP_SET:
+			s.doSet(id, offset, size, expireAt)
+
+		case gSTORAGE_EMBEDDED_OP_TOUCH:
+			if item, ok := s.items[id]; ok {
+				item.expireAt = expireAt
+				heap.Push(&s.expiry, storageEmbeddedExpiry{id: id, expireAt: expireAt})
+			}
+			s.garbage += size
+
+		case gSTORAGE_EMBEDDED_OP_DELETE:
+			if item, ok := s.items[id]; ok {
+				delete(s.items, id)
+				s.garbage += item.size
+			}
+			s.garbage += size
+		}
+		offset += size
+	}
+	s.size = offset
+	s.doPurge(now)
+	return nil
+}
+
+// isTornTail checks whether the broken data from <offset> to <size> of the data file is the
+// partially written record caused by crash, which means there's no valid record after it.
+func (s *StorageEmbedded) isTornTail(offset, size int64) (bool, error) {
+	// Only the last write might be partially written, which is a single record.
+	if size-offset > gSTORAGE_EMBEDDED_HEADER_SIZE+gSTORAGE_EMBEDDED_MAX_BODY_SIZE {
+		return false, nil
+	}
+	tail := make([]byte, size-offset)
+	if _, err := s.file.ReadAt(tail, offset); err != nil {
+		return false, err
+	}
+	for i := 1; i+gSTORAGE_EMBEDDED_HEADER_SIZE+gSTORAGE_EMBEDDED_BODY_FIXED_SIZE <= len(tail); i++ {
+		bodySize := int(binary.BigEndian.Uint32(tail[i+4:]))
+		if bodySize < gSTORAGE_EMBEDDED_BODY_FIXED_SIZE || i+gSTORAGE_EMBEDDED_HEADER_SIZE+bodySize > len(tail) {
+			continue
+		}
+		if verifyStorageEmbeddedRecord(tail[i:i+gSTORAGE_EMBEDDED_HEADER_SIZE+bodySize]) == nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// doLoop writes the TTL updates, purges the expired sessions and compacts the data file.
+func (s *StorageEmbedded) doLoop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	if err := s.doUpdateTTL(); err != nil {
+		return err
+	}
+	s.doPurge(qn_time.TimestampMilli())
+	if s.size >= DefaultStorageEmbeddedCompactMinSize &&
+		float64(s.garbage) >= float64(s.size)*DefaultStorageEmbeddedCompactMinRatio {
+		return s.doCompact()
+	}
+	return nil
+}
+
+// doUpdateTTL writes the TTL updates of sessions to the data file.
+func (s *StorageEmbedded) doUpdateTTL() error {
+	for id := range s.updatingIds {
+		if item, ok := s.items[id]; ok {
+			record, err := encodeStorageEmbeddedRecord(gSTORAGE_EMBEDDED_OP_TOUCH, item.expireAt, id, nil)
+			if err != nil {
+				return err
+			}
+			if _, err = s.doWrite(record); err != nil {
+				return err
+			}
+			s.garbage += int64(len(record))
+		}
+		delete(s.updatingIds, id)
+	}
+	return nil
+}
+
+// doPurge removes the sessions expired before <now> from index using the expiration index.
+// It does not write any record, as the expiration is also checked when loading.
+func (s *StorageEmbedded) doPurge(now int64) {
+	for s.expiry.Len() > 0 && s.expiry[0].expireAt < now {
+		expiry := heap.Pop(&s.expiry).(storageEmbeddedExpiry)
+		if item, ok := s.items[expiry.id]; ok && item.expireAt == expiry.expireAt {
+			delete(s.items, expiry.id)
+			delete(s.updatingIds, expiry.id)
+			s.garbage += item.size
+		}
+	}
+	// Rebuilds the expiration index if there're too many outdated items.
+	if s.expiry.Len() > 2*len(s.items)+1024 {
+		s.expiry = make(storageEmbeddedHeap, 0, len(s.items))
+		for id, item := range s.items {
+			s.expiry = append(s.expiry, storageEmbeddedExpiry{id: id, expireAt: item.expireAt})
+		}
+		heap.Init(&s.expiry)
+	}
+}
+
+// doCompact rewrites the data file with only the alive sessions.
+//
+// Note that it holds the write lock of the storage during rewriting, which blocks all the
+// session operations, the duration of which depends on the size of the alive sessions.
+func (s *StorageEmbedded) doCompact() error {
+	if err := s.doUpdateTTL(); err != nil {
+		return err
+	}
+	s.doPurge(qn_time.TimestampMilli())
+	var (
+		tempPath = s.path + ".compact"
+		items    = make(map[string]*storageEmbeddedItem, len(s.items))
+		offset   = int64(len(gSTORAGE_EMBEDDED_MAGIC))
+	)
+	tempFile, err := qn_file.OpenWithFlagPerm(tempPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, qn_file.DefaultPermOpen)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tempFile)
+	err = func() error {
+		if _, err := writer.WriteString(gSTORAGE_EMBEDDED_MAGIC); err != nil {
+			return err
+		}
+		for id, item := range s.items {
+			record := make([]byte, item.size)
+			if _, err := s.file.ReadAt(record, item.offset); err != nil {
+				return err
+			}
+			_, _, _, content, err := decodeStorageEmbeddedRecord(record)
+			if err != nil {
+				return err
+			}
+			// The TTL updates are merged into the new record.
+			if record, err = encodeStorageEmbeddedRecord(gSTORAGE_EMBEDDED_OP_SET, item.expireAt, id, content); err != nil {
+				return err
+			}
+			if _, err = writer.Write(record); err != nil {
+				return err
+			}
+			items[id] = &storageEmbeddedItem{
+				offset:   offset,
+				size:     int64(len(record)),
+				expireAt: item.expireAt,
+			}
+			offset += int64(len(record))
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		return tempFile.Sync()
+	}()
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		qn_file.Remove(tempPath)
+		return err
+	}
+	if err = s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	if err = qn_file.Rename(tempPath, s.path); err != nil {
+		// Reopens the old data file.
+		if openErr := s.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if s.file, err = qn_file.OpenWithFlagPerm(s.path, os.O_RDWR, qn_file.DefaultPermOpen); err != nil {
+		return err
+	}
+	s.items = items
+	s.size = offset
+	s.garbage = 0
+	s.expiry = make(storageEmbeddedHeap, 0, len(items))
+	for id, item := range items {
+		s.expiry = append(s.expiry, storageEmbeddedExpiry{id: id, expireAt: item.expireAt})
+	}
+	heap.Init(&s.expiry)
+	return nil
+}
+
+// doWrite appends <record> to the data file and returns its offset.
+func (s *StorageEmbedded) doWrite(record []byte) (offset int64, err error) {
+	if s.file == nil {
+		return 0, errors.New("storage is closed")
+	}
+	offset = s.size
+	if _, err = s.file.WriteAt(record, offset); err != nil {
+		// Removes the partially written record.
+		s.file.Truncate(offset)
+		return 0, err
+	}
+	if s.syncEnabled {
+		if err = s.file.Sync(); err != nil {
+			return 0, err
+		}
+	}
+	s.size += int64(len(record))
+	return offset, nil
+}
+
+// doSet updates the index with the session record.
+func (s *StorageEmbedded) doSet(id string, offset, size, expireAt int64) {
+	if item, ok := s.items[id]; ok {
+		s.garbage += item.size
+	}
+	s.items[id] = &storageEmbeddedItem{
+		offset:   offset,
+		size:     size,
+		expireAt: expireAt,
+	}
+	heap.Push(&s.expiry, storageEmbeddedExpiry{id: id, expireAt: expireAt})
+}
+
+// encodeStorageEmbeddedRecord encodes and returns a record in format:
+// checksum(4) + body length(4) + op(1) + expireAt(8) + id length(2) + id + content.
+func encodeStorageEmbeddedRecord(op byte, expireAt int64, id string, content []byte) ([]byte, error) {
+	if len(id) > 0xFFFF {
+		return nil, errors.New(fmt.Sprintf("session id too long: %d", len(id)))
+	}
+	bodySize := gSTORAGE_EMBEDDED_BODY_FIXED_SIZE + len(id) + len(content)
+	if bodySize > gSTORAGE_EMBEDDED_MAX_BODY_SIZE {
+		return nil, errors.New(fmt.Sprintf("session data too large: %d", len(content)))
+	}
+	record := make([]byte, gSTORAGE_EMBEDDED_HEADER_SIZE+bodySize)
+	body := record[gSTORAGE_EMBEDDED_HEADER_SIZE:]
+	body[0] = op
+	binary.BigEndian.PutUint64(body[1:], uint64(expireAt))
+	binary.BigEndian.PutUint16(body[9:], uint16(len(id)))
+	copy(body[gSTORAGE_EMBEDDED_BODY_FIXED_SIZE:], id)
+	copy(body[gSTORAGE_EMBEDDED_BODY_FIXED_SIZE+len(id):], content)
+	binary.BigEndian.PutUint32(record[0:], crc32.ChecksumIEEE(body))
+	binary.BigEndian.PutUint32(record[4:], uint32(bodySize))
+	return record, nil
+}
+
+// decodeStorageEmbeddedRecord decodes the record which is verified by readStorageEmbeddedRecord.
+func decodeStorageEmbeddedRecord(record []byte) (op byte, expireAt int64, id string, content []byte, err error) {
+	if len(record) < gSTORAGE_EMBEDDED_HEADER_SIZE+gSTORAGE_EMBEDDED_BODY_FIXED_SIZE {
+		return 0, 0, "", nil, errors.New("invalid session record")
+	}
+	body := record[gSTORAGE_EMBEDDED_HEADER_SIZE:]
+	idSize := int(binary.BigEndian.Uint16(body[9:]))
+	if len(body) < gSTORAGE_EMBEDDED_BODY_FIXED_SIZE+idSize {
+		return 0, 0, "", nil, errors.New("invalid session record")
+	}
+	op = body[0]
+	expireAt = int64(binary.BigEndian.Uint64(body[1:]))
+	id = string(body[gSTORAGE_EMBEDDED_BODY_FIXED_SIZE : gSTORAGE_EMBEDDED_BODY_FIXED_SIZE+idSize])
+	content = body[gSTORAGE_EMBEDDED_BODY_FIXED_SIZE+idSize:]
+	return
+}
+
+// readStorageEmbeddedRecord reads and verifies a record from <reader>.
+// It returns io.EOF if there's no more record.
+func readStorageEmbeddedRecord(reader io.Reader) ([]byte, error) {
+	header := make([]byte, gSTORAGE_EMBEDDED_HEADER_SIZE)
+	if n, err := io.ReadFull(reader, header); err != nil {
+		if err == io.EOF && n == 0 {
+			return nil, io.EOF
+		}
+		return nil, errors.New("incomplete record header")
+	}
+	bodySize := int(binary.BigEndian.Uint32(header[4:]))
+	if bodySize < gSTORAGE_EMBEDDED_BODY_FIXED_SIZE || bodySize > gSTORAGE_EMBEDDED_MAX_BODY_SIZE {
+		return nil, errors.New(fmt.Sprintf("invalid record body size: %d", bodySize))
+	}
+	record := make([]byte, gSTORAGE_EMBEDDED_HEADER_SIZE+bodySize)
+	copy(record, header)
+	if _, err := io.ReadFull(reader, record[gSTORAGE_EMBEDDED_HEADER_SIZE:]); err != nil {
+		return nil, errors.New("incomplete record body")
+	}
+	if err := verifyStorageEmbeddedRecord(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// verifyStorageEmbeddedRecord verifies the checksum and fields of complete record <record>.
+func verifyStorageEmbeddedRecord(record []byte) error {
+	if crc32.ChecksumIEEE(record[gSTORAGE_EMBEDDED_HEADER_SIZE:]) != binary.BigEndian.Uint32(record) {
+		return errors.New("record checksum mismatch")
+	}
+	_, _, _, _, err := decodeStorageEmbeddedRecord(record)
+	return err
+}
+
+func (h storageEmbeddedHeap) Len() int {
+	return len(h)
+}
+
+func (h storageEmbeddedHeap) Less(i, j int) bool {
+	return h[i].expireAt < h[j].expireAt
+}
+
+func (h storageEmbeddedHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *storageEmbeddedHeap) Push(x interface{}) {
+	*h = append(*h, x.(storageEmbeddedExpiry))
+}
+
+func (h *storageEmbeddedHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
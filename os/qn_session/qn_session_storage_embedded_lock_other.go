@@ -0,0 +1,19 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package qn_session
+
+import (
+	"os"
+)
+
+// lockStorageEmbeddedFile does nothing as file locking is not supported on this platform.
+func lockStorageEmbeddedFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package qn_session
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockStorageEmbeddedFile locks <file> exclusively without blocking.
+// The lock is released when the file is closed, or the process exits.
+func lockStorageEmbeddedFile(file *os.File) error {
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return errorStorageEmbeddedLocked
+		}
+		return err
+	}
+	return nil
+}
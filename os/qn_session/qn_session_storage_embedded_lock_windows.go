@@ -0,0 +1,47 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+//go:build windows
+// +build windows
+
+package qn_session
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	gWINDOWS_LOCKFILE_FAIL_IMMEDIATELY = 0x00000001
+	gWINDOWS_LOCKFILE_EXCLUSIVE_LOCK   = 0x00000002
+	gWINDOWS_ERROR_LOCK_VIOLATION      = syscall.Errno(33)
+)
+
+var (
+	procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+)
+
+// lockStorageEmbeddedFile locks <file> exclusively without blocking.
+// The lock is released when the file is closed, or the process exits.
+func lockStorageEmbeddedFile(file *os.File) error {
+	overlapped := new(syscall.Overlapped)
+	r, _, err := procLockFileEx.Call(
+		file.Fd(),
+		gWINDOWS_LOCKFILE_EXCLUSIVE_LOCK|gWINDOWS_LOCKFILE_FAIL_IMMEDIATELY,
+		0,
+		1,
+		0,
+		uintptr(unsafe.Pointer(overlapped)),
+	)
+	if r == 0 {
+		if err == gWINDOWS_ERROR_LOCK_VIOLATION {
+			return errorStorageEmbeddedLocked
+		}
+		return err
+	}
+	return nil
+}
@@ -7,8 +7,10 @@
 package qn_session
 
 import (
+	"encoding/hex"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/qnsoft/common/container/qn_map"
@@ -31,6 +33,8 @@ type StorageFile struct {
 	cryptoKey     []byte
 	cryptoEnabled bool
 	updatingIdSet *qn_set.StrSet
+	serializer    Serializer
+	indexMu       sync.Mutex // Mutex for concurrent-safe operations on index files.
 }
 
 var (
@@ -62,6 +66,7 @@ func NewStorageFile(path ...string) *StorageFile {
 		cryptoKey:     DefaultStorageFileCryptoKey,
 		cryptoEnabled: DefaultStorageFileCryptoEnabled,
 		updatingIdSet: qn_set.NewStrSet(true),
+		serializer:    DefaultSerializer,
 	}
 	// Batch updates the TTL for session ids timely.
 	qn_timer.AddSingleton(DefaultStorageFileLoopInterval, func() {
@@ -92,6 +97,11 @@ func (s *StorageFile) SetCryptoEnabled(enabled bool) {
 	s.cryptoEnabled = enabled
 }
 
+// SetSerializer sets the serializer for session storage, which is DefaultSerializer in default.
+func (s *StorageFile) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+}
+
 // sessionFilePath returns the storage file path for given session id.
 func (s *StorageFile) sessionFilePath(id string) string {
 	return qn_file.Join(s.path, id)
@@ -164,13 +174,13 @@ func (s *StorageFile) GetSession(id string, ttl time.Duration, data *qn_map.StrA
 		content = content[8:]
 		// Decrypt with AES.
 		if s.cryptoEnabled {
-			content, err = gaes.Decrypt(content, DefaultStorageFileCryptoKey)
+			content, err = gaes.Decrypt(content, s.cryptoKey)
 			if err != nil {
 				return nil, err
 			}
 		}
-		var m map[string]interface{}
-		if err = json.Unmarshal(content, &m); err != nil {
+		m, err := s.serializer.Unserialize(content)
+		if err != nil {
 			return nil, err
 		}
 		if m == nil {
@@ -187,13 +197,13 @@ func (s *StorageFile) GetSession(id string, ttl time.Duration, data *qn_map.StrA
 func (s *StorageFile) SetSession(id string, data *qn_map.StrAnyMap, ttl time.Duration) error {
 	intlog.Printf("StorageFile.SetSession: %s, %v, %v", id, data, ttl)
 	path := s.sessionFilePath(id)
-	content, err := json.Marshal(data)
+	content, err := s.serializer.Serialize(data.Map())
 	if err != nil {
 		return err
 	}
 	// Encrypt with AES.
 	if s.cryptoEnabled {
-		content, err = gaes.Encrypt(content, DefaultStorageFileCryptoKey)
+		content, err = gaes.Encrypt(content, s.cryptoKey)
 		if err != nil {
 			return err
 		}
@@ -238,3 +248,90 @@ func (s *StorageFile) doUpdateTTL(id string) error {
 	}
 	return file.Close()
 }
+
+// indexFilePath returns the index file path for given user key.
+func (s *StorageFile) indexFilePath(userKey string) string {
+	return qn_file.Join(s.path, "index", hex.EncodeToString([]byte(userKey)))
+}
+
+// Delete deletes the session of given session id completely from storage.
+func (s *StorageFile) Delete(id string) error {
+	s.updatingIdSet.Remove(id)
+	path := s.sessionFilePath(id)
+	if !qn_file.Exists(path) {
+		return nil
+	}
+	return qn_file.Remove(path)
+}
+
+// AddIndex adds session id <id> to the index of <userKey>.
+// The index file is not expired by <ttl>, as the dead ids are removed by the manager.
+func (s *StorageFile) AddIndex(userKey string, id string, ttl time.Duration) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	ids, err := s.doGetIndex(userKey)
+	if err != nil {
+		return err
+	}
+	for _, v := range ids {
+		if v == id {
+			return nil
+		}
+	}
+	return s.doSetIndex(userKey, append(ids, id))
+}
+
+// RemoveIndex removes session ids <ids> from the index of <userKey>.
+func (s *StorageFile) RemoveIndex(userKey string, ids ...string) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	oldIds, err := s.doGetIndex(userKey)
+	if err != nil {
+		return err
+	}
+	removed := qn_set.NewStrSetFrom(ids)
+	newIds := make([]string, 0, len(oldIds))
+	for _, v := range oldIds {
+		if !removed.Contains(v) {
+			newIds = append(newIds, v)
+		}
+	}
+	return s.doSetIndex(userKey, newIds)
+}
+
+// GetIndex retrieves and returns the session ids in the index of <userKey>.
+func (s *StorageFile) GetIndex(userKey string) ([]string, error) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	return s.doGetIndex(userKey)
+}
+
+// doGetIndex reads the session ids from the index file of <userKey>.
+func (s *StorageFile) doGetIndex(userKey string) ([]string, error) {
+	content := qn_file.GetBytes(s.indexFilePath(userKey))
+	if len(content) == 0 {
+		return nil, nil
+	}
+	var ids []string
+	if err := json.Unmarshal(content, &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// doSetIndex writes the session ids to the index file of <userKey>,
+// or removes the index file if <ids> is empty.
+func (s *StorageFile) doSetIndex(userKey string, ids []string) error {
+	path := s.indexFilePath(userKey)
+	if len(ids) == 0 {
+		if qn_file.Exists(path) {
+			return qn_file.Remove(path)
+		}
+		return nil
+	}
+	content, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return qn_file.PutBytes(path, content)
+}
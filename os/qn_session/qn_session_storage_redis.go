@@ -12,7 +12,6 @@ import (
 	"github.com/qnsoft/common/container/qn_map"
 	"github.com/qnsoft/common/database/gredis"
 	"github.com/qnsoft/common/internal/intlog"
-	"github.com/qnsoft/common/internal/json"
 	"github.com/qnsoft/common/os/qn_timer"
 )
 
@@ -21,8 +20,14 @@ type StorageRedis struct {
 	redis         *gredis.Redis     // Redis client for session storage.
 	prefix        string            // Redis key prefix for session id.
 	updatingIdMap *qn_map.StrIntMap // Updating TTL set for session id.
+	serializer    Serializer        // Serializer for session data.
 }
 
+const (
+	// gSESSION_REDIS_INDEX_PREFIX is the redis key prefix for the user key index.
+	gSESSION_REDIS_INDEX_PREFIX = "index:"
+)
+
 var (
 	// DefaultStorageRedisLoopInterval is the interval updating TTL for session ids
 	// in last duration.
@@ -38,6 +43,7 @@ func NewStorageRedis(redis *gredis.Redis, prefix ...string) *StorageRedis {
 	s := &StorageRedis{
 		redis:         redis,
 		updatingIdMap: qn_map.NewStrIntMap(true),
+		serializer:    DefaultSerializer,
 	}
 	if len(prefix) > 0 && prefix[0] != "" {
 		s.prefix = prefix[0]
@@ -62,6 +68,11 @@ func NewStorageRedis(redis *gredis.Redis, prefix ...string) *StorageRedis {
 	return s
 }
 
+// SetSerializer sets the serializer for session storage, which is DefaultSerializer in default.
+func (s *StorageRedis) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+}
+
 // New creates a session id.
 // This function can be used for custom session creation.
 func (s *StorageRedis) New(ttl time.Duration) (id string) {
@@ -123,8 +134,8 @@ func (s *StorageRedis) GetSession(id string, ttl time.Duration, data *qn_map.Str
 	if len(content) == 0 {
 		return nil, nil
 	}
-	var m map[string]interface{}
-	if err = json.Unmarshal(content, &m); err != nil {
+	m, err := s.serializer.Unserialize(content)
+	if err != nil {
 		return nil, err
 	}
 	if m == nil {
@@ -143,7 +154,7 @@ func (s *StorageRedis) GetSession(id string, ttl time.Duration, data *qn_map.Str
 // This copy all session data map from memory to storage.
 func (s *StorageRedis) SetSession(id string, data *qn_map.StrAnyMap, ttl time.Duration) error {
 	intlog.Printf("StorageRedis.SetSession: %s, %v, %v", id, data, ttl)
-	content, err := json.Marshal(data)
+	content, err := s.serializer.Serialize(data.Map())
 	if err != nil {
 		return err
 	}
@@ -172,3 +183,59 @@ func (s *StorageRedis) doUpdateTTL(id string, ttlSeconds int) error {
 func (s *StorageRedis) key(id string) string {
 	return s.prefix + id
 }
+
+// Delete deletes the session of given session id completely from storage.
+func (s *StorageRedis) Delete(id string) error {
+	s.updatingIdMap.Remove(id)
+	_, err := s.redis.Do("DEL", s.key(id))
+	return err
+}
+
+// AddIndex adds session id <id> to the index of <userKey>.
+// The parameter <ttl> specifies the TTL for the index.
+func (s *StorageRedis) AddIndex(userKey string, id string, ttl time.Duration) error {
+	return redisAddIndex(s.redis, s.indexKey(userKey), id, ttl)
+}
+
+// RemoveIndex removes session ids <ids> from the index of <userKey>.
+func (s *StorageRedis) RemoveIndex(userKey string, ids ...string) error {
+	return redisRemoveIndex(s.redis, s.indexKey(userKey), ids...)
+}
+
+// GetIndex retrieves and returns the session ids in the index of <userKey>.
+func (s *StorageRedis) GetIndex(userKey string) ([]string, error) {
+	return redisGetIndex(s.redis, s.indexKey(userKey))
+}
+
+func (s *StorageRedis) indexKey(userKey string) string {
+	return s.prefix + gSESSION_REDIS_INDEX_PREFIX + userKey
+}
+
+// redisAddIndex adds <id> to the redis set of <key> and refreshes its TTL.
+func redisAddIndex(redis *gredis.Redis, key string, id string, ttl time.Duration) error {
+	if _, err := redis.Do("SADD", key, id); err != nil {
+		return err
+	}
+	_, err := redis.Do("EXPIRE", key, int64(ttl.Seconds()))
+	return err
+}
+
+// redisRemoveIndex removes <ids> from the redis set of <key>.
+func redisRemoveIndex(redis *gredis.Redis, key string, ids ...string) error {
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, key)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := redis.Do("SREM", args...)
+	return err
+}
+
+// redisGetIndex retrieves and returns all the members of the redis set of <key>.
+func redisGetIndex(redis *gredis.Redis, key string) ([]string, error) {
+	r, err := redis.DoVar("SMEMBERS", key)
+	if err != nil {
+		return nil, err
+	}
+	return r.Strings(), nil
+}
@@ -151,3 +151,29 @@ func (s *StorageRedisHashTable) UpdateTTL(id string, ttl time.Duration) error {
 func (s *StorageRedisHashTable) key(id string) string {
 	return s.prefix + id
 }
+
+// Delete deletes the session of given session id completely from storage.
+func (s *StorageRedisHashTable) Delete(id string) error {
+	_, err := s.redis.Do("DEL", s.key(id))
+	return err
+}
+
+// AddIndex adds session id <id> to the index of <userKey>.
+// The parameter <ttl> specifies the TTL for the index.
+func (s *StorageRedisHashTable) AddIndex(userKey string, id string, ttl time.Duration) error {
+	return redisAddIndex(s.redis, s.indexKey(userKey), id, ttl)
+}
+
+// RemoveIndex removes session ids <ids> from the index of <userKey>.
+func (s *StorageRedisHashTable) RemoveIndex(userKey string, ids ...string) error {
+	return redisRemoveIndex(s.redis, s.indexKey(userKey), ids...)
+}
+
+// GetIndex retrieves and returns the session ids in the index of <userKey>.
+func (s *StorageRedisHashTable) GetIndex(userKey string) ([]string, error) {
+	return redisGetIndex(s.redis, s.indexKey(userKey))
+}
+
+func (s *StorageRedisHashTable) indexKey(userKey string) string {
+	return s.prefix + gSESSION_REDIS_INDEX_PREFIX + userKey
+}
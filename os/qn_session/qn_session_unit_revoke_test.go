@@ -0,0 +1,87 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_session"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_Session_Revoke(t *testing.T) {
+	dir := qn_file.TempDir(qn_time.TimestampNanoStr())
+	qn_file.Mkdir(dir)
+	defer qn_file.Remove(dir)
+
+	storages := []qn_session.Storage{
+		qn_session.NewStorageMemory(),
+		qn_session.NewStorageFile(dir),
+	}
+	for _, storage := range storages {
+		manager := qn_session.New(time.Minute, storage)
+		qn_test.C(t, func(t *qn_test.T) {
+			// Session fixation protection.
+			s := manager.New()
+			t.Assert(s.Set("k", "v"), nil)
+			t.Assert(s.Bind("john"), nil)
+			t.Assert(s.UserKey(), "john")
+			oldId := s.Id()
+			s.Close()
+
+			s = manager.New(oldId)
+			t.Assert(s.Regenerate(), nil)
+			newId := s.Id()
+			t.AssertNE(newId, oldId)
+			t.Assert(s.Get("k"), "v")
+			t.Assert(s.UserKey(), "john")
+			s.Close()
+
+			s = manager.New(oldId)
+			t.AssertNE(s.Id(), oldId)
+			t.Assert(s.Get("k"), nil)
+			s.Close()
+
+			s = manager.New(newId)
+			t.Assert(s.Id(), newId)
+			t.Assert(s.Get("k"), "v")
+			s.Close()
+
+			// Sessions of the user.
+			s2 := manager.New()
+			t.Assert(s2.Bind("john"), nil)
+			s2.Close()
+			ids, err := manager.Sessions("john")
+			t.Assert(err, nil)
+			t.Assert(len(ids), 2)
+			t.AssertIN(newId, ids)
+			t.AssertIN(s2.Id(), ids)
+
+			// Revoking single session.
+			t.Assert(manager.Revoke(s2.Id()), nil)
+			ids, err = manager.Sessions("john")
+			t.Assert(err, nil)
+			t.Assert(ids, []string{newId})
+
+			// Revoking all sessions, the session being used cannot be restored by closing.
+			s = manager.New(newId)
+			t.Assert(s.Get("k"), "v")
+			t.Assert(manager.RevokeAll("john"), nil)
+			s.Set("k", "v2")
+			s.Close()
+			ids, err = manager.Sessions("john")
+			t.Assert(err, nil)
+			t.Assert(len(ids), 0)
+			s = manager.New(newId)
+			t.AssertNE(s.Id(), newId)
+			t.Assert(s.Get("k"), nil)
+		})
+	}
+}
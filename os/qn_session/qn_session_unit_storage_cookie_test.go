@@ -0,0 +1,90 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/os/qn_session"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_StorageCookie(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		for _, cryptoEnabled := range []bool{false, true} {
+			storage := qn_session.NewStorageCookie([]byte("key1"))
+			storage.SetCryptoEnabled(cryptoEnabled)
+			manager := qn_session.New(time.Minute, storage)
+
+			s := manager.New()
+			t.Assert(s.Set("k1", "v1"), nil)
+			t.Assert(s.Set("k2", 2), nil)
+			t.Assert(s.Flush(), nil)
+			id := s.Id()
+			t.Assert(strings.Contains(id, "v1"), !cryptoEnabled)
+
+			data, err := storage.Decode(id, time.Minute)
+			t.Assert(err, nil)
+			t.Assert(data["k1"], "v1")
+			t.Assert(data["k2"], 2)
+
+			// Another manager without memory cached session data.
+			s = qn_session.New(time.Minute, storage).New(id)
+			t.Assert(s.Id(), id)
+			t.Assert(s.Get("k1"), "v1")
+			t.Assert(s.GetInt("k2"), 2)
+
+			// Tampered.
+			_, err = storage.Decode(id+"a", time.Minute)
+			t.Assert(err, qn_session.ErrorStorageCookieInvalid)
+			_, err = storage.Decode("a"+id, time.Minute)
+			t.Assert(err, qn_session.ErrorStorageCookieInvalid)
+			s = qn_session.New(time.Minute, storage).New("a" + id)
+			t.AssertNE(s.Id(), "a"+id)
+			t.Assert(s.Get("k1"), nil)
+
+			// Expired.
+			time.Sleep(10 * time.Millisecond)
+			data, err = storage.Decode(id, time.Millisecond)
+			t.Assert(err, nil)
+			t.Assert(data, nil)
+		}
+	})
+	// Key rotation.
+	qn_test.C(t, func(t *qn_test.T) {
+		oldStorage := qn_session.NewStorageCookie([]byte("old"))
+		s := qn_session.New(time.Minute, oldStorage).New()
+		t.Assert(s.Set("k", "v"), nil)
+		t.Assert(s.Flush(), nil)
+		oldId := s.Id()
+
+		storage := qn_session.NewStorageCookie([]byte("new"), []byte("old"))
+		data, err := storage.Decode(oldId, time.Minute)
+		t.Assert(err, nil)
+		t.Assert(data["k"], "v")
+
+		s = qn_session.New(time.Minute, storage).New()
+		t.Assert(s.Set("k", "v"), nil)
+		t.Assert(s.Flush(), nil)
+		_, err = oldStorage.Decode(s.Id(), time.Minute)
+		t.Assert(err, qn_session.ErrorStorageCookieInvalid)
+
+		storage.SetKeys([]byte("new"))
+		_, err = storage.Decode(oldId, time.Minute)
+		t.Assert(err, qn_session.ErrorStorageCookieInvalid)
+	})
+	// Size limit.
+	qn_test.C(t, func(t *qn_test.T) {
+		storage := qn_session.NewStorageCookie([]byte("key"))
+		storage.SetMaxSize(100)
+		s := qn_session.New(time.Minute, storage).New()
+		t.Assert(s.Set("k", strings.Repeat("v", 100)), nil)
+		t.AssertNE(s.Flush(), nil)
+	})
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session_test
+
+import (
+	"encoding/gob"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_session"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+type sessionUser struct {
+	Id   int
+	Name string
+}
+
+func init() {
+	gob.Register(sessionUser{})
+}
+
+func Test_Serializer(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		data := map[string]interface{}{
+			"user": sessionUser{Id: 1, Name: "john"},
+		}
+		content, err := qn_session.SerializerGob.Serialize(data)
+		t.Assert(err, nil)
+		m, err := qn_session.SerializerGob.Unserialize(content)
+		t.Assert(err, nil)
+		t.Assert(m["user"].(sessionUser).Name, "john")
+
+		content, err = qn_session.SerializerJson.Serialize(data)
+		t.Assert(err, nil)
+		m, err = qn_session.SerializerJson.Unserialize(content)
+		t.Assert(err, nil)
+		_, ok := m["user"].(sessionUser)
+		t.Assert(ok, false)
+	})
+	qn_test.C(t, func(t *qn_test.T) {
+		now := time.Unix(1600000000, 123)
+		data := map[string]interface{}{
+			"id":    10000,
+			"name":  "john",
+			"score": 99.5,
+			"time":  now,
+			"tags":  []string{"a", "b"},
+			"extra": map[string]interface{}{"vip": true},
+		}
+		content, err := qn_session.SerializerMsgpack.Serialize(data)
+		t.Assert(err, nil)
+		m, err := qn_session.SerializerMsgpack.Unserialize(content)
+		t.Assert(err, nil)
+		t.Assert(m["id"].(int64), 10000)
+		t.Assert(m["name"].(string), "john")
+		t.Assert(m["score"].(float64), 99.5)
+		t.Assert(m["time"].(time.Time).Equal(now), true)
+		t.Assert(m["tags"], []interface{}{"a", "b"})
+		t.Assert(m["extra"].(map[string]interface{})["vip"], true)
+
+		_, err = qn_session.SerializerMsgpack.Unserialize(content[:len(content)-1])
+		t.AssertNE(err, nil)
+		_, err = qn_session.SerializerMsgpack.Serialize(map[string]interface{}{
+			"user": sessionUser{Id: 1, Name: "john"},
+		})
+		t.AssertNE(err, nil)
+	})
+}
+
+func Test_StorageCrypto(t *testing.T) {
+	dir := qn_file.TempDir(qn_time.TimestampNanoStr())
+	qn_file.Mkdir(dir)
+	defer qn_file.Remove(dir)
+
+	var (
+		fileStorage = qn_session.NewStorageFile(dir)
+		key1        = []byte("0123456789abcdef")
+		key2        = []byte("fedcba9876543210")
+		sessionId   = ""
+	)
+	fileStorage.SetSerializer(qn_session.SerializerGob)
+	qn_test.C(t, func(t *qn_test.T) {
+		storage := qn_session.NewStorageCrypto(fileStorage, "k1", key1)
+		storage.SetSerializer(qn_session.SerializerGob)
+		s := qn_session.New(time.Minute, storage).New()
+		t.Assert(s.Set("user", sessionUser{Id: 1, Name: "john"}), nil)
+		sessionId = s.Id()
+		s.Close()
+
+		content := qn_file.GetContents(qn_file.Join(dir, sessionId))
+		t.AssertNE(content, "")
+		t.Assert(strings.Contains(content, "john"), false)
+	})
+	// Key rotation.
+	qn_test.C(t, func(t *qn_test.T) {
+		storage := qn_session.NewStorageCrypto(fileStorage, "k2", key2)
+		storage.SetSerializer(qn_session.SerializerGob)
+		storage.AddKey("k1", key1)
+		s := qn_session.New(time.Minute, storage).New(sessionId)
+		t.Assert(s.Id(), sessionId)
+		t.Assert(s.Get("user").(sessionUser).Name, "john")
+		t.Assert(s.Set("k", "v"), nil)
+		s.Close()
+
+		storage = qn_session.NewStorageCrypto(fileStorage, "k2", key2)
+		storage.SetSerializer(qn_session.SerializerGob)
+		s = qn_session.New(time.Minute, storage).New(sessionId)
+		t.Assert(s.Id(), sessionId)
+		t.Assert(s.Get("user").(sessionUser).Id, 1)
+		t.Assert(s.Get("k"), "v")
+
+		storage = qn_session.NewStorageCrypto(fileStorage, "k1", key1)
+		storage.SetSerializer(qn_session.SerializerGob)
+		s = qn_session.New(time.Minute, storage).New(sessionId)
+		t.AssertNE(s.Id(), sessionId)
+		t.Assert(s.Get("user"), nil)
+	})
+}
+
+func Test_StorageCrypto_Encoder(t *testing.T) {
+	qn_test.C(t, func(t *qn_test.T) {
+		defer func() {
+			t.AssertNE(recover(), nil)
+		}()
+		qn_session.NewStorageCrypto(qn_session.NewStorageCookie([]byte("secret")), "k1", []byte("0123456789abcdef"))
+	})
+}
+
+// The crypto storage implements StorageIndexer, but returns ErrorDisabled for the
+// underlying storages without index, which makes the manager use its in-memory index.
+func Test_StorageCrypto_Revoke(t *testing.T) {
+	dir := qn_file.TempDir(qn_time.TimestampNanoStr())
+	qn_file.Mkdir(dir)
+	defer qn_file.Remove(dir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	storages := []qn_session.Storage{
+		qn_session.NewStorageCrypto(qn_session.NewStorageMemory(), "k1", key),
+		qn_session.NewStorageCrypto(qn_session.NewStorageFile(dir), "k1", key),
+	}
+	for _, storage := range storages {
+		manager := qn_session.New(time.Minute, storage)
+		qn_test.C(t, func(t *qn_test.T) {
+			s1 := manager.New()
+			t.Assert(s1.Set("k", "v"), nil)
+			t.Assert(s1.Bind("john"), nil)
+			s1.Close()
+			s2 := manager.New()
+			t.Assert(s2.Bind("john"), nil)
+			s2.Close()
+			ids, err := manager.Sessions("john")
+			t.Assert(err, nil)
+			t.Assert(len(ids), 2)
+
+			t.Assert(manager.Revoke(s2.Id()), nil)
+			ids, err = manager.Sessions("john")
+			t.Assert(err, nil)
+			t.Assert(ids, []string{s1.Id()})
+
+			t.Assert(manager.RevokeAll("john"), nil)
+			ids, err = manager.Sessions("john")
+			t.Assert(err, nil)
+			t.Assert(len(ids), 0)
+			s := manager.New(s1.Id())
+			t.AssertNE(s.Id(), s1.Id())
+			t.Assert(s.Get("k"), nil)
+		})
+	}
+}
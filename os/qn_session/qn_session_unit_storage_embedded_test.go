@@ -0,0 +1,114 @@
+// Copyright 2020 gf Author(https://github.com/qnsoft/common). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/qnsoft/common.
+
+package qn_session_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/qnsoft/common/os/qn_file"
+	"github.com/qnsoft/common/os/qn_session"
+	"github.com/qnsoft/common/os/qn_time"
+	"github.com/qnsoft/common/test/qn_test"
+)
+
+func Test_StorageEmbedded(t *testing.T) {
+	var (
+		dir  = qn_file.TempDir(qn_time.TimestampNanoStr())
+		path = qn_file.Join(dir, "sessions.db")
+	)
+	defer qn_file.Remove(dir)
+
+	qn_test.C(t, func(t *qn_test.T) {
+		storage := qn_session.NewStorageEmbedded(path)
+		manager := qn_session.New(time.Minute, storage)
+		for i := 0; i < 10; i++ {
+			s := manager.New(fmt.Sprintf("id%d", i))
+			t.Assert(s.Set("k", i), nil)
+			s.Close()
+		}
+		// Overwritten.
+		s := manager.New("id0")
+		t.Assert(s.Set("k", "v"), nil)
+		s.Close()
+		// Deleted.
+		t.Assert(manager.Revoke("id1"), nil)
+		// Expired.
+		s = qn_session.New(time.Millisecond, storage).New("expired")
+		t.Assert(s.Set("k", "v"), nil)
+		s.Close()
+		t.Assert(storage.Close(), nil)
+
+		time.Sleep(10 * time.Millisecond)
+		storage = qn_session.NewStorageEmbedded(path)
+		defer storage.Close()
+		t.Assert(storage.Size(), 9)
+		manager = qn_session.New(time.Minute, storage)
+		t.Assert(manager.New("id0").Get("k"), "v")
+		t.Assert(manager.New("id9").Get("k"), 9)
+		s = manager.New("id1")
+		t.AssertNE(s.Id(), "id1")
+		s = manager.New("expired")
+		t.AssertNE(s.Id(), "expired")
+
+		// Compaction.
+		size := qn_file.Size(path)
+		t.Assert(storage.Compact(), nil)
+		t.AssertLT(qn_file.Size(path), size)
+		t.Assert(storage.Size(), 9)
+		t.Assert(qn_session.New(time.Minute, storage).New("id5").Get("k"), 5)
+	})
+	// The data file can be opened by only one storage.
+	qn_test.C(t, func(t *qn_test.T) {
+		storage := qn_session.NewStorageEmbedded(path)
+		func() {
+			defer func() {
+				t.AssertNE(recover(), nil)
+			}()
+			qn_session.NewStorageEmbedded(path)
+		}()
+		t.Assert(storage.Close(), nil)
+		storage = qn_session.NewStorageEmbedded(path)
+		t.Assert(storage.Size(), 9)
+		t.Assert(storage.Close(), nil)
+	})
+	// Broken record at the end of data file.
+	qn_test.C(t, func(t *qn_test.T) {
+		size := qn_file.Size(path)
+		file, err := qn_file.OpenWithFlag(path, os.O_WRONLY|os.O_APPEND)
+		t.Assert(err, nil)
+		_, err = file.Write([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8})
+		t.Assert(err, nil)
+		t.Assert(file.Close(), nil)
+
+		storage := qn_session.NewStorageEmbedded(path)
+		defer storage.Close()
+		t.Assert(qn_file.Size(path), size)
+		t.Assert(storage.Size(), 9)
+		t.Assert(qn_session.New(time.Minute, storage).New("id2").Get("k"), 2)
+	})
+	// Broken record in the middle of data file.
+	qn_test.C(t, func(t *qn_test.T) {
+		file, err := qn_file.OpenWithFlag(path, os.O_RDWR)
+		t.Assert(err, nil)
+		// The first byte of the body of the first record.
+		_, err = file.WriteAt([]byte{0xFF}, 16)
+		t.Assert(err, nil)
+		t.Assert(file.Close(), nil)
+
+		size := qn_file.Size(path)
+		func() {
+			defer func() {
+				t.AssertNE(recover(), nil)
+			}()
+			qn_session.NewStorageEmbedded(path)
+		}()
+		t.Assert(qn_file.Size(path), size)
+	})
+}